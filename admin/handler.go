@@ -0,0 +1,95 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/czcorpus/cnc-gokit/uniresp"
+	"github.com/czcorpus/cnc-vlo/cnchook"
+	"github.com/gin-gonic/gin"
+)
+
+// Hook provides operations exposed by the admin endpoints
+type Hook interface {
+	ClearCache()
+	GetLastServed(from, until time.Time) (cnchook.ServedRange, bool)
+}
+
+// Handler serves admin endpoints. All the endpoints require
+// the configured token (see Authorize).
+type Handler struct {
+	hook  Hook
+	token string
+}
+
+// Authorize is a middleware rejecting requests without the admin
+// token provided as `Authorization: Bearer <token>`
+func (h *Handler) Authorize(ctx *gin.Context) {
+	token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !ok || h.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		uniresp.WriteJSONErrorResponse(
+			ctx.Writer, uniresp.NewActionError("unauthorized"), http.StatusUnauthorized)
+		ctx.Abort()
+		return
+	}
+	ctx.Next()
+}
+
+// HandleCacheClear invalidates all the cached values
+func (h *Handler) HandleCacheClear(ctx *gin.Context) {
+	h.hook.ClearCache()
+	uniresp.WriteJSONResponse(ctx.Writer, map[string]bool{"ok": true})
+}
+
+// HandleLastServed returns the most recent range of records served
+// by ListRecords within optional RFC 3339 `from` and `until` bounds
+// (the whole kept history by default)
+func (h *Handler) HandleLastServed(ctx *gin.Context) {
+	from, until := time.Time{}, time.Now()
+	for arg, value := range map[string]*time.Time{"from": &from, "until": &until} {
+		if v := ctx.Query(arg); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				uniresp.WriteJSONErrorResponse(
+					ctx.Writer,
+					uniresp.NewActionError("invalid `%s` argument, RFC 3339 expected", arg),
+					http.StatusBadRequest,
+				)
+				return
+			}
+			*value = parsed
+		}
+	}
+	served, ok := h.hook.GetLastServed(from, until)
+	if !ok {
+		uniresp.WriteJSONErrorResponse(
+			ctx.Writer, uniresp.NewActionError("no records served within the range"), http.StatusNotFound)
+		return
+	}
+	uniresp.WriteJSONResponse(ctx.Writer, served)
+}
+
+func NewHandler(hook Hook, token string) *Handler {
+	return &Handler{
+		hook:  hook,
+		token: token,
+	}
+}
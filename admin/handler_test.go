@@ -0,0 +1,125 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/czcorpus/cnc-vlo/cnchook"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubHook struct {
+	numClears int
+	served    []cnchook.ServedRange
+}
+
+func (h *stubHook) ClearCache() {
+	h.numClears++
+}
+
+func (h *stubHook) GetLastServed(from, until time.Time) (cnchook.ServedRange, bool) {
+	for i := len(h.served) - 1; i >= 0; i-- {
+		if !h.served[i].Time.Before(from) && !h.served[i].Time.After(until) {
+			return h.served[i], true
+		}
+	}
+	return cnchook.ServedRange{}, false
+}
+
+func doAdminRequest(handler *Handler, method, path, authorization string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	routes := engine.Group("/admin", handler.Authorize)
+	routes.POST("/cache/clear", handler.HandleCacheClear)
+	routes.GET("/harvest/last-served", handler.HandleLastServed)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestCacheClearAuthorized(t *testing.T) {
+	hook := &stubHook{}
+	w := doAdminRequest(NewHandler(hook, "secret"), http.MethodPost, "/admin/cache/clear", "Bearer secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, hook.numClears)
+}
+
+func TestCacheClearUnauthorized(t *testing.T) {
+	hook := &stubHook{}
+	handler := NewHandler(hook, "secret")
+	for _, authorization := range []string{"", "Bearer wrong", "secret", "Basic secret"} {
+		w := doAdminRequest(handler, http.MethodPost, "/admin/cache/clear", authorization)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, authorization)
+	}
+	assert.Equal(t, 0, hook.numClears)
+}
+
+func TestCacheClearNoToken(t *testing.T) {
+	// an empty token must never match
+	hook := &stubHook{}
+	w := doAdminRequest(NewHandler(hook, ""), http.MethodPost, "/admin/cache/clear", "Bearer ")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, 0, hook.numClears)
+}
+
+func TestLastServed(t *testing.T) {
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	hook := &stubHook{served: []cnchook.ServedRange{
+		{Time: base, MetadataPrefix: "oai_dc", NumRecords: 2, MinRecordID: 1, MaxRecordID: 2},
+		{Time: base.Add(time.Hour), MetadataPrefix: "cmdi", NumRecords: 1, MinRecordID: 7, MaxRecordID: 7},
+	}}
+	handler := NewHandler(hook, "secret")
+
+	w := doAdminRequest(handler, http.MethodGet, "/admin/harvest/last-served", "Bearer secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var served cnchook.ServedRange
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+	assert.Equal(t, "cmdi", served.MetadataPrefix)
+
+	w = doAdminRequest(
+		handler, http.MethodGet, "/admin/harvest/last-served?until=2024-05-01T10:30:00Z", "Bearer secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+	assert.Equal(t, "oai_dc", served.MetadataPrefix)
+
+	w = doAdminRequest(
+		handler, http.MethodGet, "/admin/harvest/last-served?from=2024-05-02T00:00:00Z", "Bearer secret")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestLastServedInvalidArg(t *testing.T) {
+	handler := NewHandler(&stubHook{}, "secret")
+	w := doAdminRequest(handler, http.MethodGet, "/admin/harvest/last-served?from=yesterday", "Bearer secret")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "`from`")
+}
+
+func TestLastServedUnauthorized(t *testing.T) {
+	handler := NewHandler(&stubHook{}, "secret")
+	w := doAdminRequest(handler, http.MethodGet, "/admin/harvest/last-served", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -18,6 +18,7 @@
 package cncdb
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -35,23 +36,71 @@ type DBOverrides struct {
 	UserTableName         string `json:"userTableName"`
 	UserTableFirstNameCol string `json:"userTableFirstNameCol"`
 	UserTableLastNameCol  string `json:"userTableLastNameCol"`
+
+	// UserTableAffiliationENCol is an optional user table column
+	// with an English version of the affiliation (the `affiliation`
+	// column is expected to be in Czech)
+	UserTableAffiliationENCol string `json:"userTableAffiliationEnCol"`
 }
 
+const (
+	// recordDatestampExpr is a datestamp of a record, i.e. the time
+	// of the last modification or the time of deletion. In case
+	// the deletion time is unknown, the last modification is used.
+	recordDatestampExpr = "IF(m.deleted, COALESCE(m.deleted_date, GREATEST(m.created, m.updated)), " +
+		"GREATEST(m.created, m.updated))"
+
+	// modifiedDatestampExpr is a datestamp of a non-deleted record,
+	// i.e. the time of the last modification
+	modifiedDatestampExpr = "GREATEST(m.created, m.updated)"
+
+	// deletedVisibilityCond matches non-deleted records and records
+	// deleted after being published. Records created as deleted
+	// (i.e. drafts, see scripts/triggers.sql) have no deletion date
+	// and are not exposed (unless undated deletions are included).
+	deletedVisibilityCond = "(m.deleted = FALSE OR m.deleted_date IS NOT NULL)"
+)
+
 type CNCMySQLHandler struct {
 	conn             *sql.DB
 	overrides        DBOverrides
 	publicCorplistID int
+
+	// includeUndatedDeletions exposes deleted records
+	// without a deletion date (see DatabaseSetup)
+	includeUndatedDeletions bool
+
+	// hostedRecords filters records by the `hosted` flag
+	// (see DatabaseSetup)
+	hostedRecords string
+
+	// includeOrigin enables selecting of the record origin columns
+	// (otherwise, records have no origin data)
+	includeOrigin bool
+
+	// queryTimeout limits duration of context-aware queries
+	// (zero means no limit)
+	queryTimeout time.Duration
+
+	// debugQueries enables logging of SQL queries
+	// (should be enabled only in the debug mode)
+	debugQueries bool
 }
 
 type DBData struct {
 	ID            int
 	Date          time.Time
+	Deleted       bool
 	Hosted        bool
 	Type          string
 	Name          string
 	DescEN        sql.NullString
 	DescCS        sql.NullString
 	DateIssued    string
+	DateAvailable sql.NullString
+	SourceID      sql.NullInt64 // ID of a record the resource is derived from
+	PID           sql.NullString
+	Origin        OriginData
 	TitleEN       string
 	TitleCS       string
 	Link          sql.NullString
@@ -59,6 +108,96 @@ type DBData struct {
 	Authors       string
 	ContactPerson ContactPersonData
 	CorpusData    CorpusData
+
+	// Corplists contains corplists (OAI-PMH sets) the record belongs to
+	Corplists []Corplist
+
+	// CorplistSetSpecs contains setSpecs of the Corplists. They are
+	// filled in by the hook as they depend on all the listed corplists.
+	CorplistSetSpecs []string
+
+	// KeywordIDs contains IDs of keywords the corpus is tagged with
+	KeywordIDs []string
+
+	// Distributions contains downloadable distributions of the resource
+	Distributions []Distribution
+
+	// Relations contains typed relations to other resources
+	Relations []Relation
+
+	// Funding contains grants and projects funding the resource
+	Funding []Funding
+
+	// RegistryAttrs contains optional corpus registry attributes
+	RegistryAttrs RegistryAttrs
+}
+
+// RecordStamp identifies a record and its last modification
+type RecordStamp struct {
+	ID        int
+	Datestamp time.Time
+}
+
+type Corplist struct {
+	ID   int
+	Name string
+}
+
+type Keyword struct {
+	ID      string
+	LabelEN string
+}
+
+type RegistryAttrs struct {
+	Tagsets        []string
+	AlignedCorpora []string
+}
+
+// SetFilter limits listed records to members of an OAI-PMH set
+// (i.e. corpora tagged with a keyword or belonging to a corplist)
+type SetFilter struct {
+	Keyword    string
+	AnyKeyword bool
+	CorplistID int
+}
+
+// RecordCursor identifies a position in the list of records
+// ordered by datestamps and IDs
+type RecordCursor struct {
+	Datestamp time.Time
+	ID        int
+}
+
+// OriginData describes origin of records ingested
+// from an upstream pipeline
+type OriginData struct {
+	BaseURL    sql.NullString
+	Identifier sql.NullString
+	Datestamp  sql.NullTime
+	Synced     sql.NullTime
+}
+
+// Distribution is a downloadable form of a resource
+// (e.g. a vertical file or CoNLL-U)
+type Distribution struct {
+	URL      string
+	MimeType string
+	Size     sql.NullInt64 // in bytes
+}
+
+// Relation is a typed relation to another resource
+// (e.g. `isVersionOf` or `references`)
+type Relation struct {
+	Type   string
+	Target string
+}
+
+// Funding describes a grant or a project funding the resource
+type Funding struct {
+	Organization string
+	Code         string // grant or project ID
+	ProjectName  string
+	FundsType    string
 }
 
 type ContactPersonData struct {
@@ -66,42 +205,171 @@ type ContactPersonData struct {
 	Lastname    string
 	Email       string
 	Affiliation sql.NullString
+
+	// AffiliationEN is an English version of the affiliation
+	// (the `Affiliation` is in Czech)
+	AffiliationEN sql.NullString
 }
 
 type CorpusData struct {
 	Size     sql.NullInt64
 	Locale   *language.Tag
 	Keywords sql.NullString
+
+	// TimePeriods and Places describe temporal and spatial
+	// coverage (comma-separated values)
+	TimePeriods sql.NullString
+	Places      sql.NullString
+
+	// ParallelLocales contains locales of all the corpora
+	// aligned with the corpus (incl. the corpus itself)
+	ParallelLocales []language.Tag
+}
+
+// logQuery logs query template and bound arguments before execution
+// (in case debugging of queries is enabled). The returned function
+// is expected to be called once the query results are read.
+// Note: none of the bound arguments contains sensitive data
+// (only record IDs, corplist IDs, corpus names and dates).
+func (c *CNCMySQLHandler) logQuery(query string, args ...any) func(numRows int) {
+	if !c.debugQueries {
+		return func(numRows int) {}
+	}
+	t0 := time.Now()
+	log.Debug().Str("query", query).Any("args", args).Msg("executing DB query")
+	return func(numRows int) {
+		log.Debug().
+			Str("query", query).
+			Int("numRows", numRows).
+			Dur("duration", time.Since(t0)).
+			Msg("DB query finished")
+	}
+}
+
+// affiliationENExpr returns an SQL expression selecting
+// the English affiliation of a contact person (if configured)
+func (c *CNCMySQLHandler) affiliationENExpr() string {
+	if c.overrides.UserTableAffiliationENCol == "" {
+		return "NULL"
+	}
+	return "u." + c.overrides.UserTableAffiliationENCol
+}
+
+// originExpr returns SQL expressions selecting the record origin
+// (if enabled). The origin columns are not required otherwise.
+func (c *CNCMySQLHandler) originExpr() string {
+	if !c.includeOrigin {
+		return "NULL, NULL, NULL, NULL"
+	}
+	return "m.origin_base_url, m.origin_identifier, m.origin_datestamp, m.origin_synced"
+}
+
+// queryContext derives a context for a single query
+// applying the configured query timeout
+func (c *CNCMySQLHandler) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.queryTimeout > 0 {
+		return context.WithTimeout(ctx, c.queryTimeout)
+	}
+	return context.WithCancel(ctx)
 }
 
-func (c *CNCMySQLHandler) GetFirstDate() (time.Time, error) {
-	var date time.Time
-	row := c.conn.QueryRow("SELECT MIN(created) FROM vlo_metadata_common")
+func (c *CNCMySQLHandler) GetFirstDate(ctx context.Context) (time.Time, error) {
+	var date sql.NullTime
+	query := "SELECT MIN(created) FROM vlo_metadata_common WHERE created > '0000-00-00'"
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query)
+	row := c.conn.QueryRowContext(ctx, query)
 	err := row.Scan(&date)
-	return date, err
+	done(1)
+	return date.Time, err
+}
+
+// datestampExpr returns an SQL expression of record datestamps.
+// The `deleted_date` column (see scripts/schema_update.sql) is
+// referenced only in case deleted records are included.
+func datestampExpr(includeDeleted bool) string {
+	if includeDeleted {
+		return recordDatestampExpr
+	}
+	return modifiedDatestampExpr
+}
+
+// datestampFromDB converts a nullable DB datetime into a record
+// datestamp. With `ParseTime` enabled, zero dates (`0000-00-00`) are
+// scanned as a zero time and NULLs (e.g. from GREATEST with a NULL
+// argument) are not valid. In both cases, a zero time is returned
+// and a warning is logged so a single broken row does not break
+// the whole harvesting.
+func datestampFromDB(recordID int, value sql.NullTime) time.Time {
+	if !value.Valid || value.Time.IsZero() {
+		log.Warn().Int("id", recordID).Msg("record has a zero or missing datestamp")
+		return time.Time{}
+	}
+	return value.Time
+}
+
+// deletedCond returns a condition for filtering deleted records
+// (see deletedVisibilityCond)
+func (c *CNCMySQLHandler) deletedCond(includeDeleted bool) string {
+	if !includeDeleted {
+		return "m.deleted = FALSE"
+	}
+	if c.includeUndatedDeletions {
+		return "TRUE"
+	}
+	return deletedVisibilityCond
+}
+
+// hostedCond returns a condition for filtering records by the `hosted`
+// flag (see DatabaseSetup). If all the records are included, an empty
+// string is returned.
+func (c *CNCMySQLHandler) hostedCond() string {
+	switch c.hostedRecords {
+	case HostedRecordsHosted:
+		return "m.hosted = TRUE"
+	case HostedRecordsExternal:
+		return "m.hosted = FALSE"
+	}
+	return ""
+}
+
+// recordCond combines the deleted and hosted conditions
+// for queries of a single record
+func (c *CNCMySQLHandler) recordCond(includeDeleted bool) string {
+	if cond := c.hostedCond(); cond != "" {
+		return c.deletedCond(includeDeleted) + " AND " + cond
+	}
+	return c.deletedCond(includeDeleted)
 }
 
-func (c *CNCMySQLHandler) IdentifierExists(identifier string) (bool, error) {
+// IdentifierExists tests whether a publicly visible record exists.
+// Deleted records are considered only if `includeDeleted` is true.
+func (c *CNCMySQLHandler) IdentifierExists(ctx context.Context, identifier string, includeDeleted bool) (bool, error) {
 	var id int
-	row := c.conn.QueryRow(
-		fmt.Sprintf(
-			"SELECT m.id FROM vlo_metadata_common AS m "+
-				"LEFT JOIN vlo_metadata_corpus AS mc ON m.corpus_metadata_id = mc.id "+
-				"LEFT JOIN %s AS c ON m.corpus_name = c.name "+
-				"LEFT JOIN corplist_corpus AS cc ON c.id = cc.corpus_id "+
-				"WHERE m.id = ? AND m.deleted = FALSE "+
-				"AND ((m.type = 'corpus' AND cc.corplist_id = ?) OR m.type != 'corpus')",
-			c.overrides.CorporaTableName,
-		),
-		identifier, c.publicCorplistID,
+	query := fmt.Sprintf(
+		"SELECT m.id FROM vlo_metadata_common AS m "+
+			"LEFT JOIN vlo_metadata_corpus AS mc ON m.corpus_metadata_id = mc.id "+
+			"LEFT JOIN %s AS c ON m.corpus_name = c.name "+
+			"LEFT JOIN corplist_corpus AS cc ON c.id = cc.corpus_id "+
+			"WHERE m.id = ? AND %s "+
+			"AND ((m.type = 'corpus' AND cc.corplist_id = ?) OR m.type != 'corpus')",
+		c.overrides.CorporaTableName, c.recordCond(includeDeleted),
 	)
+	args := []any{identifier, c.publicCorplistID}
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, args...)
+	row := c.conn.QueryRowContext(ctx, query, args...)
 	err := row.Scan(&id)
 	if err != nil {
 		if err == sql.ErrNoRows {
+			done(0)
 			return false, nil
 		}
 		return false, fmt.Errorf("failed to check identifier existence record info: %w", err)
 	}
+	done(1)
 	return true, nil
 }
 
@@ -128,60 +396,81 @@ func (c *CNCMySQLHandler) parseLocale(loc string) (ans language.Tag, err error)
 	return
 }
 
-func (c *CNCMySQLHandler) GetRecordInfo(identifier string) (*DBData, error) {
+// GetRecordInfo returns a publicly visible record or nil if there is
+// no such record. Deleted records are returned only if `includeDeleted`
+// is true.
+func (c *CNCMySQLHandler) GetRecordInfo(ctx context.Context, identifier string, includeDeleted bool) (*DBData, error) {
 	var data DBData
 	var locale sql.NullString
+	var date sql.NullTime
 
-	row := c.conn.QueryRow(
-		fmt.Sprintf(
-			"SELECT "+
-				"m.id, "+
-				"GREATEST(m.created, m.updated), "+
-				"m.hosted, "+
-				"m.type, "+
-				"m.desc_en, "+
-				"m.desc_cs, "+
-				"m.date_issued, "+
-				"m.license_info, "+
-				"m.authors, "+
-				"u.%s, "+
-				"u.%s, "+
-				"u.email, "+
-				"u.affiliation, "+
-				"COALESCE(c.name, ms.name), "+
-				"COALESCE(rc.name, c.name, ms.name), "+
-				"COALESCE(rc.name, c.name, ms.name), "+
-				"COALESCE(c.web, ms.link), "+
-				"c.size, c.locale, GROUP_CONCAT(k.label_en ORDER BY k.display_order SEPARATOR ',') "+
-				"FROM vlo_metadata_common AS m "+
-				"LEFT JOIN vlo_metadata_corpus AS mc ON m.corpus_metadata_id = mc.id "+
-				"LEFT JOIN vlo_metadata_service AS ms ON m.service_metadata_id = ms.id "+
-				"LEFT JOIN %s AS c ON mc.corpus_name = c.name "+
-				"LEFT JOIN kontext_keyword_corpus AS kc ON kc.corpus_name = c.name "+
-				"LEFT JOIN kontext_keyword AS k ON kc.keyword_id = k.id "+
-				"LEFT JOIN corplist_corpus AS cc ON c.id = cc.corpus_id "+
-				"LEFT JOIN corplist_parallel_corpus AS cpc ON cpc.parallel_corpus_id = c.parallel_corpus_id "+
-				"LEFT JOIN registry_conf AS rc ON mc.corpus_name = rc.corpus_name "+
-				"JOIN %s AS u ON m.contact_user_id = u.id "+
-				"WHERE m.id = ? AND m.deleted = FALSE "+
-				"AND ((m.type = 'corpus' AND cc.corplist_id = ?) OR (cpc.corplist_id = ?) OR m.type != 'corpus') "+
-				"GROUP BY kc.corpus_name ",
-			c.overrides.UserTableFirstNameCol, c.overrides.UserTableLastNameCol,
-			c.overrides.CorporaTableName, c.overrides.UserTableName,
-		), identifier, c.publicCorplistID, c.publicCorplistID,
+	query := fmt.Sprintf(
+		"SELECT "+
+			"m.id, "+
+			"%s, "+
+			"m.deleted, "+
+			"m.hosted, "+
+			"m.type, "+
+			"m.desc_en, "+
+			"m.desc_cs, "+
+			"m.date_issued, "+
+			"m.date_available, "+
+			"m.source_metadata_id, "+
+			"m.pid, "+
+			"%s, "+
+			"m.license_info, "+
+			"m.authors, "+
+			"u.%s, "+
+			"u.%s, "+
+			"u.email, "+
+			"u.affiliation, "+
+			"%s, "+
+			"COALESCE(c.name, ms.name), "+
+			"COALESCE(rc.name, c.name, ms.name), "+
+			"COALESCE(rc.name, c.name, ms.name), "+
+			"COALESCE(c.web, ms.link), "+
+			"c.size, c.locale, GROUP_CONCAT(k.label_en ORDER BY k.display_order SEPARATOR ','), "+
+			"mc.time_periods, mc.places "+
+			"FROM vlo_metadata_common AS m "+
+			"LEFT JOIN vlo_metadata_corpus AS mc ON m.corpus_metadata_id = mc.id "+
+			"LEFT JOIN vlo_metadata_service AS ms ON m.service_metadata_id = ms.id "+
+			"LEFT JOIN %s AS c ON mc.corpus_name = c.name "+
+			"LEFT JOIN kontext_keyword_corpus AS kc ON kc.corpus_name = c.name "+
+			"LEFT JOIN kontext_keyword AS k ON kc.keyword_id = k.id "+
+			"LEFT JOIN corplist_corpus AS cc ON c.id = cc.corpus_id "+
+			"LEFT JOIN corplist_parallel_corpus AS cpc ON cpc.parallel_corpus_id = c.parallel_corpus_id "+
+			"LEFT JOIN registry_conf AS rc ON mc.corpus_name = rc.corpus_name "+
+			"JOIN %s AS u ON m.contact_user_id = u.id "+
+			"WHERE m.id = ? AND %s "+
+			"AND ((m.type = 'corpus' AND cc.corplist_id = ?) OR (cpc.corplist_id = ?) OR m.type != 'corpus') "+
+			"GROUP BY kc.corpus_name ",
+		datestampExpr(includeDeleted), c.originExpr(),
+		c.overrides.UserTableFirstNameCol, c.overrides.UserTableLastNameCol, c.affiliationENExpr(),
+		c.overrides.CorporaTableName, c.overrides.UserTableName, c.recordCond(includeDeleted),
 	)
+	args := []any{identifier, c.publicCorplistID, c.publicCorplistID}
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, args...)
+	row := c.conn.QueryRowContext(ctx, query, args...)
 	err := row.Scan(
-		&data.ID, &data.Date, &data.Hosted, &data.Type, &data.DescEN, &data.DescCS, &data.DateIssued, &data.License, &data.Authors,
+		&data.ID, &date, &data.Deleted, &data.Hosted, &data.Type, &data.DescEN, &data.DescCS, &data.DateIssued, &data.DateAvailable, &data.SourceID, &data.PID,
+		&data.Origin.BaseURL, &data.Origin.Identifier, &data.Origin.Datestamp, &data.Origin.Synced,
+		&data.License, &data.Authors,
 		&data.ContactPerson.Firstname, &data.ContactPerson.Lastname, &data.ContactPerson.Email,
-		&data.ContactPerson.Affiliation, &data.Name, &data.TitleEN, &data.TitleCS, &data.Link,
+		&data.ContactPerson.Affiliation, &data.ContactPerson.AffiliationEN, &data.Name, &data.TitleEN, &data.TitleCS, &data.Link,
 		&data.CorpusData.Size, &locale, &data.CorpusData.Keywords,
+		&data.CorpusData.TimePeriods, &data.CorpusData.Places,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
+			done(0)
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get record info: %w", err)
 	}
+	done(1)
+	data.Date = datestampFromDB(data.ID, date)
 	if locale.Valid {
 		tag, err := c.parseLocale(locale.String)
 		if err != nil {
@@ -192,46 +481,202 @@ func (c *CNCMySQLHandler) GetRecordInfo(identifier string) (*DBData, error) {
 	return &data, nil
 }
 
-func (c *CNCMySQLHandler) ListRecordInfo(from *time.Time, until *time.Time) ([]DBData, error) {
+// listRecordsWhere prepares WHERE clause conditions and respective
+// values for listing records. Deleted records are included only
+// if `includeDeleted` is true (see deletedVisibilityCond). A non-empty
+// `set` limits the records to members of an OAI-PMH set.
+func (c *CNCMySQLHandler) listRecordsWhere(from *time.Time, until *time.Time, set SetFilter, includeDeleted bool) ([]string, []any) {
 	whereClause := []string{
-		"m.deleted = ?",
 		"((m.type = 'corpus' AND cc.corplist_id = ?) OR cpc.corplist_id = ? OR m.type != 'corpus')",
+		c.deletedCond(includeDeleted),
 	}
 	whereValues := []any{
-		"FALSE",
 		c.publicCorplistID,
 		c.publicCorplistID,
 	}
+	if cond := c.hostedCond(); cond != "" {
+		whereClause = append(whereClause, cond)
+	}
 	if from != nil {
-		whereClause = append(whereClause, "GREATEST(m.created, m.updated) >= ?")
+		whereClause = append(whereClause, datestampExpr(includeDeleted)+" >= ?")
 		whereValues = append(whereValues, from)
 	}
 	if until != nil {
-		whereClause = append(whereClause, "GREATEST(m.created, m.updated) <= ?")
+		whereClause = append(whereClause, datestampExpr(includeDeleted)+" <= ?")
 		whereValues = append(whereValues, until)
 	}
+	if set.Keyword != "" {
+		whereClause = append(
+			whereClause,
+			"EXISTS (SELECT 1 FROM kontext_keyword_corpus AS kf WHERE kf.corpus_name = c.name AND kf.keyword_id = ?)",
+		)
+		whereValues = append(whereValues, set.Keyword)
+	}
+	if set.AnyKeyword {
+		whereClause = append(
+			whereClause,
+			"EXISTS (SELECT 1 FROM kontext_keyword_corpus AS kf WHERE kf.corpus_name = c.name)",
+		)
+	}
+	if set.CorplistID > 0 {
+		whereClause = append(whereClause, corplistMemberCond("?"))
+		whereValues = append(whereValues, set.CorplistID, set.CorplistID)
+	}
+	return whereClause, whereValues
+}
+
+// corplistMemberCond creates a condition matching corpora (directly
+// or via their parallel corpus) included in a corplist specified
+// by the corplistIDExpr (a placeholder or a column)
+func corplistMemberCond(corplistIDExpr string) string {
+	return fmt.Sprintf(
+		"(EXISTS (SELECT 1 FROM corplist_corpus AS ccf WHERE ccf.corpus_id = c.id AND ccf.corplist_id = %s) OR "+
+			"EXISTS (SELECT 1 FROM corplist_parallel_corpus AS cpcf "+
+			"WHERE cpcf.parallel_corpus_id = c.parallel_corpus_id AND cpcf.corplist_id = %s))",
+		corplistIDExpr, corplistIDExpr,
+	)
+}
+
+// visibleRecordsQuery creates an aggregating query over records matching
+// the same criteria as ListRecordInfo.
+func (c *CNCMySQLHandler) visibleRecordsQuery(selectExpr string, from *time.Time, until *time.Time, set SetFilter, includeDeleted bool) (string, []any) {
+	whereClause, whereValues := c.listRecordsWhere(from, until, set, includeDeleted)
+	query := fmt.Sprintf(
+		"SELECT %s "+
+			"FROM vlo_metadata_common AS m "+
+			"LEFT JOIN vlo_metadata_corpus AS mc ON m.corpus_metadata_id = mc.id "+
+			"LEFT JOIN %s AS c ON mc.corpus_name = c.name "+
+			"LEFT JOIN corplist_corpus AS cc ON c.id = cc.corpus_id "+
+			"LEFT JOIN corplist_parallel_corpus AS cpc ON cpc.parallel_corpus_id = c.parallel_corpus_id ",
+		selectExpr, c.overrides.CorporaTableName,
+	)
+	if len(whereClause) > 0 {
+		query += " WHERE " + strings.Join(whereClause, " AND ")
+	}
+	return query, whereValues
+}
+
+// CountRecords returns the number of records matching the same
+// criteria as ListRecordInfo (i.e. the complete list size)
+func (c *CNCMySQLHandler) CountRecords(
+	ctx context.Context,
+	from *time.Time,
+	until *time.Time,
+	set SetFilter,
+	includeDeleted bool,
+) (int, error) {
+	query, args := c.visibleRecordsQuery("COUNT(DISTINCT m.id)", from, until, set, includeDeleted)
+	var count int
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, args...)
+	row := c.conn.QueryRowContext(ctx, query, args...)
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count records: %w", err)
+	}
+	done(1)
+	return count, nil
+}
+
+// GetLastUpdate returns datestamp of the most recently updated
+// publicly visible record
+func (c *CNCMySQLHandler) GetLastUpdate(ctx context.Context) (time.Time, error) {
+	query, args := c.visibleRecordsQuery("MAX("+modifiedDatestampExpr+")", nil, nil, SetFilter{}, false)
+	var date sql.NullTime
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, args...)
+	row := c.conn.QueryRowContext(ctx, query, args...)
+	if err := row.Scan(&date); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get last update: %w", err)
+	}
+	done(1)
+	return date.Time, nil
+}
+
+// ListRecordStamps lists IDs and datestamps of all the publicly
+// visible (non-deleted) records ordered by their IDs. Unlike
+// ListRecordInfo, no other record data are loaded.
+func (c *CNCMySQLHandler) ListRecordStamps(ctx context.Context) ([]RecordStamp, error) {
+	query, args := c.visibleRecordsQuery(
+		"DISTINCT m.id, "+modifiedDatestampExpr, nil, nil, SetFilter{}, false)
+	query += " ORDER BY m.id"
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, args...)
+	rows, err := c.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list record stamps: %w", err)
+	}
+	defer rows.Close()
+	ans := make([]RecordStamp, 0, 100)
+	for rows.Next() {
+		var stamp RecordStamp
+		var date sql.NullTime
+		if err := rows.Scan(&stamp.ID, &date); err != nil {
+			return nil, fmt.Errorf("failed to list record stamps: %w", err)
+		}
+		stamp.Datestamp = datestampFromDB(stamp.ID, date)
+		ans = append(ans, stamp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list record stamps: %w", err)
+	}
+	done(len(ans))
+	return ans, nil
+}
+
+// ListRecordInfo lists records ordered by their datestamps and IDs.
+// If `after` is set, only records following the cursor are returned
+// (keyset pagination). A positive `limit` bounds the number of records.
+func (c *CNCMySQLHandler) ListRecordInfo(
+	ctx context.Context,
+	from *time.Time,
+	until *time.Time,
+	set SetFilter,
+	includeDeleted bool,
+	after *RecordCursor,
+	limit int,
+) ([]DBData, error) {
+	whereClause, whereValues := c.listRecordsWhere(from, until, set, includeDeleted)
+	datestamp := datestampExpr(includeDeleted)
+	if after != nil {
+		whereClause = append(
+			whereClause,
+			"("+datestamp+" > ? OR ("+datestamp+" = ? AND m.id > ?))",
+		)
+		whereValues = append(whereValues, after.Datestamp, after.Datestamp, after.ID)
+	}
 	query := fmt.Sprintf(
 		"SELECT "+
 			"m.id, "+
-			" GREATEST(m.created, m.updated), "+
+			"%s, "+
+			"m.deleted, "+
 			"m.hosted, "+
 			"m.type, "+
 			"m.desc_en, "+
 			"m.desc_cs, "+
 			"m.date_issued, "+
+			"m.date_available, "+
+			"m.source_metadata_id, "+
+			"m.pid, "+
+			"%s, "+
 			"m.license_info, "+
 			"m.authors, "+
 			"u.%s, "+
 			"u.%s, "+
 			"u.email, "+
 			"u.affiliation, "+
+			"%s, "+
 			"COALESCE(c.name, ms.name), "+
 			"COALESCE(rc.name, c.name, ms.name), "+
 			"COALESCE(rc.name, c.name, ms.name), "+
 			"COALESCE(c.web, ms.link), "+
 			"c.size, "+
 			"c.locale, "+
-			"GROUP_CONCAT(k.label_en ORDER BY k.display_order SEPARATOR ',') "+
+			"GROUP_CONCAT(k.label_en ORDER BY k.display_order SEPARATOR ','), "+
+			"mc.time_periods, "+
+			"mc.places "+
 			"FROM vlo_metadata_common AS m "+
 			"LEFT JOIN vlo_metadata_corpus AS mc ON m.corpus_metadata_id = mc.id "+
 			"LEFT JOIN vlo_metadata_service AS ms ON m.service_metadata_id = ms.id "+
@@ -242,30 +687,44 @@ func (c *CNCMySQLHandler) ListRecordInfo(from *time.Time, until *time.Time) ([]D
 			"LEFT JOIN corplist_parallel_corpus AS cpc ON cpc.parallel_corpus_id = c.parallel_corpus_id "+
 			"LEFT JOIN registry_conf AS rc ON mc.corpus_name = rc.corpus_name "+
 			"JOIN %s AS u ON m.contact_user_id = u.id ",
-		c.overrides.UserTableFirstNameCol, c.overrides.UserTableLastNameCol,
+		datestamp, c.originExpr(),
+		c.overrides.UserTableFirstNameCol, c.overrides.UserTableLastNameCol, c.affiliationENExpr(),
 		c.overrides.CorporaTableName, c.overrides.UserTableName,
 	)
 	if len(whereClause) > 0 {
 		query += " WHERE " + strings.Join(whereClause, " AND ")
 	}
-	query += " GROUP BY c.name "
-	rows, err := c.conn.Query(query, whereValues...)
+	query += " GROUP BY m.id ORDER BY " + datestamp + ", m.id"
+	if limit > 0 {
+		query += " LIMIT ?"
+		whereValues = append(whereValues, limit)
+	}
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, whereValues...)
+	rows, err := c.conn.QueryContext(ctx, query, whereValues...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list record info: %w", err)
 	}
+	defer rows.Close()
 	results := make([]DBData, 0, 10)
 	for rows.Next() {
 		var row DBData
 		var locale sql.NullString
+		var date sql.NullTime
 		err := rows.Scan(
-			&row.ID, &row.Date, &row.Hosted, &row.Type, &row.DescEN, &row.DescCS, &row.DateIssued, &row.License, &row.Authors,
+			&row.ID, &date, &row.Deleted, &row.Hosted, &row.Type, &row.DescEN, &row.DescCS, &row.DateIssued, &row.DateAvailable, &row.SourceID, &row.PID,
+			&row.Origin.BaseURL, &row.Origin.Identifier, &row.Origin.Datestamp, &row.Origin.Synced,
+			&row.License, &row.Authors,
 			&row.ContactPerson.Firstname, &row.ContactPerson.Lastname, &row.ContactPerson.Email,
-			&row.ContactPerson.Affiliation, &row.Name, &row.TitleEN, &row.TitleCS, &row.Link,
+			&row.ContactPerson.Affiliation, &row.ContactPerson.AffiliationEN, &row.Name, &row.TitleEN, &row.TitleCS, &row.Link,
 			&row.CorpusData.Size, &locale, &row.CorpusData.Keywords,
+			&row.CorpusData.TimePeriods, &row.CorpusData.Places,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list record info: %w", err)
 		}
+		row.Date = datestampFromDB(row.ID, date)
 		if locale.String != "" {
 			tag, err := c.parseLocale(locale.String)
 			if err != nil {
@@ -275,10 +734,403 @@ func (c *CNCMySQLHandler) ListRecordInfo(from *time.Time, until *time.Time) ([]D
 		}
 		results = append(results, row)
 	}
+	// e.g. a cancelled context terminates the iteration early
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list record info: %w", err)
+	}
+	done(len(results))
 	return results, nil
 }
 
-func NewCNCMySQLHandler(cnf DatabaseSetup) (*CNCMySQLHandler, error) {
+// GetParallelLocales returns locales of all the members of parallel corpora
+// the provided corpora belong to. Corpora without a parallel corpus are
+// not present in the result.
+func (c *CNCMySQLHandler) GetParallelLocales(ctx context.Context, corpusNames []string) (map[string][]language.Tag, error) {
+	ans := make(map[string][]language.Tag)
+	if len(corpusNames) == 0 {
+		return ans, nil
+	}
+	placeholders, values := inClauseArgs(corpusNames)
+	query := fmt.Sprintf(
+		"SELECT c.name, pc.locale FROM %s AS c "+
+			"JOIN %s AS pc ON pc.parallel_corpus_id = c.parallel_corpus_id "+
+			"WHERE c.name IN (%s) AND pc.locale IS NOT NULL "+
+			"ORDER BY c.name, pc.name",
+		c.overrides.CorporaTableName, c.overrides.CorporaTableName, placeholders,
+	)
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, values...)
+	rows, err := c.conn.QueryContext(ctx, query, values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parallel locales: %w", err)
+	}
+	defer rows.Close()
+	var numRows int
+	for rows.Next() {
+		var name, locale string
+		if err := rows.Scan(&name, &locale); err != nil {
+			return nil, fmt.Errorf("failed to get parallel locales: %w", err)
+		}
+		tag, err := c.parseLocale(locale)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parallel locales: %w", err)
+		}
+		ans[name] = append(ans[name], tag)
+		numRows++
+	}
+	done(numRows)
+	return ans, rows.Err()
+}
+
+// GetCorplists returns corplists the provided corpora belong to
+// (either directly or via a parallel corpus)
+func (c *CNCMySQLHandler) GetCorplists(ctx context.Context, corpusNames []string) (map[string][]Corplist, error) {
+	ans := make(map[string][]Corplist)
+	if len(corpusNames) == 0 {
+		return ans, nil
+	}
+	placeholders, values := inClauseArgs(corpusNames)
+	query := fmt.Sprintf(
+		"SELECT c.name, cl.id, cl.name FROM %s AS c "+
+			"JOIN corplist_corpus AS cc ON cc.corpus_id = c.id "+
+			"JOIN corplist AS cl ON cl.id = cc.corplist_id "+
+			"WHERE c.name IN (%s) "+
+			"UNION "+
+			"SELECT c.name, cl.id, cl.name FROM %s AS c "+
+			"JOIN corplist_parallel_corpus AS cpc ON cpc.parallel_corpus_id = c.parallel_corpus_id "+
+			"JOIN corplist AS cl ON cl.id = cpc.corplist_id "+
+			"WHERE c.name IN (%s) "+
+			"ORDER BY 1, 3, 2",
+		c.overrides.CorporaTableName, placeholders,
+		c.overrides.CorporaTableName, placeholders,
+	)
+	args := append(values, values...)
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, args...)
+	rows, err := c.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get corplists: %w", err)
+	}
+	defer rows.Close()
+	var numRows int
+	for rows.Next() {
+		var name string
+		var corplist Corplist
+		if err := rows.Scan(&name, &corplist.ID, &corplist.Name); err != nil {
+			return nil, fmt.Errorf("failed to get corplists: %w", err)
+		}
+		ans[name] = append(ans[name], corplist)
+		numRows++
+	}
+	done(numRows)
+	return ans, rows.Err()
+}
+
+// GetKeywords returns IDs of keywords the provided corpora are tagged with
+func (c *CNCMySQLHandler) GetKeywords(ctx context.Context, corpusNames []string) (map[string][]string, error) {
+	if len(corpusNames) == 0 {
+		return make(map[string][]string), nil
+	}
+	placeholders, values := inClauseArgs(corpusNames)
+	ans, err := c.selectNameValues(
+		ctx,
+		fmt.Sprintf(
+			"SELECT kc.corpus_name, kc.keyword_id FROM kontext_keyword_corpus AS kc "+
+				"WHERE kc.corpus_name IN (%s) "+
+				"ORDER BY kc.corpus_name, kc.keyword_id",
+			placeholders,
+		),
+		values,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get keywords: %w", err)
+	}
+	return ans, nil
+}
+
+// ListCorplists returns corplists containing at least one publicly
+// visible (non-deleted) record
+func (c *CNCMySQLHandler) ListCorplists(ctx context.Context) ([]Corplist, error) {
+	recordsQuery, args := c.visibleRecordsQuery("1", nil, nil, SetFilter{}, false)
+	query := "SELECT cl.id, cl.name FROM corplist AS cl " +
+		"WHERE EXISTS (" + recordsQuery + " AND " + corplistMemberCond("cl.id") + ") " +
+		"ORDER BY cl.name, cl.id"
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, args...)
+	rows, err := c.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list corplists: %w", err)
+	}
+	defer rows.Close()
+	ans := make([]Corplist, 0, 20)
+	for rows.Next() {
+		var corplist Corplist
+		if err := rows.Scan(&corplist.ID, &corplist.Name); err != nil {
+			return nil, fmt.Errorf("failed to list corplists: %w", err)
+		}
+		ans = append(ans, corplist)
+	}
+	done(len(ans))
+	return ans, rows.Err()
+}
+
+// ListKeywords returns all the keywords available for tagging corpora
+func (c *CNCMySQLHandler) ListKeywords(ctx context.Context) ([]Keyword, error) {
+	query := "SELECT k.id, k.label_en FROM kontext_keyword AS k ORDER BY k.display_order, k.id"
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query)
+	rows, err := c.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keywords: %w", err)
+	}
+	defer rows.Close()
+	ans := make([]Keyword, 0, 20)
+	for rows.Next() {
+		var keyword Keyword
+		if err := rows.Scan(&keyword.ID, &keyword.LabelEN); err != nil {
+			return nil, fmt.Errorf("failed to list keywords: %w", err)
+		}
+		ans = append(ans, keyword)
+	}
+	done(len(ans))
+	return ans, rows.Err()
+}
+
+// GetDistributions returns downloadable distributions of the provided records
+func (c *CNCMySQLHandler) GetDistributions(ctx context.Context, recordIDs []int) (map[int][]Distribution, error) {
+	ans := make(map[int][]Distribution)
+	if len(recordIDs) == 0 {
+		return ans, nil
+	}
+	placeholders, values := inClauseArgs(recordIDs)
+	query := fmt.Sprintf(
+		"SELECT d.metadata_id, d.url, d.mime_type, d.size FROM vlo_metadata_distribution AS d "+
+			"WHERE d.metadata_id IN (%s) "+
+			"ORDER BY d.metadata_id, d.id",
+		placeholders,
+	)
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, values...)
+	rows, err := c.conn.QueryContext(ctx, query, values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get distributions: %w", err)
+	}
+	defer rows.Close()
+	var numRows int
+	for rows.Next() {
+		var recordID int
+		var dist Distribution
+		if err := rows.Scan(&recordID, &dist.URL, &dist.MimeType, &dist.Size); err != nil {
+			return nil, fmt.Errorf("failed to get distributions: %w", err)
+		}
+		ans[recordID] = append(ans[recordID], dist)
+		numRows++
+	}
+	done(numRows)
+	return ans, rows.Err()
+}
+
+// GetRelations returns typed relations of the provided records
+// to other resources
+func (c *CNCMySQLHandler) GetRelations(ctx context.Context, recordIDs []int) (map[int][]Relation, error) {
+	ans := make(map[int][]Relation)
+	if len(recordIDs) == 0 {
+		return ans, nil
+	}
+	placeholders, values := inClauseArgs(recordIDs)
+	query := fmt.Sprintf(
+		"SELECT r.metadata_id, r.relation_type, r.target FROM vlo_metadata_relation AS r "+
+			"WHERE r.metadata_id IN (%s) "+
+			"ORDER BY r.metadata_id, r.id",
+		placeholders,
+	)
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, values...)
+	rows, err := c.conn.QueryContext(ctx, query, values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relations: %w", err)
+	}
+	defer rows.Close()
+	var numRows int
+	for rows.Next() {
+		var recordID int
+		var rel Relation
+		if err := rows.Scan(&recordID, &rel.Type, &rel.Target); err != nil {
+			return nil, fmt.Errorf("failed to get relations: %w", err)
+		}
+		ans[recordID] = append(ans[recordID], rel)
+		numRows++
+	}
+	done(numRows)
+	return ans, rows.Err()
+}
+
+// GetFunding returns funding information of the provided records
+func (c *CNCMySQLHandler) GetFunding(ctx context.Context, recordIDs []int) (map[int][]Funding, error) {
+	ans := make(map[int][]Funding)
+	if len(recordIDs) == 0 {
+		return ans, nil
+	}
+	placeholders, values := inClauseArgs(recordIDs)
+	query := fmt.Sprintf(
+		"SELECT f.metadata_id, f.organization, COALESCE(f.code, ''), "+
+			"COALESCE(f.project_name, ''), COALESCE(f.funds_type, '') "+
+			"FROM vlo_metadata_funding AS f "+
+			"WHERE f.metadata_id IN (%s) "+
+			"ORDER BY f.metadata_id, f.id",
+		placeholders,
+	)
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, values...)
+	rows, err := c.conn.QueryContext(ctx, query, values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funding: %w", err)
+	}
+	defer rows.Close()
+	var numRows int
+	for rows.Next() {
+		var recordID int
+		var funding Funding
+		if err := rows.Scan(
+			&recordID, &funding.Organization, &funding.Code, &funding.ProjectName, &funding.FundsType,
+		); err != nil {
+			return nil, fmt.Errorf("failed to get funding: %w", err)
+		}
+		ans[recordID] = append(ans[recordID], funding)
+		numRows++
+	}
+	done(numRows)
+	return ans, rows.Err()
+}
+
+// GetRegistryAttrs returns tagsets and aligned corpora for
+// the specified corpora
+func (c *CNCMySQLHandler) GetRegistryAttrs(ctx context.Context, corpusNames []string) (map[string]RegistryAttrs, error) {
+	ans := make(map[string]RegistryAttrs)
+	if len(corpusNames) == 0 {
+		return ans, nil
+	}
+	placeholders, values := inClauseArgs(corpusNames)
+	tagsets, err := c.selectNameValues(
+		ctx,
+		fmt.Sprintf(
+			"SELECT ct.corpus_name, ct.tagset_name FROM vlo_corpus_tagset AS ct "+
+				"WHERE ct.corpus_name IN (%s) "+
+				"ORDER BY ct.corpus_name, ct.tagset_name",
+			placeholders,
+		),
+		values,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry attributes: %w", err)
+	}
+	aligned, err := c.selectNameValues(
+		ctx,
+		fmt.Sprintf(
+			"SELECT c.name, pc.name FROM %s AS c "+
+				"JOIN %s AS pc ON pc.parallel_corpus_id = c.parallel_corpus_id AND pc.name != c.name "+
+				"WHERE c.name IN (%s) "+
+				"ORDER BY c.name, pc.name",
+			c.overrides.CorporaTableName, c.overrides.CorporaTableName, placeholders,
+		),
+		values,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry attributes: %w", err)
+	}
+	for _, name := range corpusNames {
+		if len(tagsets[name]) > 0 || len(aligned[name]) > 0 {
+			ans[name] = RegistryAttrs{Tagsets: tagsets[name], AlignedCorpora: aligned[name]}
+		}
+	}
+	return ans, nil
+}
+
+// selectNameValues runs a query returning (name, value) rows
+// and groups the values by names
+func (c *CNCMySQLHandler) selectNameValues(ctx context.Context, query string, args []any) (map[string][]string, error) {
+	ctx, cancel := c.queryContext(ctx)
+	defer cancel()
+	done := c.logQuery(query, args...)
+	rows, err := c.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ans := make(map[string][]string)
+	var numRows int
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		ans[name] = append(ans[name], value)
+		numRows++
+	}
+	done(numRows)
+	return ans, rows.Err()
+}
+
+// inClauseArgs prepares placeholders and argument values
+// for the `IN (...)` SQL clause
+func inClauseArgs[T any](items []T) (string, []any) {
+	placeholders := make([]string, len(items))
+	values := make([]any, len(items))
+	for i, item := range items {
+		placeholders[i] = "?"
+		values[i] = item
+	}
+	return strings.Join(placeholders, ", "), values
+}
+
+// NewCNCMySQLHandler creates a DB handler. The `includeOrigin` enables
+// selecting of record origins (see CNCMySQLHandler).
+func NewCNCMySQLHandler(cnf DatabaseSetup, includeOrigin, debugQueries bool) (*CNCMySQLHandler, error) {
+	if cnf.PublicCorplistID <= 0 {
+		return nil, fmt.Errorf("invalid publicCorplistId %d", cnf.PublicCorplistID)
+	}
+	conf, err := mysqlConfig(cnf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CNC DB: %w", err)
+	}
+	db, err := sql.Open("mysql", conf.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CNC DB: %w", err)
+	}
+	applyPoolSetup(db, cnf)
+	ans := &CNCMySQLHandler{
+		conn:             db,
+		overrides:        cnf.Overrides,
+		publicCorplistID: cnf.PublicCorplistID,
+		includeOrigin:    includeOrigin,
+		queryTimeout:     time.Duration(cnf.QueryTimeoutSecs) * time.Second,
+		debugQueries:     debugQueries,
+
+		includeUndatedDeletions: cnf.IncludeUndatedDeletions,
+		hostedRecords:           cnf.HostedRecords,
+	}
+	if err := ans.checkCorplistExists(cnf.PublicCorplistID); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return ans, nil
+}
+
+// mysqlConfig creates a driver configuration. Date and time values
+// are parsed in the configured DB time zone so they can be converted
+// to UTC correctly.
+func mysqlConfig(cnf DatabaseSetup) (*mysql.Config, error) {
+	loc, err := cnf.Location()
+	if err != nil {
+		return nil, err
+	}
 	conf := mysql.NewConfig()
 	conf.Net = "tcp"
 	conf.Addr = cnf.Host
@@ -286,14 +1138,43 @@ func NewCNCMySQLHandler(cnf DatabaseSetup) (*CNCMySQLHandler, error) {
 	conf.Passwd = cnf.Passwd
 	conf.DBName = cnf.Name
 	conf.ParseTime = true
-	conf.Loc = time.Local
-	db, err := sql.Open("mysql", conf.FormatDSN())
-	if err != nil {
-		return nil, fmt.Errorf("failed to open CNC DB: %w", err)
+	conf.Loc = loc
+	return conf, nil
+}
+
+// Ping verifies the database is reachable
+func (c *CNCMySQLHandler) Ping(ctx context.Context) error {
+	if err := c.conn.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping CNC DB: %w", err)
 	}
-	return &CNCMySQLHandler{
-		conn:             db,
-		overrides:        cnf.Overrides,
-		publicCorplistID: cnf.PublicCorplistID,
-	}, nil
+	return nil
+}
+
+// applyPoolSetup configures the connection pool of db
+func applyPoolSetup(db *sql.DB, cnf DatabaseSetup) {
+	db.SetMaxOpenConns(cnf.MaxOpenConns)
+	db.SetMaxIdleConns(cnf.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cnf.ConnMaxLifetimeSecs) * time.Second)
+	log.Info().
+		Int("maxOpenConns", cnf.MaxOpenConns).
+		Int("maxIdleConns", cnf.MaxIdleConns).
+		Int("connMaxLifetimeSecs", cnf.ConnMaxLifetimeSecs).
+		Msg("configured CNC DB connection pool")
+}
+
+// checkCorplistExists verifies the configured public corplist
+// is present in the database. Otherwise, all the visibility
+// queries would silently return no records.
+func (c *CNCMySQLHandler) checkCorplistExists(corplistID int) error {
+	query := "SELECT COUNT(*) FROM corplist WHERE id = ?"
+	var count int
+	done := c.logQuery(query, corplistID)
+	if err := c.conn.QueryRow(query, corplistID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to check publicCorplistId: %w", err)
+	}
+	done(count)
+	if count == 0 {
+		return fmt.Errorf("configured publicCorplistId %d does not exist", corplistID)
+	}
+	return nil
 }
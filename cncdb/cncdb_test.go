@@ -17,8 +17,17 @@
 package cncdb
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/text/language"
 )
@@ -70,3 +79,263 @@ func TestParseLocaleBroken(t *testing.T) {
 	assert.Equal(t, language.Low, conf)
 	assert.Equal(t, "US", reg.String())
 }
+
+func TestListRecordsWhereExcludesDeleted(t *testing.T) {
+	h := CNCMySQLHandler{publicCorplistID: 1}
+	clauses, values := h.listRecordsWhere(nil, nil, SetFilter{}, false)
+	// deleted rows are excluded by a literal condition with no bound value
+	assert.Contains(t, clauses, "m.deleted = FALSE")
+	assert.Equal(t, []any{1, 1}, values)
+}
+
+func TestListRecordsWhereIncludesDeletedInWindow(t *testing.T) {
+	h := CNCMySQLHandler{publicCorplistID: 1}
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 3, 10, 23, 59, 59, 0, time.UTC)
+	clauses, values := h.listRecordsWhere(&from, &until, SetFilter{}, true)
+	assert.NotContains(t, clauses, "m.deleted = FALSE")
+	assert.Contains(t, clauses, "(m.deleted = FALSE OR m.deleted_date IS NOT NULL)")
+	assert.Contains(t, clauses, recordDatestampExpr+" >= ?")
+	assert.Contains(t, clauses, recordDatestampExpr+" <= ?")
+	assert.Equal(t, []any{1, 1, &from, &until}, values)
+}
+
+func TestDeletedCond(t *testing.T) {
+	var h CNCMySQLHandler
+	assert.Equal(t, "m.deleted = FALSE", h.deletedCond(false))
+	assert.Equal(t, "(m.deleted = FALSE OR m.deleted_date IS NOT NULL)", h.deletedCond(true))
+}
+
+func TestDeletedCondUndatedDeletions(t *testing.T) {
+	h := CNCMySQLHandler{includeUndatedDeletions: true}
+	assert.Equal(t, "m.deleted = FALSE", h.deletedCond(false))
+	assert.Equal(t, "TRUE", h.deletedCond(true))
+}
+
+func TestListRecordsWhereHostedRecords(t *testing.T) {
+	for hosted, cond := range map[string]string{
+		HostedRecordsHosted:   "m.hosted = TRUE",
+		HostedRecordsExternal: "m.hosted = FALSE",
+	} {
+		h := CNCMySQLHandler{publicCorplistID: 1, hostedRecords: hosted}
+		clauses, values := h.listRecordsWhere(nil, nil, SetFilter{}, false)
+		assert.Contains(t, clauses, cond)
+		assert.Equal(t, []any{1, 1}, values)
+		assert.Equal(t, "m.deleted = FALSE AND "+cond, h.recordCond(false))
+	}
+}
+
+func TestListRecordsWhereAllHostedRecords(t *testing.T) {
+	for _, hosted := range []string{"", HostedRecordsAll} {
+		h := CNCMySQLHandler{publicCorplistID: 1, hostedRecords: hosted}
+		clauses, _ := h.listRecordsWhere(nil, nil, SetFilter{}, false)
+		assert.Len(t, clauses, 2)
+		assert.NotContains(t, strings.Join(clauses, " "), "m.hosted")
+		assert.Equal(t, "m.deleted = FALSE", h.recordCond(false))
+	}
+}
+
+func TestRecordDatestampExprDeletionFallback(t *testing.T) {
+	// deletion time first, the last modification if unknown
+	assert.Equal(
+		t,
+		"IF(m.deleted, COALESCE(m.deleted_date, GREATEST(m.created, m.updated)), GREATEST(m.created, m.updated))",
+		recordDatestampExpr,
+	)
+}
+
+func TestListRecordsWhereNoDeletionDate(t *testing.T) {
+	// the `deleted_date` column is not required unless deleted records are tracked
+	h := CNCMySQLHandler{publicCorplistID: 1}
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	clauses, _ := h.listRecordsWhere(&from, nil, SetFilter{}, false)
+	assert.Contains(t, clauses, "GREATEST(m.created, m.updated) >= ?")
+	assert.NotContains(t, strings.Join(clauses, " "), "deleted_date")
+	assert.NotContains(t, datestampExpr(false), "deleted_date")
+	assert.Equal(t, recordDatestampExpr, datestampExpr(true))
+}
+
+func TestListRecordsWhereKeyword(t *testing.T) {
+	h := CNCMySQLHandler{publicCorplistID: 1}
+	clauses, values := h.listRecordsWhere(nil, nil, SetFilter{Keyword: "fiction"}, false)
+	assert.Contains(
+		t,
+		clauses,
+		"EXISTS (SELECT 1 FROM kontext_keyword_corpus AS kf WHERE kf.corpus_name = c.name AND kf.keyword_id = ?)",
+	)
+	assert.Equal(t, []any{1, 1, "fiction"}, values)
+}
+
+func TestListRecordsWhereCorplist(t *testing.T) {
+	h := CNCMySQLHandler{publicCorplistID: 1}
+	clauses, values := h.listRecordsWhere(nil, nil, SetFilter{CorplistID: 7}, false)
+	assert.Contains(
+		t,
+		clauses,
+		"(EXISTS (SELECT 1 FROM corplist_corpus AS ccf WHERE ccf.corpus_id = c.id AND ccf.corplist_id = ?) OR "+
+			"EXISTS (SELECT 1 FROM corplist_parallel_corpus AS cpcf "+
+			"WHERE cpcf.parallel_corpus_id = c.parallel_corpus_id AND cpcf.corplist_id = ?))",
+	)
+	assert.Equal(t, []any{1, 1, 7, 7}, values)
+}
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buff bytes.Buffer
+	origLogger := log.Logger
+	log.Logger = zerolog.New(&buff).Level(zerolog.DebugLevel)
+	t.Cleanup(func() { log.Logger = origLogger })
+	return &buff
+}
+
+func TestQueryLoggingOffByDefault(t *testing.T) {
+	buff := captureLog(t)
+	var h CNCMySQLHandler
+	h.logQuery("SELECT 1", 42)(1)
+	assert.Empty(t, buff.String())
+}
+
+func TestQueryLoggingDebug(t *testing.T) {
+	buff := captureLog(t)
+	h := CNCMySQLHandler{debugQueries: true}
+	h.logQuery("SELECT ?", 42)(1)
+	assert.Contains(t, buff.String(), `"query":"SELECT ?"`)
+	assert.Contains(t, buff.String(), `"args":[42]`)
+	assert.Contains(t, buff.String(), `"numRows":1`)
+}
+
+func TestQueryContextTimeout(t *testing.T) {
+	h := CNCMySQLHandler{queryTimeout: 5 * time.Second}
+	ctx, cancel := h.queryContext(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(5*time.Second), deadline, time.Second)
+}
+
+func TestQueryContextNoTimeout(t *testing.T) {
+	var h CNCMySQLHandler
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := h.queryContext(parent)
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+	cancelParent()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+// readDatestamp emulates reading a DB value by the driver
+// configured via a DSN
+func readDatestamp(t *testing.T, setup DatabaseSetup, value string) time.Time {
+	conf, err := mysqlConfig(setup)
+	assert.NoError(t, err)
+	parsed, err := mysql.ParseDSN(conf.FormatDSN())
+	assert.NoError(t, err)
+	ans, err := time.ParseInLocation(time.DateTime, value, parsed.Loc)
+	assert.NoError(t, err)
+	return ans.UTC()
+}
+
+func TestUTCStoredDatestampInPragueApp(t *testing.T) {
+	prague, err := time.LoadLocation("Europe/Prague")
+	assert.NoError(t, err)
+	origLocal := time.Local
+	time.Local = prague
+	t.Cleanup(func() { time.Local = origLocal })
+
+	expected := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, expected, readDatestamp(t, DatabaseSetup{TimeZone: "UTC"}, "2024-03-10 12:00:00"))
+	// without the DB time zone, the value is considered Prague local time
+	assert.Equal(t, expected.Add(-time.Hour), readDatestamp(t, DatabaseSetup{}, "2024-03-10 12:00:00"))
+}
+
+func TestDatabaseSetupInvalidTimeZone(t *testing.T) {
+	_, err := DatabaseSetup{TimeZone: "Mars/Olympus"}.Location()
+	assert.Error(t, err)
+}
+
+func TestApplyPoolSetup(t *testing.T) {
+	buff := captureLog(t)
+	// no connection is established until the first query
+	db, err := sql.Open("mysql", "user@tcp(127.0.0.1:1)/test")
+	assert.NoError(t, err)
+	defer db.Close()
+	applyPoolSetup(db, DatabaseSetup{MaxOpenConns: 7, MaxIdleConns: 3, ConnMaxLifetimeSecs: 60})
+	// sql.DB exposes only the max. number of open connections
+	assert.Equal(t, 7, db.Stats().MaxOpenConnections)
+	assert.Contains(t, buff.String(), `"maxIdleConns":3`)
+	assert.Contains(t, buff.String(), `"connMaxLifetimeSecs":60`)
+}
+
+func TestDatestampFromDBZeroDate(t *testing.T) {
+	buff := captureLog(t)
+	// with ParseTime, `0000-00-00 00:00:00` is scanned as a zero time
+	date := datestampFromDB(42, sql.NullTime{Valid: true})
+	assert.True(t, date.IsZero())
+	assert.Contains(t, buff.String(), `"id":42`)
+}
+
+func TestDatestampFromDBNull(t *testing.T) {
+	captureLog(t)
+	date := datestampFromDB(42, sql.NullTime{})
+	assert.True(t, date.IsZero())
+}
+
+func TestDatestampFromDBValid(t *testing.T) {
+	buff := captureLog(t)
+	value := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	date := datestampFromDB(42, sql.NullTime{Time: value, Valid: true})
+	assert.Equal(t, value, date)
+	assert.Empty(t, buff.String())
+}
+
+func TestVisibleRecordsQueryFilter(t *testing.T) {
+	h := CNCMySQLHandler{publicCorplistID: 3, overrides: DBOverrides{CorporaTableName: "kontext_corpus"}}
+	query, args := h.visibleRecordsQuery("MAX(GREATEST(m.created, m.updated))", nil, nil, SetFilter{}, false)
+	assert.Contains(t, query, "SELECT MAX(GREATEST(m.created, m.updated)) FROM vlo_metadata_common AS m ")
+	assert.Contains(t, query, "LEFT JOIN kontext_corpus AS c ON mc.corpus_name = c.name ")
+	assert.Contains(
+		t,
+		query,
+		" WHERE ((m.type = 'corpus' AND cc.corplist_id = ?) OR cpc.corplist_id = ? OR m.type != 'corpus') AND m.deleted = FALSE",
+	)
+	assert.Equal(t, []any{3, 3}, args)
+}
+
+func TestVisibleRecordsQueryHostedOnly(t *testing.T) {
+	h := CNCMySQLHandler{
+		publicCorplistID: 3,
+		overrides:        DBOverrides{CorporaTableName: "kontext_corpus"},
+		hostedRecords:    HostedRecordsHosted,
+	}
+	query, args := h.visibleRecordsQuery("COUNT(DISTINCT m.id)", nil, nil, SetFilter{}, false)
+	assert.True(t, strings.HasSuffix(query, " AND m.deleted = FALSE AND m.hosted = TRUE"))
+	assert.Equal(t, []any{3, 3}, args)
+}
+
+func TestNewHandlerInvalidCorplistID(t *testing.T) {
+	for _, id := range []int{0, -1} {
+		h, err := NewCNCMySQLHandler(DatabaseSetup{PublicCorplistID: id}, false, false)
+		assert.Nil(t, h)
+		assert.EqualError(t, err, fmt.Sprintf("invalid publicCorplistId %d", id))
+	}
+}
+
+func TestNewHandlerFailsFastWithoutDB(t *testing.T) {
+	h, err := NewCNCMySQLHandler(DatabaseSetup{Host: "127.0.0.1:1", PublicCorplistID: 99}, false, false)
+	assert.Nil(t, h)
+	assert.ErrorContains(t, err, "failed to check publicCorplistId")
+}
+
+func TestAffiliationENExpr(t *testing.T) {
+	var h CNCMySQLHandler
+	assert.Equal(t, "NULL", h.affiliationENExpr())
+	h.overrides.UserTableAffiliationENCol = "affiliation_en"
+	assert.Equal(t, "u.affiliation_en", h.affiliationENExpr())
+}
+
+func TestOriginExpr(t *testing.T) {
+	var h CNCMySQLHandler
+	assert.Equal(t, "NULL, NULL, NULL, NULL", h.originExpr())
+	h.includeOrigin = true
+	assert.Equal(t, "m.origin_base_url, m.origin_identifier, m.origin_datestamp, m.origin_synced", h.originExpr())
+}
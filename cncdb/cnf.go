@@ -16,6 +16,17 @@
 
 package cncdb
 
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	HostedRecordsAll      = "all"
+	HostedRecordsHosted   = "hosted"
+	HostedRecordsExternal = "external"
+)
+
 type DatabaseSetup struct {
 	Host             string      `json:"host"`
 	User             string      `json:"user"`
@@ -23,4 +34,45 @@ type DatabaseSetup struct {
 	Name             string      `json:"db"`
 	Overrides        DBOverrides `json:"overrides"`
 	PublicCorplistID int         `json:"publicCorplistId"`
+
+	// TimeZone is a time zone DB date and time values are interpreted
+	// in (i.e. the session time zone of the server for TIMESTAMP columns
+	// and the zone DATETIME values are written in). If empty, the local
+	// time zone of the application is used. Please note that this is
+	// independent of the application's own time zone configuration.
+	TimeZone string `json:"timeZone"`
+
+	// QueryTimeoutSecs limits duration of record queries
+	// (zero means no limit besides the client disconnecting)
+	QueryTimeoutSecs int `json:"queryTimeoutSecs"`
+
+	// IncludeUndatedDeletions exposes also deleted records with
+	// an unknown deletion time (e.g. deleted before the deletion
+	// tracking trigger was installed) using their last modification
+	// as the datestamp. Please note that such records cannot be
+	// distinguished from drafts created as deleted.
+	IncludeUndatedDeletions bool `json:"includeUndatedDeletions"`
+
+	// HostedRecords selects records by the `hosted` flag: `all`
+	// (default), `hosted` (only resources hosted by CNC) or `external`
+	// (only externally hosted ones)
+	HostedRecords string `json:"hostedRecords"`
+
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetimeSecs configure
+	// the connection pool (see the respective sql.DB setters)
+	MaxOpenConns        int `json:"maxOpenConns"`
+	MaxIdleConns        int `json:"maxIdleConns"`
+	ConnMaxLifetimeSecs int `json:"connMaxLifetimeSecs"`
+}
+
+// Location returns the time zone DB values are interpreted in
+func (setup DatabaseSetup) Location() (*time.Location, error) {
+	if setup.TimeZone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(setup.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB time zone: %w", err)
+	}
+	return loc, nil
 }
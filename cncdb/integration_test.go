@@ -0,0 +1,510 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cncdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+// envTestDBDSN specifies a MySQL database (as a go-sql-driver DSN,
+// e.g. `user:passwd@tcp(localhost:3306)/vlo_test`) used by
+// integration tests. Tests create (and drop) tables with production
+// names there so the database must be dedicated to testing.
+// If not set, integration tests are skipped.
+const envTestDBDSN = "CNC_VLO_TEST_DB_DSN"
+
+const (
+	testPublicCorplistID = 1
+	testContactUserID    = 1
+)
+
+// kontextTestSchema creates minimal versions of the KonText tables
+// which the VLO schema (scripts/schema.sql) and queries depend on
+var kontextTestSchema = []string{
+	"CREATE TABLE kontext_user (id INT PRIMARY KEY NOT NULL AUTO_INCREMENT, " +
+		"firstname VARCHAR(255) NOT NULL, lastname VARCHAR(255) NOT NULL, " +
+		"email VARCHAR(255) NOT NULL, affiliation VARCHAR(255))",
+	"CREATE TABLE kontext_corpus (id INT PRIMARY KEY NOT NULL AUTO_INCREMENT, " +
+		"name VARCHAR(63) NOT NULL UNIQUE, size BIGINT, web VARCHAR(255), " +
+		"locale VARCHAR(31), parallel_corpus_id INT)",
+	"CREATE TABLE kontext_keyword (id VARCHAR(63) PRIMARY KEY NOT NULL, " +
+		"label_en VARCHAR(255) NOT NULL, display_order INT)",
+	"CREATE TABLE kontext_keyword_corpus (corpus_name VARCHAR(63) NOT NULL, " +
+		"keyword_id VARCHAR(63) NOT NULL)",
+	"CREATE TABLE corplist (id INT PRIMARY KEY NOT NULL AUTO_INCREMENT, name VARCHAR(255) NOT NULL)",
+	"CREATE TABLE corplist_corpus (corplist_id INT NOT NULL, corpus_id INT NOT NULL)",
+	"CREATE TABLE corplist_parallel_corpus (corplist_id INT NOT NULL, parallel_corpus_id INT NOT NULL)",
+	"CREATE TABLE registry_conf (id INT PRIMARY KEY NOT NULL AUTO_INCREMENT, " +
+		"corpus_name VARCHAR(63) NOT NULL, name VARCHAR(255))",
+}
+
+var createTableRegexp = regexp.MustCompile(`(?i)^CREATE TABLE (?:IF NOT EXISTS )?(\w+)`)
+
+// testRecord describes a record inserted by insertTestRecord.
+// Records without a corpus are service records.
+type testRecord struct {
+	id          int
+	corpus      string
+	created     string
+	updated     string
+	deleted     bool
+	deletedDate string
+	hosted      bool
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv(envTestDBDSN)
+	if dsn == "" {
+		t.Skipf("%s not set, skipping DB integration test", envTestDBDSN)
+	}
+	conf, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("invalid %s: %s", envTestDBDSN, err)
+	}
+	conf.ParseTime = true
+	db, err := sql.Open("mysql", conf.FormatDSN())
+	if err != nil {
+		t.Fatalf("failed to open test DB: %s", err)
+	}
+	// a single connection makes session settings (e.g. sql_mode)
+	// apply to all the statements of a test
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("failed to connect to test DB: %s", err)
+	}
+	return db
+}
+
+// createTestSchema creates the KonText tables (see kontextTestSchema)
+// and the VLO tables from scripts/schema.sql. All the tables are
+// dropped when the test finishes.
+func createTestSchema(t *testing.T, db *sql.DB) {
+	src, err := os.ReadFile("scripts/schema.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %s", err)
+	}
+	stmts := append([]string{}, kontextTestSchema...)
+	for _, stmt := range strings.Split(string(src), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	tables := make([]string, 0, len(stmts))
+	for _, stmt := range stmts {
+		if m := createTableRegexp.FindStringSubmatch(stmt); m != nil {
+			tables = append(tables, m[1])
+		}
+	}
+	// tables may be left over by an interrupted test run
+	dropTestTables(db, tables)
+	t.Cleanup(func() { dropTestTables(db, tables) })
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to create test schema: %s", err)
+		}
+	}
+}
+
+// dropTestTables drops tables in the reverse order of their
+// creation so foreign keys are respected
+func dropTestTables(db *sql.DB, tables []string) {
+	for i := len(tables) - 1; i >= 0; i-- {
+		db.Exec("DROP TABLE IF EXISTS " + tables[i])
+	}
+}
+
+// newTestHandler creates a handler over a fresh test schema with
+// a contact person and a public corplist (testPublicCorplistID)
+func newTestHandler(t *testing.T) (*CNCMySQLHandler, *sql.DB) {
+	db := openTestDB(t)
+	createTestSchema(t, db)
+	execTestSQL(
+		t, db,
+		"INSERT INTO kontext_user (id, firstname, lastname, email, affiliation) "+
+			"VALUES (?, 'Jan', 'Novák', 'novak@example.com', 'ÚČNK')",
+		testContactUserID,
+	)
+	execTestSQL(
+		t, db,
+		"INSERT INTO corplist (id, name) VALUES (?, 'public'), (?, 'Korpusy ČNK')",
+		testPublicCorplistID, testPublicCorplistID+1,
+	)
+	handler := &CNCMySQLHandler{
+		conn: db,
+		overrides: DBOverrides{
+			CorporaTableName:      "kontext_corpus",
+			UserTableName:         "kontext_user",
+			UserTableFirstNameCol: "firstname",
+			UserTableLastNameCol:  "lastname",
+		},
+		publicCorplistID: testPublicCorplistID,
+	}
+	return handler, db
+}
+
+func execTestSQL(t *testing.T, db *sql.DB, query string, args ...any) {
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("failed to prepare test data: %s", err)
+	}
+}
+
+// insertTestCorpus adds a standalone corpus and includes it
+// in the provided corplists
+func insertTestCorpus(t *testing.T, db *sql.DB, id int, name string, corplistIDs ...int) {
+	execTestSQL(
+		t, db,
+		"INSERT INTO kontext_corpus (id, name, size, web, locale) VALUES (?, ?, 1000, ?, 'cs_CZ.UTF-8')",
+		id, name, "https://www.korpus.cz/"+name,
+	)
+	for _, corplistID := range corplistIDs {
+		execTestSQL(t, db, "INSERT INTO corplist_corpus (corplist_id, corpus_id) VALUES (?, ?)", corplistID, id)
+	}
+}
+
+// insertTestRecord adds a metadata record. Corpus records
+// expect the corpus to be already inserted (see insertTestCorpus).
+func insertTestRecord(t *testing.T, db *sql.DB, rec testRecord) {
+	recType := "service"
+	var corpusID, serviceID any
+	if rec.corpus != "" {
+		recType = "corpus"
+		corpusID = rec.id
+		execTestSQL(t, db, "INSERT INTO vlo_metadata_corpus (id, corpus_name) VALUES (?, ?)", rec.id, rec.corpus)
+	} else {
+		serviceID = rec.id
+		execTestSQL(
+			t, db,
+			"INSERT INTO vlo_metadata_service (id, name, link) VALUES (?, ?, 'https://www.korpus.cz/')",
+			rec.id, fmt.Sprintf("service%d", rec.id),
+		)
+	}
+	var deletedDate any
+	if rec.deletedDate != "" {
+		deletedDate = rec.deletedDate
+	}
+	execTestSQL(
+		t, db,
+		"INSERT INTO vlo_metadata_common (id, created, updated, deleted, deleted_date, hosted, type, "+
+			"date_issued, license_info, contact_user_id, authors, corpus_metadata_id, service_metadata_id) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, '2020', 'CC BY 4.0', ?, 'Novák, Jan', ?, ?)",
+		rec.id, rec.created, rec.updated, rec.deleted, deletedDate, rec.hosted, recType,
+		testContactUserID, corpusID, serviceID,
+	)
+}
+
+func recordIDs(records []DBData) []int {
+	ans := make([]int, len(records))
+	for i, rec := range records {
+		ans[i] = rec.ID
+	}
+	return ans
+}
+
+func TestGetParallelLocalesDB(t *testing.T) {
+	handler, db := newTestHandler(t)
+	execTestSQL(
+		t, db,
+		"INSERT INTO kontext_corpus (id, name, parallel_corpus_id, locale) VALUES "+
+			"(1, 'intercorp_cs', 10, 'cs_CZ.UTF-8'), "+
+			"(2, 'intercorp_en', 10, 'en_US.UTF-8'), "+
+			"(3, 'syn2020', NULL, 'cs_CZ.UTF-8')",
+	)
+	locales, err := handler.GetParallelLocales(
+		context.Background(), []string{"intercorp_cs", "intercorp_en", "syn2020"})
+	assert.NoError(t, err)
+	expected := []language.Tag{language.MustParse("cs-CZ"), language.MustParse("en-US")}
+	assert.Equal(t, expected, locales["intercorp_cs"])
+	assert.Equal(t, expected, locales["intercorp_en"])
+	assert.NotContains(t, locales, "syn2020")
+}
+
+func TestGetRelationsDB(t *testing.T) {
+	handler, db := newTestHandler(t)
+	insertTestCorpus(t, db, 1, "syn2020", testPublicCorplistID)
+	insertTestRecord(t, db, testRecord{id: 1, corpus: "syn2020", created: "2024-01-10 10:00:00", updated: "2024-01-10 10:00:00"})
+	insertTestRecord(t, db, testRecord{id: 2, created: "2024-01-10 10:00:00", updated: "2024-01-10 10:00:00"})
+	execTestSQL(
+		t, db,
+		"INSERT INTO vlo_metadata_relation (metadata_id, relation_type, target) VALUES "+
+			"(1, 'isVersionOf', 'https://www.korpus.cz/syn2015'), "+
+			"(1, 'references', 'https://doi.org/10.1234/syn'), "+
+			"(2, 'isPartOf', 'https://www.korpus.cz/kontext')",
+	)
+	relations, err := handler.GetRelations(context.Background(), []int{1, 3})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		map[int][]Relation{1: {
+			{Type: "isVersionOf", Target: "https://www.korpus.cz/syn2015"},
+			{Type: "references", Target: "https://doi.org/10.1234/syn"},
+		}},
+		relations,
+	)
+
+	// relations are removed along with their record
+	execTestSQL(t, db, "DELETE FROM vlo_metadata_common WHERE id = 1")
+	relations, err = handler.GetRelations(context.Background(), []int{1})
+	assert.NoError(t, err)
+	assert.Empty(t, relations)
+}
+
+func TestGetFundingDB(t *testing.T) {
+	handler, db := newTestHandler(t)
+	insertTestCorpus(t, db, 1, "syn2020", testPublicCorplistID)
+	insertTestRecord(t, db, testRecord{id: 1, corpus: "syn2020", created: "2024-01-10 10:00:00", updated: "2024-01-10 10:00:00"})
+	execTestSQL(
+		t, db,
+		"INSERT INTO vlo_metadata_funding (metadata_id, organization, code, project_name, funds_type) VALUES "+
+			"(1, 'Ministry of Education, Youth and Sports', 'LM2023044', 'Czech National Corpus', 'infrastructure'), "+
+			"(1, 'Charles University', NULL, NULL, NULL)",
+	)
+	funding, err := handler.GetFunding(context.Background(), []int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		map[int][]Funding{1: {
+			{
+				Organization: "Ministry of Education, Youth and Sports",
+				Code:         "LM2023044",
+				ProjectName:  "Czech National Corpus",
+				FundsType:    "infrastructure",
+			},
+			{Organization: "Charles University"},
+		}},
+		funding,
+	)
+}
+
+func TestGetRegistryAttrsDB(t *testing.T) {
+	handler, db := newTestHandler(t)
+	execTestSQL(
+		t, db,
+		"INSERT INTO kontext_corpus (id, name, parallel_corpus_id, locale) VALUES "+
+			"(1, 'intercorp_cs', 10, 'cs_CZ.UTF-8'), "+
+			"(2, 'intercorp_en', 10, 'en_US.UTF-8'), "+
+			"(3, 'syn2020', NULL, 'cs_CZ.UTF-8'), "+
+			"(4, 'oral', NULL, 'cs_CZ.UTF-8')",
+	)
+	execTestSQL(
+		t, db,
+		"INSERT INTO vlo_corpus_tagset (corpus_name, tagset_name) VALUES "+
+			"('syn2020', 'pdt'), ('syn2020', 'ud'), ('intercorp_en', 'penn')",
+	)
+	attrs, err := handler.GetRegistryAttrs(
+		context.Background(), []string{"intercorp_cs", "intercorp_en", "syn2020", "oral"})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		map[string]RegistryAttrs{
+			"intercorp_cs": {AlignedCorpora: []string{"intercorp_en"}},
+			"intercorp_en": {Tagsets: []string{"penn"}, AlignedCorpora: []string{"intercorp_cs"}},
+			"syn2020":      {Tagsets: []string{"pdt", "ud"}},
+		},
+		attrs,
+	)
+}
+
+func TestZeroDatestampDB(t *testing.T) {
+	handler, db := newTestHandler(t)
+	insertTestCorpus(t, db, 1, "syn2020", testPublicCorplistID)
+	// zero dates are rejected in the strict SQL mode
+	execTestSQL(t, db, "SET SESSION sql_mode = ''")
+	insertTestRecord(t, db, testRecord{id: 1, corpus: "syn2020", created: "0000-00-00 00:00:00", updated: "0000-00-00 00:00:00"})
+	insertTestRecord(t, db, testRecord{id: 2, created: "2024-01-10 10:00:00", updated: "2024-01-10 10:00:00"})
+
+	record, err := handler.GetRecordInfo(context.Background(), "1", false)
+	assert.NoError(t, err)
+	if assert.NotNil(t, record) {
+		assert.True(t, record.Date.IsZero())
+	}
+	records, err := handler.ListRecordInfo(context.Background(), nil, nil, SetFilter{}, false, nil, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, 1, records[0].ID)
+		assert.True(t, records[0].Date.IsZero())
+		assert.Equal(t, 2, records[1].ID)
+	}
+}
+
+func TestDeletedRecordsInHarvestWindowDB(t *testing.T) {
+	handler, db := newTestHandler(t)
+	insertTestCorpus(t, db, 1, "syn2020", testPublicCorplistID)
+	insertTestRecord(t, db, testRecord{id: 1, corpus: "syn2020", created: "2024-01-05 10:00:00", updated: "2024-01-05 10:00:00"})
+	// published before the window, deleted inside it
+	insertTestRecord(t, db, testRecord{
+		id: 2, created: "2023-06-01 10:00:00", updated: "2023-06-01 10:00:00",
+		deleted: true, deletedDate: "2024-01-10 10:00:00",
+	})
+	// created as deleted (a draft)
+	insertTestRecord(t, db, testRecord{id: 3, created: "2024-01-08 10:00:00", updated: "2024-01-08 10:00:00", deleted: true})
+	// deleted before the window
+	insertTestRecord(t, db, testRecord{
+		id: 4, created: "2023-06-01 10:00:00", updated: "2023-06-01 10:00:00",
+		deleted: true, deletedDate: "2023-12-01 10:00:00",
+	})
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	records, err := handler.ListRecordInfo(ctx, &from, &until, SetFilter{}, true, nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, recordIDs(records))
+	if len(records) == 2 {
+		assert.True(t, records[1].Deleted)
+		assert.WithinDuration(t, time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC), records[1].Date, 0)
+	}
+	count, err := handler.CountRecords(ctx, &from, &until, SetFilter{}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	records, err = handler.ListRecordInfo(ctx, &from, &until, SetFilter{}, false, nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, recordIDs(records))
+
+	record, err := handler.GetRecordInfo(ctx, "2", true)
+	assert.NoError(t, err)
+	assert.NotNil(t, record)
+	record, err = handler.GetRecordInfo(ctx, "3", true)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	handler.includeUndatedDeletions = true
+	records, err = handler.ListRecordInfo(ctx, &from, &until, SetFilter{}, true, nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 3, 2}, recordIDs(records))
+}
+
+func TestKeywordSetsDB(t *testing.T) {
+	handler, db := newTestHandler(t)
+	insertTestCorpus(t, db, 1, "syn2020", testPublicCorplistID)
+	insertTestCorpus(t, db, 2, "oral", testPublicCorplistID)
+	insertTestCorpus(t, db, 3, "intercorp_cs", testPublicCorplistID)
+	execTestSQL(
+		t, db,
+		"INSERT INTO kontext_keyword (id, label_en, display_order) VALUES "+
+			"('written', 'Written', 1), ('spoken', 'Spoken', 2)",
+	)
+	execTestSQL(
+		t, db,
+		"INSERT INTO kontext_keyword_corpus (corpus_name, keyword_id) VALUES "+
+			"('syn2020', 'written'), ('oral', 'spoken')",
+	)
+	for i, corpus := range []string{"syn2020", "oral", "intercorp_cs"} {
+		insertTestRecord(t, db, testRecord{id: i + 1, corpus: corpus, created: "2024-01-10 10:00:00", updated: "2024-01-10 10:00:00"})
+	}
+	insertTestRecord(t, db, testRecord{id: 4, created: "2024-01-10 10:00:00", updated: "2024-01-10 10:00:00"})
+	ctx := context.Background()
+
+	records, err := handler.ListRecordInfo(ctx, nil, nil, SetFilter{Keyword: "spoken"}, false, nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2}, recordIDs(records))
+	count, err := handler.CountRecords(ctx, nil, nil, SetFilter{Keyword: "spoken"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	records, err = handler.ListRecordInfo(ctx, nil, nil, SetFilter{AnyKeyword: true}, false, nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, recordIDs(records))
+
+	records, err = handler.ListRecordInfo(ctx, nil, nil, SetFilter{Keyword: "unknown"}, false, nil, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	keywords, err := handler.ListKeywords(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []Keyword{{ID: "written", LabelEN: "Written"}, {ID: "spoken", LabelEN: "Spoken"}}, keywords)
+}
+
+func TestCheckCorplistExistsDB(t *testing.T) {
+	handler, _ := newTestHandler(t)
+	assert.NoError(t, handler.checkCorplistExists(testPublicCorplistID))
+	err := handler.checkCorplistExists(999)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "publicCorplistId 999 does not exist")
+	}
+}
+
+func TestHostedRecordsDB(t *testing.T) {
+	handler, db := newTestHandler(t)
+	insertTestCorpus(t, db, 1, "syn2020", testPublicCorplistID)
+	insertTestRecord(t, db, testRecord{id: 1, corpus: "syn2020", created: "2024-01-10 10:00:00", updated: "2024-01-10 10:00:00", hosted: true})
+	insertTestRecord(t, db, testRecord{id: 2, created: "2024-01-10 10:00:00", updated: "2024-01-10 10:00:00"})
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		hostedRecords string
+		expected      []int
+	}{
+		{HostedRecordsAll, []int{1, 2}},
+		{HostedRecordsHosted, []int{1}},
+		{HostedRecordsExternal, []int{2}},
+	} {
+		handler.hostedRecords = tc.hostedRecords
+		records, err := handler.ListRecordInfo(ctx, nil, nil, SetFilter{}, false, nil, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, recordIDs(records), tc.hostedRecords)
+		count, err := handler.CountRecords(ctx, nil, nil, SetFilter{}, false)
+		assert.NoError(t, err)
+		assert.Equal(t, len(tc.expected), count, tc.hostedRecords)
+	}
+
+	handler.hostedRecords = HostedRecordsHosted
+	record, err := handler.GetRecordInfo(ctx, "2", false)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+}
+
+func TestListRecordInfoKeysetPagingDB(t *testing.T) {
+	handler, db := newTestHandler(t)
+	for id, date := range map[int]string{
+		1: "2024-01-02 10:00:00",
+		2: "2024-01-02 10:00:00",
+		3: "2023-12-31 10:00:00",
+		4: "2024-01-02 10:00:00",
+		5: "2024-01-03 10:00:00",
+	} {
+		insertTestRecord(t, db, testRecord{id: id, created: date, updated: date})
+	}
+	ctx := context.Background()
+
+	var pages [][]int
+	var after *RecordCursor
+	for i := 0; i < 5; i++ {
+		records, err := handler.ListRecordInfo(ctx, nil, nil, SetFilter{}, false, after, 2)
+		assert.NoError(t, err)
+		if len(records) == 0 {
+			break
+		}
+		pages = append(pages, recordIDs(records))
+		last := records[len(records)-1]
+		after = &RecordCursor{Datestamp: last.Date, ID: last.ID}
+	}
+	// records sharing a datestamp are split by IDs
+	assert.Equal(t, [][]int{{3, 1}, {2, 4}, {5}}, pages)
+}
@@ -1,6 +1,8 @@
 CREATE TABLE vlo_metadata_corpus (
   id int(11) PRIMARY KEY NOT NULL AUTO_INCREMENT,
   corpus_name varchar(63) NOT NULL,
+  time_periods varchar(255),
+  places varchar(255),
   CONSTRAINT vlo_metadata_corpus_corpus_name_fk FOREIGN KEY (corpus_name) REFERENCES kontext_corpus(name) ON DELETE CASCADE ON UPDATE CASCADE,
   UNIQUE (corpus_name)
 ) ENGINE=InnoDB DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
@@ -17,17 +19,60 @@ CREATE TABLE vlo_metadata_common (
   created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   updated TIMESTAMP NOT NULL ON UPDATE CURRENT_TIMESTAMP,
   deleted TINYINT(1) DEFAULT 0,
+  deleted_date DATETIME,
   hosted TINYINT(1) DEFAULT 0,
   type ENUM('corpus', 'service') NOT NULL,
   desc_en TEXT,
   desc_cs TEXT,
   date_issued VARCHAR(255) NOT NULL,
+  date_available VARCHAR(255),
   license_info VARCHAR(255) NOT NULL,
   contact_user_id INT(11) NOT NULL,
   authors TEXT NOT NULL,
   corpus_metadata_id INT,
   service_metadata_id INT,
+  source_metadata_id INT,
+  pid VARCHAR(255),
+  origin_base_url VARCHAR(255),
+  origin_identifier VARCHAR(255),
+  origin_datestamp DATETIME,
+  origin_synced DATETIME,
   CONSTRAINT vlo_metadata_common_contact_user_id_fk FOREIGN KEY (contact_user_id) REFERENCES kontext_user(id) ON DELETE RESTRICT ON UPDATE RESTRICT,
   CONSTRAINT vlo_metadata_common_corpus_metadata_id_fk FOREIGN KEY (corpus_metadata_id) REFERENCES vlo_metadata_corpus(id) ON DELETE CASCADE ON UPDATE RESTRICT,
-  CONSTRAINT vlo_metadata_common_service_metadata_id_fk FOREIGN KEY (service_metadata_id) REFERENCES vlo_metadata_service(id) ON DELETE CASCADE ON UPDATE RESTRICT
-) ENGINE=InnoDB DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
\ No newline at end of file
+  CONSTRAINT vlo_metadata_common_service_metadata_id_fk FOREIGN KEY (service_metadata_id) REFERENCES vlo_metadata_service(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+  CONSTRAINT vlo_metadata_common_source_metadata_id_fk FOREIGN KEY (source_metadata_id) REFERENCES vlo_metadata_common(id) ON DELETE SET NULL ON UPDATE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE vlo_metadata_distribution (
+  id int(11) PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  metadata_id INT NOT NULL,
+  url VARCHAR(255) NOT NULL,
+  mime_type VARCHAR(127) NOT NULL,
+  size BIGINT,
+  CONSTRAINT vlo_metadata_distribution_metadata_id_fk FOREIGN KEY (metadata_id) REFERENCES vlo_metadata_common(id) ON DELETE CASCADE ON UPDATE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE vlo_metadata_relation (
+  id int(11) PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  metadata_id INT NOT NULL,
+  relation_type VARCHAR(63) NOT NULL,
+  target VARCHAR(255) NOT NULL,
+  CONSTRAINT vlo_metadata_relation_metadata_id_fk FOREIGN KEY (metadata_id) REFERENCES vlo_metadata_common(id) ON DELETE CASCADE ON UPDATE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE vlo_metadata_funding (
+  id int(11) PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  metadata_id INT NOT NULL,
+  organization VARCHAR(255) NOT NULL,
+  code VARCHAR(127),
+  project_name VARCHAR(255),
+  funds_type VARCHAR(127),
+  CONSTRAINT vlo_metadata_funding_metadata_id_fk FOREIGN KEY (metadata_id) REFERENCES vlo_metadata_common(id) ON DELETE CASCADE ON UPDATE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+CREATE TABLE vlo_corpus_tagset (
+  corpus_name varchar(63) NOT NULL,
+  tagset_name VARCHAR(63) NOT NULL,
+  PRIMARY KEY (corpus_name, tagset_name),
+  CONSTRAINT vlo_corpus_tagset_corpus_name_fk FOREIGN KEY (corpus_name) REFERENCES kontext_corpus(name) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
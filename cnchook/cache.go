@@ -0,0 +1,133 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// cachedValue holds a value obtained via the `load` function
+// for a specified time. It is safe for concurrent use. A failed load
+// (e.g. due to a timeout) is not cached.
+//
+// Concurrent readers of an expired value share a single load which
+// runs without holding the lock and independently of readers'
+// contexts - a reader's context limits only its own waiting.
+// The data source is expected to limit the load duration itself
+// (e.g. by the DB query timeout).
+type cachedValue[T any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	load    func(ctx context.Context) (T, error)
+	value   T
+	expires time.Time
+	loading *cachedLoad[T]
+}
+
+// cachedLoad is a load of a value shared by concurrent readers
+type cachedLoad[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+func (cv *cachedValue[T]) Get(ctx context.Context) (T, error) {
+	cv.mu.Lock()
+	if time.Now().Before(cv.expires) {
+		value := cv.value
+		cv.mu.Unlock()
+		return value, nil
+	}
+	call := cv.loading
+	if call == nil {
+		call = &cachedLoad[T]{done: make(chan struct{})}
+		cv.loading = call
+		go cv.loadShared(call)
+	}
+	cv.mu.Unlock()
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		var empty T
+		return empty, ctx.Err()
+	}
+}
+
+// loadShared performs the load on behalf of all the readers
+// waiting for the `call`. A result of a load started before
+// an invalidation is not stored.
+func (cv *cachedValue[T]) loadShared(call *cachedLoad[T]) {
+	call.value, call.err = cv.load(context.Background())
+	cv.mu.Lock()
+	if cv.loading == call {
+		if call.err == nil {
+			cv.value = call.value
+			cv.expires = time.Now().Add(cv.ttl)
+		}
+		cv.loading = nil
+	}
+	cv.mu.Unlock()
+	close(call.done)
+}
+
+func (cv *cachedValue[T]) Invalidate() {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	cv.expires = time.Time{}
+	cv.loading = nil
+}
+
+// refresh loads a new value regardless of the expiration and keeps
+// it for `ttl`. Readers are not blocked while the value is loading.
+func (cv *cachedValue[T]) refresh(ctx context.Context, ttl time.Duration) error {
+	value, err := cv.load(ctx)
+	if err != nil {
+		return err
+	}
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	cv.value = value
+	cv.expires = time.Now().Add(ttl)
+	return nil
+}
+
+// KeepFresh reloads the value every `interval` until the context
+// is cancelled. A refreshed value is valid for two intervals so
+// a single failed refresh does not make readers hit the data source.
+func (cv *cachedValue[T]) KeepFresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := cv.refresh(ctx, 2*interval); err != nil {
+			log.Error().Err(err).Msg("failed to refresh cached value")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func newCachedValue[T any](ttl time.Duration, load func(ctx context.Context) (T, error)) *cachedValue[T] {
+	return &cachedValue[T]{ttl: ttl, load: load}
+}
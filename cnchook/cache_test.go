@@ -0,0 +1,148 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedValueHit(t *testing.T) {
+	var numLoads int
+	cv := newCachedValue(time.Minute, func(ctx context.Context) (int, error) {
+		numLoads++
+		return 42, nil
+	})
+	for i := 0; i < 3; i++ {
+		v, err := cv.Get(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 42, v)
+	}
+	assert.Equal(t, 1, numLoads)
+}
+
+func TestCachedValueInvalidate(t *testing.T) {
+	var numLoads int
+	cv := newCachedValue(time.Minute, func(ctx context.Context) (int, error) {
+		numLoads++
+		return numLoads, nil
+	})
+	cv.Get(context.Background())
+	cv.Invalidate()
+	v, err := cv.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v)
+}
+
+func TestCachedValueErrorNotCached(t *testing.T) {
+	var numLoads int
+	cv := newCachedValue(time.Minute, func(ctx context.Context) (int, error) {
+		numLoads++
+		if numLoads == 1 {
+			return 0, errors.New("db error")
+		}
+		return 42, nil
+	})
+	_, err := cv.Get(context.Background())
+	assert.Error(t, err)
+	v, err := cv.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 42, v)
+}
+
+func TestCachedValueKeepFresh(t *testing.T) {
+	var numLoads atomic.Int32
+	cv := newCachedValue(time.Hour, func(ctx context.Context) (int, error) {
+		return int(numLoads.Add(1)), nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		cv.KeepFresh(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return numLoads.Load() >= 3 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+	loaded := numLoads.Load()
+	v, err := cv.Get(context.Background())
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, v, 3)
+	// Get does not load the value as the background refresh keeps it valid
+	assert.Equal(t, loaded, numLoads.Load())
+}
+
+func TestCachedValueKeepFreshKeepsOldValueOnError(t *testing.T) {
+	var numLoads atomic.Int32
+	cv := newCachedValue(time.Hour, func(ctx context.Context) (int, error) {
+		if numLoads.Add(1) > 1 {
+			return 0, errors.New("db error")
+		}
+		return 42, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cv.KeepFresh(ctx, 100*time.Millisecond)
+	assert.Eventually(t, func() bool { return numLoads.Load() >= 2 }, time.Second, time.Millisecond)
+	v, err := cv.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 42, v)
+}
+
+func TestCachedValueSharedLoad(t *testing.T) {
+	var numLoads atomic.Int32
+	release := make(chan struct{})
+	cv := newCachedValue(time.Minute, func(ctx context.Context) (int, error) {
+		numLoads.Add(1)
+		<-release
+		// the load must not depend on a context of any reader
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		return 42, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := cv.Get(ctx)
+		cancelled <- err
+	}()
+	assert.Eventually(t, func() bool { return numLoads.Load() == 1 }, time.Second, time.Millisecond)
+	waiting := make(chan int)
+	go func() {
+		v, err := cv.Get(context.Background())
+		assert.NoError(t, err)
+		waiting <- v
+	}()
+
+	// a cancelled reader stops waiting while the load continues
+	cancel()
+	assert.ErrorIs(t, <-cancelled, context.Canceled)
+	close(release)
+	assert.Equal(t, 42, <-waiting)
+	assert.Equal(t, int32(1), numLoads.Load())
+
+	v, err := cv.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 42, v)
+	assert.Equal(t, int32(1), numLoads.Load())
+}
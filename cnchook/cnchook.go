@@ -17,8 +17,11 @@
 package cnchook
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/czcorpus/cnc-vlo/cncdb"
@@ -26,25 +29,140 @@ import (
 	"github.com/czcorpus/cnc-vlo/oaipmh"
 	"github.com/czcorpus/cnc-vlo/oaipmh/formats"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
 )
 
+// RecordStore specifies DB operations the hook depends on
+// (implemented by cncdb.CNCMySQLHandler)
+type RecordStore interface {
+	GetFirstDate(ctx context.Context) (time.Time, error)
+	GetLastUpdate(ctx context.Context) (time.Time, error)
+	IdentifierExists(ctx context.Context, identifier string, includeDeleted bool) (bool, error)
+	GetRecordInfo(ctx context.Context, identifier string, includeDeleted bool) (*cncdb.DBData, error)
+	ListRecordInfo(
+		ctx context.Context, from *time.Time, until *time.Time, set cncdb.SetFilter, includeDeleted bool,
+		after *cncdb.RecordCursor, limit int) ([]cncdb.DBData, error)
+	ListRecordStamps(ctx context.Context) ([]cncdb.RecordStamp, error)
+	CountRecords(ctx context.Context, from *time.Time, until *time.Time, set cncdb.SetFilter, includeDeleted bool) (int, error)
+	GetParallelLocales(ctx context.Context, corpusNames []string) (map[string][]language.Tag, error)
+	GetCorplists(ctx context.Context, corpusNames []string) (map[string][]cncdb.Corplist, error)
+	GetKeywords(ctx context.Context, corpusNames []string) (map[string][]string, error)
+	ListCorplists(ctx context.Context) ([]cncdb.Corplist, error)
+	ListKeywords(ctx context.Context) ([]cncdb.Keyword, error)
+	GetDistributions(ctx context.Context, recordIDs []int) (map[int][]cncdb.Distribution, error)
+	GetRelations(ctx context.Context, recordIDs []int) (map[int][]cncdb.Relation, error)
+	GetFunding(ctx context.Context, recordIDs []int) (map[int][]cncdb.Funding, error)
+	GetRegistryAttrs(ctx context.Context, corpusNames []string) (map[string]cncdb.RegistryAttrs, error)
+}
+
 type CNCHook struct {
-	conf *cnf.Conf
-	db   *cncdb.CNCMySQLHandler
+	conf              *cnf.Conf
+	db                RecordStore
+	earliestDatestamp *cachedValue[time.Time]
+	lastUpdate        *cachedValue[time.Time]
+
+	// recordStamps lists records of the sitemap
+	recordStamps *cachedValue[[]cncdb.RecordStamp]
+
+	// served keeps ranges of recently served records
+	// for harvest reconciliation
+	served *servedLog
+
+	// metadataFormats is a static list of supported formats
+	metadataFormats []oaipmh.OAIPMHMetadataFormat
 }
 
-func (c *CNCHook) Identify() oaipmh.ResultWrapper[oaipmh.OAIPMHIdentify] {
-	earliestDatestamp, err := c.db.GetFirstDate()
+// getRepositoryName selects the best matching localized repository
+// name based on the provided Accept-Language header value
+func (c *CNCHook) getRepositoryName(acceptLanguage string) string {
+	if acceptLanguage == "" || len(c.conf.RepositoryInfo.LocalizedNames) == 0 {
+		return c.conf.RepositoryInfo.Name
+	}
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return c.conf.RepositoryInfo.Name
+	}
+	langs := make([]string, 0, len(c.conf.RepositoryInfo.LocalizedNames))
+	for lang := range c.conf.RepositoryInfo.LocalizedNames {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	supported := []language.Tag{language.Make(c.conf.DefaultLanguage())}
+	for _, lang := range langs {
+		supported = append(supported, language.Make(lang))
+	}
+	_, idx, confidence := language.NewMatcher(supported).Match(tags...)
+	if confidence == language.No || idx == 0 {
+		return c.conf.RepositoryInfo.Name
+	}
+	return c.conf.RepositoryInfo.LocalizedNames[langs[idx-1]]
+}
+
+// displayLanguage selects a language of localized record values
+// (`en` or `cs`) based on the provided Accept-Language header value
+func (c *CNCHook) displayLanguage(acceptLanguage string) string {
+	dflt := c.conf.DefaultLanguage()
+	if acceptLanguage == "" {
+		return dflt
+	}
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return dflt
+	}
+	supported := []language.Tag{language.Make(dflt), language.Czech}
+	_, idx, confidence := language.NewMatcher(supported).Match(tags...)
+	if confidence == language.No || idx == 0 {
+		return dflt
+	}
+	return "cs"
+}
+
+// dbErrorHTTPCode maps a failed DB query to a HTTP status code.
+// Queries cancelled by the client or exceeding the configured
+// timeout are reported as a temporary unavailability.
+func dbErrorHTTPCode(err error) int {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// RunBackgroundRefresh keeps the earliest datestamp fresh until
+// the context is cancelled. It returns immediately if the background
+// refresh is not enabled.
+func (c *CNCHook) RunBackgroundRefresh(ctx context.Context) {
+	if c.conf.EarliestDatestampRefreshSecs <= 0 {
+		return
+	}
+	c.earliestDatestamp.KeepFresh(
+		ctx, time.Duration(c.conf.EarliestDatestampRefreshSecs)*time.Second)
+}
+
+func (c *CNCHook) Identify(ctx context.Context, req oaipmh.OAIPMHRequest) oaipmh.ResultWrapper[oaipmh.OAIPMHIdentify] {
+	earliestDatestamp, err := c.earliestDatestamp.Get(ctx)
 	result := oaipmh.NewResultWrapper(
 		oaipmh.OAIPMHIdentify{
-			RepositoryName:    c.conf.RepositoryInfo.Name,
+			RepositoryName:    c.getRepositoryName(req.AcceptLanguage),
 			BaseURL:           c.conf.RepositoryInfo.BaseURL,
 			AdminEmail:        c.conf.RepositoryInfo.AdminEmail,
 			EarliestDatestamp: earliestDatestamp.In(time.UTC),
-			DeletedRecord:     "no",
-			Granularity:       "YYYY-MM-DDThh:mm:ssZ",
+			DeletedRecord:     c.conf.RepositoryInfo.DeletedRecord,
+			Granularity:       oaipmh.GranularitySeconds,
+			Compression:       c.conf.OAIPMH.EnabledEncodings(),
 		},
 	)
+	if ns := c.conf.RepositoryInfo.IdentifierNamespace; ns != "" {
+		result.Data.Description = append(
+			result.Data.Description,
+			oaipmh.ElementWrapper{Value: oaipmh.NewOAIPMHIdentifierDescription(ns, c.oaiIdentifier("1"))},
+		)
+	}
+	if len(c.conf.RepositoryInfo.Friends) > 0 {
+		result.Data.Description = append(
+			result.Data.Description,
+			oaipmh.ElementWrapper{Value: oaipmh.NewOAIPMHFriends(c.conf.RepositoryInfo.Friends)},
+		)
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to call Identify")
 		result.HTTPCode = http.StatusInternalServerError
@@ -52,130 +170,428 @@ func (c *CNCHook) Identify() oaipmh.ResultWrapper[oaipmh.OAIPMHIdentify] {
 	return result
 }
 
-func (c *CNCHook) ListMetadataFormats(req oaipmh.OAIPMHRequest) oaipmh.ResultWrapper[[]oaipmh.OAIPMHMetadataFormat] {
-	ans := oaipmh.NewResultWrapper(
-		[]oaipmh.OAIPMHMetadataFormat{
-			formats.GetDublinCoreFormat(),
-			formats.GetCMDIFormat(),
-		},
-	)
+func (c *CNCHook) ListMetadataFormats(ctx context.Context, req oaipmh.OAIPMHRequest) oaipmh.ResultWrapper[[]oaipmh.OAIPMHMetadataFormat] {
+	ans := oaipmh.NewResultWrapper(c.metadataFormats)
 	if req.Identifier != "" {
-		exists, err := c.db.IdentifierExists(req.Identifier)
+		recordID, ok := c.requestRecordID(req)
+		if !ok {
+			ans.Errors.Add(oaipmh.ErrorCodeIDDoesNotExist, fmt.Sprintf("Result for ID = %s not found", req.Identifier))
+			return ans
+		}
+		exists, err := c.db.IdentifierExists(ctx, recordID, c.conf.TracksDeletedRecords())
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to call ListMetadataFormats")
-			ans.HTTPCode = http.StatusInternalServerError
+			ans.HTTPCode = dbErrorHTTPCode(err)
 			return ans
 
 		} else if !exists {
 			ans.Errors.Add(oaipmh.ErrorCodeIDDoesNotExist, fmt.Sprintf("Result for ID = %s not found", req.Identifier))
-			ans.HTTPCode = http.StatusNotFound
 			return ans
 		}
 	}
 	return ans
 }
 
-func (c *CNCHook) GetRecord(req oaipmh.OAIPMHRequest) oaipmh.ResultWrapper[oaipmh.OAIPMHRecord] {
+func (c *CNCHook) GetRecord(ctx context.Context, req oaipmh.OAIPMHRequest) oaipmh.ResultWrapper[oaipmh.OAIPMHRecord] {
 	ans := oaipmh.NewResultWrapper(oaipmh.OAIPMHRecord{})
-	data, err := c.db.GetRecordInfo(req.Identifier)
+	recordID, ok := c.requestRecordID(req)
+	if !ok {
+		ans.Errors.Add(oaipmh.ErrorCodeIDDoesNotExist, fmt.Sprintf("Result for ID = %s not found", req.Identifier))
+		return ans
+	}
+	data, err := c.db.GetRecordInfo(ctx, recordID, c.conf.TracksDeletedRecords())
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to call GetRecord")
-		ans.HTTPCode = http.StatusInternalServerError
+		ans.HTTPCode = dbErrorHTTPCode(err)
 		return ans
 
 	} else if data == nil {
 		ans.Errors.Add(oaipmh.ErrorCodeIDDoesNotExist, fmt.Sprintf("Result for ID = %s not found", req.Identifier))
-		ans.HTTPCode = http.StatusNotFound
 		return ans
 	}
 
-	switch req.MetadataPrefix {
-	case formats.DublinCoreMetadataPrefix:
-		ans.Data = c.dcRecordFromData(data)
-	case formats.CMDIMetadataPrefix:
-		ans.Data = c.cmdiLindatClarinRecordFromData(data)
-	default:
+	now := time.Now()
+	if c.conf.Conversion.FutureDatestamps == cnf.FutureDatestampsExclude && data.Date.After(now) {
+		// consistent with list requests which skip such records
+		ans.Errors.Add(oaipmh.ErrorCodeIDDoesNotExist, fmt.Sprintf("Result for ID = %s not found", req.Identifier))
+		return ans
+	}
+	clampFutureDatestamp(data, now)
+	if err := c.completeData(ctx, data); err != nil {
+		log.Error().Err(err).Msg("Failed to call GetRecord")
+		ans.HTTPCode = dbErrorHTTPCode(err)
+		return ans
+	}
+
+	localizeContact(data, c.displayLanguage(req.AcceptLanguage))
+	record, ok := c.recordFromData(req.MetadataPrefix, data)
+	if !ok {
 		ans.Errors.Add(oaipmh.ErrorCodeCannotDisseminateFormat, "Unknown metadata format")
-		ans.HTTPCode = http.StatusBadRequest
+		return ans
 	}
+	ans.Data = record
 	return ans
 }
 
 // same as ListRecords but returns only RecordHeaders
-func (c *CNCHook) ListIdentifiers(req oaipmh.OAIPMHRequest) oaipmh.ResultWrapper[[]oaipmh.OAIPMHRecordHeader] {
+func (c *CNCHook) ListIdentifiers(ctx context.Context, req oaipmh.OAIPMHRequest) oaipmh.ResultWrapper[[]oaipmh.OAIPMHRecordHeader] {
 	ans := oaipmh.NewResultWrapper([]oaipmh.OAIPMHRecordHeader{})
-	data, err := c.db.ListRecordInfo(req.From, req.Until)
+	page, err := c.fetchListPage(ctx, req, time.Now())
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to call ListIdentifiers")
-		ans.HTTPCode = http.StatusInternalServerError
+		ans.HTTPCode = dbErrorHTTPCode(err)
 		return ans
 	}
-	if len(data) == 0 {
-		ans.Errors.Add(oaipmh.ErrorCodeNoRecordsMatch, "No records")
+	if len(page.errors) > 0 {
+		ans.Errors = page.errors
 		return ans
 	}
-	switch req.MetadataPrefix {
-	case formats.DublinCoreMetadataPrefix:
-		for _, d := range data {
-			ans.Data = append(ans.Data, *c.dcRecordFromData(&d).Header)
-		}
-	case formats.CMDIMetadataPrefix:
-		for _, d := range data {
-			ans.Data = append(ans.Data, *c.cmdiLindatClarinRecordFromData(&d).Header)
+	if err := c.completeData(ctx, sliceToPointers(page.data)...); err != nil {
+		log.Error().Err(err).Msg("Failed to call ListIdentifiers")
+		ans.HTTPCode = dbErrorHTTPCode(err)
+		return ans
+	}
+	for _, d := range page.data {
+		record, ok := c.recordFromData(page.metadataPrefix, &d)
+		if !ok {
+			ans.Errors.Add(oaipmh.ErrorCodeCannotDisseminateFormat, "Unknown metadata format")
+			return ans
 		}
-	default:
-		ans.Errors.Add(oaipmh.ErrorCodeCannotDisseminateFormat, "Unknown metadata format")
-		ans.HTTPCode = http.StatusBadRequest
+		ans.Data = append(ans.Data, *record.Header)
 	}
+	ans.ResumptionToken = page.token
 	return ans
 }
 
-func (c *CNCHook) ListRecords(req oaipmh.OAIPMHRequest) oaipmh.ResultWrapper[[]oaipmh.OAIPMHRecord] {
+func (c *CNCHook) ListRecords(ctx context.Context, req oaipmh.OAIPMHRequest) oaipmh.ResultWrapper[[]oaipmh.OAIPMHRecord] {
 	ans := oaipmh.NewResultWrapper([]oaipmh.OAIPMHRecord{})
-	data, err := c.db.ListRecordInfo(req.From, req.Until)
+	page, err := c.fetchListPage(ctx, req, time.Now())
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to call ListRecords")
-		ans.HTTPCode = http.StatusInternalServerError
+		ans.HTTPCode = dbErrorHTTPCode(err)
 		return ans
 	}
-	if len(data) == 0 {
-		ans.Errors.Add(oaipmh.ErrorCodeNoRecordsMatch, "No records")
+	if len(page.errors) > 0 {
+		ans.Errors = page.errors
 		return ans
 	}
-	switch req.MetadataPrefix {
-	case formats.DublinCoreMetadataPrefix:
+	if err := c.completeData(ctx, sliceToPointers(page.data)...); err != nil {
+		log.Error().Err(err).Msg("Failed to call ListRecords")
+		ans.HTTPCode = dbErrorHTTPCode(err)
+		return ans
+	}
+	lang := c.displayLanguage(req.AcceptLanguage)
+	for _, d := range page.data {
+		localizeContact(&d, lang)
+		record, ok := c.recordFromData(page.metadataPrefix, &d)
+		if !ok {
+			ans.Errors.Add(oaipmh.ErrorCodeCannotDisseminateFormat, "Unknown metadata format")
+			return ans
+		}
+		ans.Data = append(ans.Data, record)
+	}
+	if served, ok := newServedRange(page.metadataPrefix, page.data, time.Now()); ok {
+		log.Info().
+			Str("metadataPrefix", served.MetadataPrefix).
+			Int("numRecords", served.NumRecords).
+			Int("minRecordId", served.MinRecordID).
+			Int("maxRecordId", served.MaxRecordID).
+			Msg("ListRecords served")
+		c.served.add(served)
+	}
+	ans.ResumptionToken = page.token
+	return ans
+}
+
+// clampFrom replaces `from` preceding the earliest datestamp
+// with the earliest datestamp (if enabled). As there are no older
+// records, the result is the same while the DB query is bounded.
+func (c *CNCHook) clampFrom(ctx context.Context, from *time.Time) *time.Time {
+	if from == nil || !c.conf.ClampFromDate {
+		return from
+	}
+	earliest, err := c.earliestDatestamp.Get(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to get earliest datestamp, using the original `from`")
+		return from
+	}
+	if from.Before(earliest) {
+		log.Debug().
+			Time("from", *from).
+			Time("earliestDatestamp", earliest).
+			Msg("clamping `from` to the earliest datestamp")
+		return &earliest
+	}
+	return from
+}
+
+// clampFutureDatestamp replaces a datestamp in the future
+// (e.g. due to a clock skew or a data entry error) with `now`
+func clampFutureDatestamp(data *cncdb.DBData, now time.Time) {
+	if data.Date.After(now) {
+		log.Warn().
+			Int("id", data.ID).
+			Time("datestamp", data.Date).
+			Msg("record has a future datestamp, clamping to the current time")
+		data.Date = now
+	}
+}
+
+// handleFutureDatestamps either clamps or removes records with
+// future datestamps based on the configuration so incremental
+// harvesters won't miss changes
+func (c *CNCHook) handleFutureDatestamps(data []cncdb.DBData, now time.Time) []cncdb.DBData {
+	if c.conf.Conversion.FutureDatestamps != cnf.FutureDatestampsExclude {
+		for i := range data {
+			clampFutureDatestamp(&data[i], now)
+		}
+		return data
+	}
+	ans := make([]cncdb.DBData, 0, len(data))
+	for _, d := range data {
+		if d.Date.After(now) {
+			log.Warn().
+				Int("id", d.ID).
+				Time("datestamp", d.Date).
+				Msg("record has a future datestamp, excluding")
+			continue
+		}
+		ans = append(ans, d)
+	}
+	return ans
+}
+
+// clampUntil limits `until` to `now` in case records with future
+// datestamps are excluded. This way, such records are filtered out
+// by DB queries so counts and pages of lists stay consistent.
+func (c *CNCHook) clampUntil(until *time.Time, now time.Time) *time.Time {
+	if c.conf.Conversion.FutureDatestamps == cnf.FutureDatestampsExclude &&
+		(until == nil || until.After(now)) {
+		return &now
+	}
+	return until
+}
+
+// completeData fills in data requiring additional DB queries
+// in case the respective features are enabled
+func (c *CNCHook) completeData(ctx context.Context, data ...*cncdb.DBData) error {
+	if !c.conf.Conversion.IncludeParallelLanguages && !c.conf.Conversion.IncludeSetSpecs &&
+		!c.conf.Conversion.IncludeRegistryAttrs && !c.conf.Conversion.KeywordSets &&
+		!c.conf.Conversion.IncludeDistributions && !c.conf.Conversion.IncludeRelations &&
+		!c.conf.Conversion.IncludeFunding {
+		return nil
+	}
+	names := make([]string, 0, len(data))
+	for _, d := range data {
+		if MetadataType(d.Type) == CorpusMetadataType {
+			names = append(names, d.Name)
+		}
+	}
+	if c.conf.Conversion.IncludeParallelLanguages {
+		locales, err := c.db.GetParallelLocales(ctx, names)
+		if err != nil {
+			return err
+		}
 		for _, d := range data {
-			ans.Data = append(ans.Data, c.dcRecordFromData(&d))
+			d.CorpusData.ParallelLocales = locales[d.Name]
 		}
-	case formats.CMDIMetadataPrefix:
+	}
+	if c.conf.Conversion.IncludeSetSpecs {
+		corplists, err := c.db.GetCorplists(ctx, names)
+		if err != nil {
+			return err
+		}
+		allCorplists, err := c.db.ListCorplists(ctx)
+		if err != nil {
+			return err
+		}
+		setSpecs := corplistSetSpecs(allCorplists)
 		for _, d := range data {
-			ans.Data = append(ans.Data, c.cmdiLindatClarinRecordFromData(&d))
+			d.Corplists = corplists[d.Name]
+			d.CorplistSetSpecs = nil
+			for _, corplist := range d.Corplists {
+				// corplists without visible records are not listed as sets
+				if setSpec, ok := setSpecs[corplist.ID]; ok {
+					d.CorplistSetSpecs = append(d.CorplistSetSpecs, setSpec)
+				}
+			}
 		}
-	default:
-		ans.Errors.Add(oaipmh.ErrorCodeCannotDisseminateFormat, "Unknown metadata format")
-		ans.HTTPCode = http.StatusBadRequest
+	}
+	if c.conf.Conversion.KeywordSets {
+		keywords, err := c.db.GetKeywords(ctx, names)
+		if err != nil {
+			return err
+		}
+		for _, d := range data {
+			d.KeywordIDs = keywords[d.Name]
+		}
+	}
+	ids := make([]int, len(data))
+	for i, d := range data {
+		ids[i] = d.ID
+	}
+	if c.conf.Conversion.IncludeDistributions {
+		distributions, err := c.db.GetDistributions(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, d := range data {
+			d.Distributions = distributions[d.ID]
+		}
+	}
+	if c.conf.Conversion.IncludeRelations {
+		relations, err := c.db.GetRelations(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, d := range data {
+			d.Relations = relations[d.ID]
+		}
+	}
+	if c.conf.Conversion.IncludeFunding {
+		funding, err := c.db.GetFunding(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, d := range data {
+			d.Funding = funding[d.ID]
+		}
+	}
+	if c.conf.Conversion.IncludeRegistryAttrs {
+		attrs, err := c.db.GetRegistryAttrs(ctx, names)
+		if err != nil {
+			return err
+		}
+		for _, d := range data {
+			d.RegistryAttrs = attrs[d.Name]
+		}
+	}
+	return nil
+}
+
+func (c *CNCHook) ListSets(ctx context.Context, req oaipmh.OAIPMHRequest) oaipmh.ResultWrapper[[]oaipmh.OAIPMHSet] {
+	ans := oaipmh.NewResultWrapper([]oaipmh.OAIPMHSet{})
+	if req.ResumptionToken != "" {
+		// the list of sets is always complete
+		ans.Errors.Add(oaipmh.ErrorCodeBadResumptionToken, "Invalid or expired resumption token")
+		return ans
+	}
+	if c.conf.Conversion.IncludeSetSpecs {
+		corplists, err := c.db.ListCorplists(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to call ListSets")
+			ans.HTTPCode = http.StatusInternalServerError
+			return ans
+		}
+		ans.Data = append(ans.Data, corplistSets(corplists)...)
+	}
+	if c.conf.Conversion.KeywordSets {
+		keywords, err := c.db.ListKeywords(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to call ListSets")
+			ans.HTTPCode = http.StatusInternalServerError
+			return ans
+		}
+		ans.Data = append(ans.Data, keywordSets(keywords)...)
 	}
 	return ans
 }
 
-func (c *CNCHook) ListSets(req oaipmh.OAIPMHRequest) oaipmh.ResultWrapper[[]oaipmh.OAIPMHSet] {
-	return oaipmh.NewResultWrapper([]oaipmh.OAIPMHSet{})
+// corplistSets creates OAI-PMH sets from corplists
+func corplistSets(corplists []cncdb.Corplist) []oaipmh.OAIPMHSet {
+	setSpecs := corplistSetSpecs(corplists)
+	ans := make([]oaipmh.OAIPMHSet, len(corplists))
+	for i, corplist := range corplists {
+		ans[i] = oaipmh.OAIPMHSet{
+			SetSpec: setSpecs[corplist.ID],
+			SetName: corplist.Name,
+		}
+	}
+	return ans
+}
+
+// keywordSets creates OAI-PMH sets from corpus keywords
+// (including their common parent set)
+func keywordSets(keywords []cncdb.Keyword) []oaipmh.OAIPMHSet {
+	ans := make([]oaipmh.OAIPMHSet, 0, len(keywords)+1)
+	ans = append(ans, oaipmh.OAIPMHSet{SetSpec: KeywordSet, SetName: "Keywords"})
+	for _, keyword := range keywords {
+		ans = append(ans, oaipmh.OAIPMHSet{
+			SetSpec: KeywordSetPrefix + getSetSpec(keyword.ID),
+			SetName: keyword.LabelEN,
+		})
+	}
+	return ans
 }
 
 func (c *CNCHook) SupportsSets() bool {
-	return false
+	return c.conf.Conversion.IncludeSetSpecs || c.conf.Conversion.KeywordSets
 }
 
 func (c *CNCHook) SupportedMetadataPrefixes() []string {
 	return []string{
 		formats.DublinCoreMetadataPrefix,
 		formats.CMDIMetadataPrefix,
+		formats.DataCiteMetadataPrefix,
+		formats.OLACMetadataPrefix,
 	}
 }
 
-func NewCNCHook(conf *cnf.Conf, db *cncdb.CNCMySQLHandler) *CNCHook {
+// getMetadataFormats returns descriptions of all the supported
+// formats in the same order as SupportedMetadataPrefixes
+func getMetadataFormats(conf *cnf.Conf) []oaipmh.OAIPMHMetadataFormat {
+	return []oaipmh.OAIPMHMetadataFormat{
+		formats.GetDublinCoreFormat(conf.Conversion.DCSchemaURL),
+		formats.GetCMDIFormat(getCMDIEnvelope(conf)),
+		formats.GetDataCiteFormat(),
+		formats.GetOLACFormat(),
+	}
+}
+
+// ClearCache invalidates all the cached values
+func (c *CNCHook) ClearCache() {
+	c.earliestDatestamp.Invalidate()
+	c.lastUpdate.Invalidate()
+	c.recordStamps.Invalidate()
+}
+
+// GetLastUpdate returns time of the most recent update of a visible
+// record (a zero time if there are no visible records). The value
+// is cached (see IdentifyCacheTTLSecs).
+func (c *CNCHook) GetLastUpdate(ctx context.Context) (time.Time, error) {
+	return c.lastUpdate.Get(ctx)
+}
+
+// GetLastServed returns the most recent range of records served
+// by ListRecords within the [from, until] interval. Only a limited
+// number of recent responses is kept.
+func (c *CNCHook) GetLastServed(from, until time.Time) (ServedRange, bool) {
+	return c.served.lastWithin(from, until)
+}
+
+func NewCNCHook(conf *cnf.Conf, db RecordStore) *CNCHook {
 	return &CNCHook{
 		conf: conf,
 		db:   db,
+		earliestDatestamp: newCachedValue(
+			time.Duration(conf.IdentifyCacheTTLSecs)*time.Second,
+			func(ctx context.Context) (time.Time, error) {
+				return db.GetFirstDate(ctx)
+			},
+		),
+		lastUpdate: newCachedValue(
+			time.Duration(conf.IdentifyCacheTTLSecs)*time.Second,
+			func(ctx context.Context) (time.Time, error) {
+				return db.GetLastUpdate(ctx)
+			},
+		),
+		recordStamps: newCachedValue(
+			time.Duration(conf.SitemapCacheTTLSecs)*time.Second,
+			func(ctx context.Context) ([]cncdb.RecordStamp, error) {
+				return db.ListRecordStamps(ctx)
+			},
+		),
+		served:          newServedLog(dfltServedLogSize),
+		metadataFormats: getMetadataFormats(conf),
 	}
 }
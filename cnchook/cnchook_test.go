@@ -0,0 +1,877 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/czcorpus/cnc-vlo/cnchook/profiles"
+	"github.com/czcorpus/cnc-vlo/cnf"
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+	"github.com/czcorpus/cnc-vlo/oaipmh/formats"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLocalizedHook() *CNCHook {
+	return &CNCHook{
+		conf: &cnf.Conf{
+			RepositoryInfo: cnf.RepositoryInfo{
+				Name:           "CNC metadata repository",
+				LocalizedNames: map[string]string{"cs": "Metadatový repozitář ČNK"},
+			},
+		},
+	}
+}
+
+func TestGetRepositoryNameDefault(t *testing.T) {
+	hook := newLocalizedHook()
+	assert.Equal(t, "CNC metadata repository", hook.getRepositoryName(""))
+	assert.Equal(t, "CNC metadata repository", hook.getRepositoryName("en-US,en;q=0.9"))
+	assert.Equal(t, "CNC metadata repository", hook.getRepositoryName("de"))
+}
+
+func TestGetRepositoryNameLocalized(t *testing.T) {
+	hook := newLocalizedHook()
+	assert.Equal(t, "Metadatový repozitář ČNK", hook.getRepositoryName("cs"))
+	assert.Equal(t, "Metadatový repozitář ČNK", hook.getRepositoryName("cs-CZ,cs;q=0.9,en;q=0.8"))
+}
+
+func TestGetRepositoryNamePreferenceOrder(t *testing.T) {
+	hook := newLocalizedHook()
+	assert.Equal(t, "CNC metadata repository", hook.getRepositoryName("en,cs;q=0.5"))
+}
+
+func newFutureDatedData(now time.Time) []cncdb.DBData {
+	return []cncdb.DBData{
+		{ID: 1, Date: now.Add(-time.Hour)},
+		{ID: 2, Date: now.Add(48 * time.Hour)},
+	}
+}
+
+func TestFutureDatestampClamped(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	hook := &CNCHook{conf: &cnf.Conf{}}
+	hook.conf.Conversion.FutureDatestamps = cnf.FutureDatestampsClamp
+	data := hook.handleFutureDatestamps(newFutureDatedData(now), now)
+	assert.Len(t, data, 2)
+	assert.Equal(t, now.Add(-time.Hour), data[0].Date)
+	assert.Equal(t, now, data[1].Date)
+}
+
+func TestFutureDatestampExcluded(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	hook := &CNCHook{conf: &cnf.Conf{}}
+	hook.conf.Conversion.FutureDatestamps = cnf.FutureDatestampsExclude
+	data := hook.handleFutureDatestamps(newFutureDatedData(now), now)
+	assert.Len(t, data, 1)
+	assert.Equal(t, 1, data[0].ID)
+}
+
+func newIdentifyHook(conf *cnf.Conf) *CNCHook {
+	return &CNCHook{
+		conf: conf,
+		earliestDatestamp: newCachedValue(time.Minute, func(ctx context.Context) (time.Time, error) {
+			return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), nil
+		}),
+	}
+}
+
+func TestIdentifyFriends(t *testing.T) {
+	hook := newIdentifyHook(&cnf.Conf{})
+	hook.conf.RepositoryInfo.Friends = []string{
+		"https://lindat.mff.cuni.cz/repository/oai/request",
+		"https://clarin.ids-mannheim.de/oai",
+	}
+	ans := hook.Identify(context.Background(), oaipmh.OAIPMHRequest{})
+	assert.True(t, ans.NoError())
+	out, err := xml.Marshal(ans.Data)
+	assert.NoError(t, err)
+	assert.Contains(
+		t,
+		string(out),
+		`<description><friends xmlns="http://www.openarchives.org/OAI/2.0/friends/" `+
+			`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" `+
+			`xsi:schemaLocation="http://www.openarchives.org/OAI/2.0/friends/ http://www.openarchives.org/OAI/2.0/friends.xsd">`+
+			`<baseURL>https://lindat.mff.cuni.cz/repository/oai/request</baseURL>`+
+			`<baseURL>https://clarin.ids-mannheim.de/oai</baseURL>`+
+			`</friends></description>`,
+	)
+}
+
+func TestIdentifyFriendsWellFormed(t *testing.T) {
+	hook := newIdentifyHook(&cnf.Conf{})
+	hook.conf.RepositoryInfo.Friends = []string{
+		"https://lindat.mff.cuni.cz/repository/oai/request?verb=Identify&x=<y>",
+	}
+	ans := hook.Identify(context.Background(), oaipmh.OAIPMHRequest{})
+	out, err := xml.Marshal(ans.Data)
+	assert.NoError(t, err)
+
+	var parsed struct {
+		Description []struct {
+			Friends struct {
+				BaseURL []string `xml:"http://www.openarchives.org/OAI/2.0/friends/ baseURL"`
+			} `xml:"http://www.openarchives.org/OAI/2.0/friends/ friends"`
+		} `xml:"description"`
+	}
+	assert.NoError(t, xml.Unmarshal(out, &parsed))
+	if assert.Len(t, parsed.Description, 1) {
+		assert.Equal(t, hook.conf.RepositoryInfo.Friends, parsed.Description[0].Friends.BaseURL)
+	}
+}
+
+func TestIdentifyNoFriends(t *testing.T) {
+	hook := newIdentifyHook(&cnf.Conf{})
+	ans := hook.Identify(context.Background(), oaipmh.OAIPMHRequest{})
+	assert.Empty(t, ans.Data.Description)
+}
+
+func TestResolveSet(t *testing.T) {
+	hook := &CNCHook{
+		conf: &cnf.Conf{},
+		db: &fakeRecordStore{
+			corplists: []cncdb.Corplist{{ID: 3, Name: "Korpusy ČNK"}},
+			keywords:  []cncdb.Keyword{{ID: "fiction"}, {ID: "non fiction"}},
+		},
+	}
+	hook.conf.Conversion.KeywordSets = true
+	hook.conf.Conversion.IncludeSetSpecs = true
+	for set, expected := range map[string]cncdb.SetFilter{
+		"":                    {},
+		"keyword":             {AnyKeyword: true},
+		"keyword:fiction":     {Keyword: "fiction"},
+		"keyword:non_fiction": {Keyword: "non fiction"},
+		"Korpusy_CNK":         {CorplistID: 3},
+	} {
+		filter, ok, err := hook.resolveSet(context.Background(), set)
+		assert.NoError(t, err)
+		assert.True(t, ok, set)
+		assert.Equal(t, expected, filter)
+	}
+	for _, set := range []string{"keyword:", "keyword:poetry", "keyword:non fiction", "public"} {
+		_, ok, err := hook.resolveSet(context.Background(), set)
+		assert.NoError(t, err)
+		assert.False(t, ok, set)
+	}
+}
+
+func TestResolveSetDisabled(t *testing.T) {
+	hook := &CNCHook{
+		conf: &cnf.Conf{},
+		db:   &fakeRecordStore{corplists: []cncdb.Corplist{{ID: 3, Name: "public"}}},
+	}
+	for _, set := range []string{"keyword:fiction", "public"} {
+		_, ok, err := hook.resolveSet(context.Background(), set)
+		assert.NoError(t, err)
+		assert.False(t, ok, set)
+	}
+}
+
+func TestKeywordSets(t *testing.T) {
+	sets := keywordSets([]cncdb.Keyword{
+		{ID: "fiction", LabelEN: "Fiction"},
+		{ID: "spoken", LabelEN: "Spoken"},
+	})
+	assert.Equal(
+		t,
+		[]oaipmh.OAIPMHSet{
+			{SetSpec: "keyword", SetName: "Keywords"},
+			{SetSpec: "keyword:fiction", SetName: "Fiction"},
+			{SetSpec: "keyword:spoken", SetName: "Spoken"},
+		},
+		sets,
+	)
+}
+
+func TestKeywordSetsSanitized(t *testing.T) {
+	sets := keywordSets([]cncdb.Keyword{{ID: "sci-fi/fantasy", LabelEN: "Sci-fi and fantasy"}})
+	assert.Equal(t, "keyword:sci-fi_fantasy", sets[1].SetSpec)
+}
+
+func TestCorplistCollidingWithKeywordSet(t *testing.T) {
+	sets := corplistSets([]cncdb.Corplist{{ID: 7, Name: "keyword"}})
+	assert.Equal(t, "keyword_7", sets[0].SetSpec)
+}
+
+func TestSupportsSets(t *testing.T) {
+	hook := &CNCHook{conf: &cnf.Conf{}}
+	assert.False(t, hook.SupportsSets())
+	hook.conf.Conversion.KeywordSets = true
+	assert.True(t, hook.SupportsSets())
+	hook.conf.Conversion.KeywordSets = false
+	hook.conf.Conversion.IncludeSetSpecs = true
+	assert.True(t, hook.SupportsSets())
+}
+
+func TestCorplistSets(t *testing.T) {
+	sets := corplistSets([]cncdb.Corplist{{ID: 1, Name: "public"}, {ID: 3, Name: "Korpusy ČNK"}})
+	assert.Equal(
+		t,
+		[]oaipmh.OAIPMHSet{
+			{SetSpec: "public", SetName: "public"},
+			{SetSpec: "Korpusy_CNK", SetName: "Korpusy ČNK"},
+		},
+		sets,
+	)
+}
+
+func TestCorplistSetsCollision(t *testing.T) {
+	sets := corplistSets([]cncdb.Corplist{{ID: 4, Name: "Cestina"}, {ID: 5, Name: "Čeština"}, {ID: 1, Name: "public"}})
+	assert.Equal(
+		t,
+		[]oaipmh.OAIPMHSet{
+			{SetSpec: "Cestina_4", SetName: "Cestina"},
+			{SetSpec: "Cestina_5", SetName: "Čeština"},
+			{SetSpec: "public", SetName: "public"},
+		},
+		sets,
+	)
+}
+
+func TestResolveCollidingSets(t *testing.T) {
+	hook := &CNCHook{
+		conf: &cnf.Conf{},
+		db:   &fakeRecordStore{corplists: []cncdb.Corplist{{ID: 4, Name: "Cestina"}, {ID: 5, Name: "Čeština"}}},
+	}
+	hook.conf.Conversion.IncludeSetSpecs = true
+	for set, expected := range map[string]cncdb.SetFilter{
+		"Cestina_4": {CorplistID: 4},
+		"Cestina_5": {CorplistID: 5},
+	} {
+		filter, ok, err := hook.resolveSet(context.Background(), set)
+		assert.NoError(t, err)
+		assert.True(t, ok, set)
+		assert.Equal(t, expected, filter)
+	}
+	_, ok, err := hook.resolveSet(context.Background(), "Cestina")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func newCorplistHook() *CNCHook {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hook := &CNCHook{
+		conf: &cnf.Conf{PageSize: 100, ResumptionTokenTTLSecs: 60},
+		db: &fakeRecordStore{
+			corplists: []cncdb.Corplist{{ID: 1, Name: "public"}, {ID: 3, Name: "Korpusy ČNK"}},
+			records: []cncdb.DBData{
+				{ID: 1, Date: base, Type: "corpus", Name: "syn2020", Corplists: []cncdb.Corplist{{ID: 1, Name: "public"}, {ID: 3, Name: "Korpusy ČNK"}}},
+				{ID: 2, Date: base, Type: "corpus", Name: "oral", Corplists: []cncdb.Corplist{{ID: 1, Name: "public"}}},
+				{ID: 3, Date: base, Type: "service", Name: "KonText"},
+			},
+		},
+	}
+	hook.conf.Conversion.IncludeSetSpecs = true
+	return hook
+}
+
+func TestListCorplistSet(t *testing.T) {
+	hook := newCorplistHook()
+	ans := hook.ListIdentifiers(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Set: "Korpusy_CNK"})
+	assert.True(t, ans.NoError())
+	assert.Len(t, ans.Data, 1)
+	assert.Equal(t, "1", ans.Data[0].Identifier)
+	assert.Equal(t, []string{"public", "Korpusy_CNK"}, ans.Data[0].SetSpec)
+
+	ans = hook.ListIdentifiers(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Set: "public"})
+	assert.True(t, ans.NoError())
+	assert.Len(t, ans.Data, 2)
+}
+
+func TestRecordHeaderCollidingSets(t *testing.T) {
+	hook := newCorplistHook()
+	store := hook.db.(*fakeRecordStore)
+	store.corplists = append(store.corplists, cncdb.Corplist{ID: 5, Name: "Korpusy CNK"})
+	store.records[1].Corplists = append(store.records[1].Corplists, cncdb.Corplist{ID: 5, Name: "Korpusy CNK"})
+	ans := hook.ListIdentifiers(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Set: "Korpusy_CNK_5"})
+	assert.True(t, ans.NoError())
+	assert.Len(t, ans.Data, 1)
+	assert.Equal(t, "2", ans.Data[0].Identifier)
+	assert.Equal(t, []string{"public", "Korpusy_CNK_5"}, ans.Data[0].SetSpec)
+}
+
+func TestListParentKeywordSet(t *testing.T) {
+	hook := newCorplistHook()
+	hook.conf.Conversion.KeywordSets = true
+	hook.db.(*fakeRecordStore).records[1].KeywordIDs = []string{"spoken"}
+	ans := hook.ListIdentifiers(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Set: "keyword"})
+	assert.True(t, ans.NoError())
+	assert.Len(t, ans.Data, 1)
+	assert.Equal(t, "2", ans.Data[0].Identifier)
+}
+
+func TestListUnknownCorplistSet(t *testing.T) {
+	hook := newCorplistHook()
+	ans := hook.ListRecords(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Set: "private"})
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeNoRecordsMatch, ans.Errors[0].Code)
+}
+
+func TestListSetsCorplists(t *testing.T) {
+	hook := newCorplistHook()
+	ans := hook.ListSets(context.Background(), oaipmh.OAIPMHRequest{})
+	assert.True(t, ans.NoError())
+	assert.Equal(t, []string{"public", "Korpusy_CNK"}, []string{ans.Data[0].SetSpec, ans.Data[1].SetSpec})
+}
+
+func TestListUnknownSet(t *testing.T) {
+	hook := &CNCHook{conf: &cnf.Conf{}}
+	hook.conf.Conversion.KeywordSets = true
+	req := oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Set: "public"}
+	records := hook.ListRecords(context.Background(), req)
+	assert.Len(t, records.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeNoRecordsMatch, records.Errors[0].Code)
+	identifiers := hook.ListIdentifiers(context.Background(), req)
+	assert.Len(t, identifiers.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeNoRecordsMatch, identifiers.Errors[0].Code)
+}
+
+func TestClampFromAncientDate(t *testing.T) {
+	hook := newIdentifyHook(&cnf.Conf{ClampFromDate: true})
+	from := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	clamped := hook.clampFrom(context.Background(), &from)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), *clamped)
+	// the original request value is kept (it is echoed in the response)
+	assert.Equal(t, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), from)
+}
+
+func TestClampFromRecentDate(t *testing.T) {
+	hook := newIdentifyHook(&cnf.Conf{ClampFromDate: true})
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	assert.Same(t, &from, hook.clampFrom(context.Background(), &from))
+	assert.Nil(t, hook.clampFrom(context.Background(), nil))
+}
+
+func TestClampFromDisabled(t *testing.T) {
+	hook := newIdentifyHook(&cnf.Conf{})
+	from := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Same(t, &from, hook.clampFrom(context.Background(), &from))
+}
+
+func TestIdentifyCompression(t *testing.T) {
+	hook := newIdentifyHook(&cnf.Conf{})
+	hook.conf.OAIPMH.Compression = []string{"deflate", "gzip", "GZIP"}
+	ans := hook.Identify(context.Background(), oaipmh.OAIPMHRequest{})
+	assert.Equal(t, []string{"deflate", "gzip"}, ans.Data.Compression)
+	out, err := xml.Marshal(ans.Data)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "<compression>deflate</compression><compression>gzip</compression>")
+}
+
+func TestIdentifyNoCompression(t *testing.T) {
+	hook := newIdentifyHook(&cnf.Conf{})
+	out, err := xml.Marshal(hook.Identify(context.Background(), oaipmh.OAIPMHRequest{}).Data)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "<compression>")
+}
+
+func newDeletionHook(deletedRecord string) (*CNCHook, *fakeRecordStore) {
+	db := &fakeRecordStore{
+		records: []cncdb.DBData{
+			{ID: 1, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Type: "service", Name: "KonText"},
+		},
+	}
+	hook := &CNCHook{
+		conf: &cnf.Conf{PageSize: 100, ResumptionTokenTTLSecs: 60},
+		db:   db,
+	}
+	hook.conf.RepositoryInfo.DeletedRecord = deletedRecord
+	return hook, db
+}
+
+func TestDeletedRecordTransition(t *testing.T) {
+	hook, db := newDeletionHook(cnf.DeletedRecordPersistent)
+	ans := hook.ListRecords(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	assert.True(t, ans.NoError())
+	assert.Len(t, ans.Data, 1)
+	assert.NotNil(t, ans.Data[0].Metadata)
+
+	deletedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	db.records[0].Deleted = true
+	db.records[0].Date = deletedAt
+
+	ans = hook.ListRecords(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", From: &deletedAt})
+	assert.True(t, ans.NoError())
+	assert.Len(t, ans.Data, 1)
+	assert.Equal(t, oaipmh.RecordStatusDeleted, ans.Data[0].Header.Status)
+	assert.Equal(t, deletedAt, ans.Data[0].Header.Datestamp)
+	assert.Nil(t, ans.Data[0].Metadata)
+
+	record := hook.GetRecord(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Identifier: "1"})
+	assert.True(t, record.NoError())
+	assert.Equal(t, oaipmh.RecordStatusDeleted, record.Data.Header.Status)
+	assert.Nil(t, record.Data.Metadata)
+
+	formats := hook.ListMetadataFormats(context.Background(), oaipmh.OAIPMHRequest{Identifier: "1"})
+	assert.True(t, formats.NoError())
+}
+
+func TestDeletedRecordNotTracked(t *testing.T) {
+	hook, db := newDeletionHook(cnf.DeletedRecordNo)
+	db.records[0].Deleted = true
+	record := hook.GetRecord(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Identifier: "1"})
+	assert.Len(t, record.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeIDDoesNotExist, record.Errors[0].Code)
+	ans := hook.ListRecords(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeNoRecordsMatch, ans.Errors[0].Code)
+}
+
+func TestIdentifyDeletedRecord(t *testing.T) {
+	hook := newIdentifyHook(&cnf.Conf{})
+	hook.conf.RepositoryInfo.DeletedRecord = cnf.DeletedRecordPersistent
+	ans := hook.Identify(context.Background(), oaipmh.OAIPMHRequest{})
+	assert.Equal(t, "persistent", ans.Data.DeletedRecord)
+}
+
+// blockingRecordStore emulates a slow DB where queries
+// finish only once their context is done
+type blockingRecordStore struct {
+	fakeRecordStore
+}
+
+func (db *blockingRecordStore) ListRecordInfo(
+	ctx context.Context, from *time.Time, until *time.Time, set cncdb.SetFilter, includeDeleted bool,
+	after *cncdb.RecordCursor, limit int,
+) ([]cncdb.DBData, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("failed to list record info: %w", ctx.Err())
+}
+
+func (db *blockingRecordStore) GetRecordInfo(ctx context.Context, identifier string, includeDeleted bool) (*cncdb.DBData, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("failed to get record info: %w", ctx.Err())
+}
+
+func newBlockingHook() *CNCHook {
+	return &CNCHook{
+		conf: &cnf.Conf{PageSize: 100, ResumptionTokenTTLSecs: 60},
+		db:   &blockingRecordStore{},
+	}
+}
+
+func TestListRecordsCancelledMidQuery(t *testing.T) {
+	hook := newBlockingHook()
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan oaipmh.ResultWrapper[[]oaipmh.OAIPMHRecord])
+	go func() {
+		result <- hook.ListRecords(ctx, oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	}()
+	cancel()
+	select {
+	case ans := <-result:
+		assert.Equal(t, http.StatusServiceUnavailable, ans.HTTPCode)
+		assert.Empty(t, ans.Data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListRecords did not return after the context was cancelled")
+	}
+}
+
+func TestGetRecordTimeout(t *testing.T) {
+	hook := newBlockingHook()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ans := hook.GetRecord(ctx, oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Identifier: "1"})
+	assert.Equal(t, http.StatusServiceUnavailable, ans.HTTPCode)
+}
+
+func TestGetRecordCompleteDataCancelled(t *testing.T) {
+	// the request context reaches also the queries completing the record
+	hook := newStoreHook(newSingleRecordStore())
+	hook.conf.Conversion.IncludeSetSpecs = true
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ans := hook.GetRecord(ctx, oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Identifier: "1"})
+	assert.Equal(t, http.StatusServiceUnavailable, ans.HTTPCode)
+}
+
+func TestDBErrorHTTPCode(t *testing.T) {
+	assert.Equal(t, http.StatusServiceUnavailable, dbErrorHTTPCode(fmt.Errorf("wrapped: %w", context.DeadlineExceeded)))
+	assert.Equal(t, http.StatusInternalServerError, dbErrorHTTPCode(errors.New("connection refused")))
+}
+
+func TestGetRecordWithRelations(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.IncludeRelations = true
+	record := *newTestData()
+	record.SourceID = sql.NullInt64{Int64: 7, Valid: true}
+	hook.db = &fakeRecordStore{
+		records: []cncdb.DBData{record},
+		relations: map[int][]cncdb.Relation{
+			42: {
+				{Type: "isVersionOf", Target: "http://localhost:8080/record/12"},
+				{Type: "references", Target: "https://doi.org/10.1000/182"},
+			},
+		},
+	}
+	ans := hook.GetRecord(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: formats.CMDIMetadataPrefix, Identifier: "42"})
+	assert.True(t, ans.NoError())
+	out, err := xml.Marshal(ans.Data.Metadata)
+	assert.NoError(t, err)
+	assert.Contains(
+		t, string(out),
+		`<cmdp:relationsInfo>`+
+			`<cmdp:relation type="isDerivedFrom">http://localhost:8080/record/7</cmdp:relation>`+
+			`<cmdp:relation type="isVersionOf">http://localhost:8080/record/12</cmdp:relation>`+
+			`<cmdp:relation type="references">https://doi.org/10.1000/182</cmdp:relation>`+
+			`</cmdp:relationsInfo>`,
+	)
+}
+
+func TestGetRecordWithoutRelations(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.IncludeRelations = true
+	hook.db = &fakeRecordStore{records: []cncdb.DBData{*newTestData()}}
+	ans := hook.GetRecord(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: formats.CMDIMetadataPrefix, Identifier: "42"})
+	assert.True(t, ans.NoError())
+	out, err := xml.Marshal(ans.Data.Metadata)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "relationsInfo")
+}
+
+func newStoreHook(store *fakeRecordStore) *CNCHook {
+	return NewCNCHook(&cnf.Conf{PageSize: 100, ResumptionTokenTTLSecs: 60}, store)
+}
+
+func newSingleRecordStore() *fakeRecordStore {
+	return &fakeRecordStore{
+		records: []cncdb.DBData{
+			{ID: 1, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Type: "corpus", Name: "syn2020"},
+		},
+	}
+}
+
+func TestGetRecordFound(t *testing.T) {
+	hook := newStoreHook(newSingleRecordStore())
+	ans := hook.GetRecord(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Identifier: "1"})
+	assert.True(t, ans.NoError())
+	assert.Equal(t, "1", ans.Data.Header.Identifier)
+}
+
+func TestGetRecordFutureDatestamp(t *testing.T) {
+	store := newSingleRecordStore()
+	store.records[0].Date = time.Now().Add(time.Hour)
+	hook := newStoreHook(store)
+	hook.conf.Conversion.FutureDatestamps = cnf.FutureDatestampsClamp
+	ans := hook.GetRecord(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Identifier: "1"})
+	assert.True(t, ans.NoError())
+	assert.False(t, time.Time(ans.Data.Header.Datestamp).After(time.Now()))
+
+	hook.conf.Conversion.FutureDatestamps = cnf.FutureDatestampsExclude
+	ans = hook.GetRecord(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Identifier: "1"})
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeIDDoesNotExist, ans.Errors[0].Code)
+}
+
+func TestGetRecordNotFound(t *testing.T) {
+	hook := newStoreHook(newSingleRecordStore())
+	ans := hook.GetRecord(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Identifier: "2"})
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeIDDoesNotExist, ans.Errors[0].Code)
+	assert.Equal(t, http.StatusOK, ans.HTTPCode)
+}
+
+func TestGetRecordDBError(t *testing.T) {
+	hook := newStoreHook(&fakeRecordStore{err: errors.New("connection refused")})
+	ans := hook.GetRecord(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Identifier: "1"})
+	assert.Empty(t, ans.Errors)
+	assert.Equal(t, http.StatusInternalServerError, ans.HTTPCode)
+}
+
+func TestListRecordsFound(t *testing.T) {
+	hook := newStoreHook(newSingleRecordStore())
+	ans := hook.ListRecords(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	assert.True(t, ans.NoError())
+	assert.Len(t, ans.Data, 1)
+}
+
+func TestListRecordsNotFound(t *testing.T) {
+	hook := newStoreHook(newSingleRecordStore())
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	ans := hook.ListRecords(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", From: &from})
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeNoRecordsMatch, ans.Errors[0].Code)
+	assert.Empty(t, ans.Data)
+}
+
+func TestListRecordsEmptyStore(t *testing.T) {
+	hook := newStoreHook(&fakeRecordStore{})
+	ans := hook.ListRecords(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeNoRecordsMatch, ans.Errors[0].Code)
+	assert.Empty(t, ans.ResumptionToken)
+}
+
+func TestListRecordsDBError(t *testing.T) {
+	hook := newStoreHook(&fakeRecordStore{err: errors.New("connection refused")})
+	ans := hook.ListRecords(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	assert.Empty(t, ans.Errors)
+	assert.Empty(t, ans.Data)
+	assert.Equal(t, http.StatusInternalServerError, ans.HTTPCode)
+}
+
+func TestListIdentifiersFound(t *testing.T) {
+	hook := newStoreHook(newSingleRecordStore())
+	ans := hook.ListIdentifiers(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	assert.True(t, ans.NoError())
+	assert.Len(t, ans.Data, 1)
+	assert.Equal(t, "1", ans.Data[0].Identifier)
+}
+
+func TestListIdentifiersNotFound(t *testing.T) {
+	hook := newStoreHook(newSingleRecordStore())
+	until := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	ans := hook.ListIdentifiers(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Until: &until})
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeNoRecordsMatch, ans.Errors[0].Code)
+}
+
+func TestListIdentifiersEmptyStore(t *testing.T) {
+	hook := newStoreHook(&fakeRecordStore{})
+	ans := hook.ListIdentifiers(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeNoRecordsMatch, ans.Errors[0].Code)
+	assert.Empty(t, ans.Data)
+}
+
+func TestListIdentifiersDBError(t *testing.T) {
+	hook := newStoreHook(&fakeRecordStore{err: errors.New("connection refused")})
+	ans := hook.ListIdentifiers(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	assert.Empty(t, ans.Errors)
+	assert.Equal(t, http.StatusInternalServerError, ans.HTTPCode)
+}
+
+func TestListMetadataFormatsExistingID(t *testing.T) {
+	store := newSingleRecordStore()
+	ans := newStoreHook(store).ListMetadataFormats(context.Background(), oaipmh.OAIPMHRequest{Identifier: "1"})
+	assert.True(t, ans.NoError())
+	assert.Equal(t, http.StatusOK, ans.HTTPCode)
+	assert.NotEmpty(t, ans.Data)
+	assert.Equal(t, 1, store.numExistenceChecks)
+}
+
+func TestListMetadataFormatsNonexistentID(t *testing.T) {
+	store := newSingleRecordStore()
+	ans := newStoreHook(store).ListMetadataFormats(context.Background(), oaipmh.OAIPMHRequest{Identifier: "2"})
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeIDDoesNotExist, ans.Errors[0].Code)
+	// same as GetRecord, the protocol error is reported with HTTP 200
+	assert.Equal(t, http.StatusOK, ans.HTTPCode)
+}
+
+func TestListMetadataFormatsWithoutID(t *testing.T) {
+	store := &fakeRecordStore{err: errors.New("connection refused")}
+	ans := newStoreHook(store).ListMetadataFormats(context.Background(), oaipmh.OAIPMHRequest{})
+	assert.True(t, ans.NoError())
+	assert.NotEmpty(t, ans.Data)
+	assert.Zero(t, store.numExistenceChecks)
+}
+
+func newBilingualAffiliationHook() *CNCHook {
+	hook := newTestHook()
+	record := *newTestData()
+	record.ContactPerson = cncdb.ContactPersonData{
+		Firstname:     "Jan",
+		Lastname:      "Novák",
+		Email:         "jan.novak@korpus.cz",
+		Affiliation:   sql.NullString{String: "Ústav Českého národního korpusu", Valid: true},
+		AffiliationEN: sql.NullString{String: "Institute of the Czech National Corpus", Valid: true},
+	}
+	hook.db = &fakeRecordStore{records: []cncdb.DBData{record}}
+	return hook
+}
+
+func getRecordAffiliation(t *testing.T, hook *CNCHook, acceptLanguage string) string {
+	ans := hook.GetRecord(
+		context.Background(),
+		oaipmh.OAIPMHRequest{MetadataPrefix: formats.CMDIMetadataPrefix, Identifier: "42", AcceptLanguage: acceptLanguage},
+	)
+	assert.True(t, ans.NoError())
+	cmdi := ans.Data.Metadata.Value.(formats.CMDIFormat)
+	return cmdi.Components.(*profiles.CNCResourceProfile).BibliographicInfo.ContactPerson.Affiliation
+}
+
+func TestBilingualAffiliationCzechDisplay(t *testing.T) {
+	hook := newBilingualAffiliationHook()
+	assert.Equal(t, "Ústav Českého národního korpusu", getRecordAffiliation(t, hook, "cs-CZ,cs;q=0.9,en;q=0.5"))
+}
+
+func TestBilingualAffiliationEnglishDisplay(t *testing.T) {
+	hook := newBilingualAffiliationHook()
+	assert.Equal(t, "Institute of the Czech National Corpus", getRecordAffiliation(t, hook, "en-US,en;q=0.9"))
+	assert.Equal(t, "Institute of the Czech National Corpus", getRecordAffiliation(t, hook, ""))
+	assert.Equal(t, "Institute of the Czech National Corpus", getRecordAffiliation(t, hook, "de"))
+}
+
+func TestCzechOnlyAffiliation(t *testing.T) {
+	hook := newBilingualAffiliationHook()
+	hook.db.(*fakeRecordStore).records[0].ContactPerson.AffiliationEN = sql.NullString{}
+	assert.Equal(t, "Ústav Českého národního korpusu", getRecordAffiliation(t, hook, "en"))
+}
+
+func getCMDIRecord(t *testing.T, hook *CNCHook, identifier string) string {
+	ans := hook.GetRecord(
+		context.Background(),
+		oaipmh.OAIPMHRequest{MetadataPrefix: formats.CMDIMetadataPrefix, Identifier: identifier},
+	)
+	assert.True(t, ans.NoError())
+	out, err := xml.Marshal(ans.Data.Metadata)
+	assert.NoError(t, err)
+	return string(out)
+}
+
+func newTagsetHook() *CNCHook {
+	hook := newTestHook()
+	hook.conf.Conversion.IncludeRegistryAttrs = true
+	hook.conf.Conversion.AnnotationTagsets = true
+	unknown := *newTestData()
+	unknown.ID = 43
+	unknown.Name = "oral_v1"
+	hook.db = &fakeRecordStore{
+		records: []cncdb.DBData{*newTestData(), unknown},
+		registry: map[string]cncdb.RegistryAttrs{
+			"syn2020": {Tagsets: []string{"PDT"}},
+		},
+	}
+	return hook
+}
+
+func TestAnnotationTagset(t *testing.T) {
+	out := getCMDIRecord(t, newTagsetHook(), "42")
+	assert.Contains(
+		t, out,
+		"<cmdp:annotationInfo><cmdp:annotationType>tags</cmdp:annotationType>"+
+			"<cmdp:tagset>PDT</cmdp:tagset></cmdp:annotationInfo>",
+	)
+	assert.Contains(t, out, `<cmdp:format cmdp:type="tagset"><cmdp:name>PDT</cmdp:name></cmdp:format>`)
+}
+
+func TestAnnotationTagsetDisabled(t *testing.T) {
+	hook := newTagsetHook()
+	hook.conf.Conversion.AnnotationTagsets = false
+	out := getCMDIRecord(t, hook, "42")
+	assert.Contains(t, out, "<cmdp:annotationInfo><cmdp:annotationType>tags</cmdp:annotationType></cmdp:annotationInfo>")
+	assert.NotContains(t, out, "cmdp:tagset")
+}
+
+func TestAnnotationTagsetUnknown(t *testing.T) {
+	out := getCMDIRecord(t, newTagsetHook(), "43")
+	assert.NotContains(t, out, "cmdp:annotationInfo")
+}
+
+func TestIdentifyOAIIdentifier(t *testing.T) {
+	hook := newIdentifyHook(&cnf.Conf{})
+	hook.conf.RepositoryInfo.IdentifierNamespace = "korpus.cz"
+	ans := hook.Identify(context.Background(), oaipmh.OAIPMHRequest{})
+	assert.True(t, ans.NoError())
+	out, err := xml.Marshal(ans.Data)
+	assert.NoError(t, err)
+	assert.Contains(
+		t,
+		string(out),
+		`<description><oai-identifier xmlns="http://www.openarchives.org/OAI/2.0/oai-identifier" `+
+			`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" `+
+			`xsi:schemaLocation="http://www.openarchives.org/OAI/2.0/oai-identifier http://www.openarchives.org/OAI/2.0/oai-identifier.xsd">`+
+			`<scheme>oai</scheme><repositoryIdentifier>korpus.cz</repositoryIdentifier>`+
+			`<delimiter>:</delimiter><sampleIdentifier>oai:korpus.cz:1</sampleIdentifier>`+
+			`</oai-identifier></description>`,
+	)
+}
+
+func newPrefixedIdentifierHook() *CNCHook {
+	hook := newStoreHook(newSingleRecordStore())
+	hook.conf.RepositoryInfo.IdentifierNamespace = "korpus.cz"
+	return hook
+}
+
+func TestGetRecordPrefixedIdentifier(t *testing.T) {
+	hook := newPrefixedIdentifierHook()
+	for _, identifier := range []string{"oai:korpus.cz:1", "1"} {
+		ans := hook.GetRecord(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Identifier: identifier})
+		assert.True(t, ans.NoError(), identifier)
+		assert.Equal(t, "oai:korpus.cz:1", ans.Data.Header.Identifier)
+	}
+}
+
+func TestGetRecordInvalidPrefix(t *testing.T) {
+	hook := newPrefixedIdentifierHook()
+	ans := hook.GetRecord(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Identifier: "oai:lindat.cz:1"})
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeIDDoesNotExist, ans.Errors[0].Code)
+}
+
+func TestListMetadataFormatsPrefixedIdentifier(t *testing.T) {
+	hook := newPrefixedIdentifierHook()
+	ans := hook.ListMetadataFormats(context.Background(), oaipmh.OAIPMHRequest{Identifier: "oai:korpus.cz:1"})
+	assert.True(t, ans.NoError())
+
+	ans = hook.ListMetadataFormats(context.Background(), oaipmh.OAIPMHRequest{Identifier: "oai:lindat.cz:1"})
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeIDDoesNotExist, ans.Errors[0].Code)
+}
+
+func TestListIdentifiersPrefixed(t *testing.T) {
+	hook := newPrefixedIdentifierHook()
+	ans := hook.ListIdentifiers(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	assert.True(t, ans.NoError())
+	assert.Equal(t, "oai:korpus.cz:1", ans.Data[0].Identifier)
+}
+
+func TestLegacyIdentifierInput(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		hook := newPrefixedIdentifierHook()
+		hook.conf.RepositoryInfo.StrictIdentifiers = strict
+		for _, identifier := range []string{"oai:korpus.cz:1", "1"} {
+			accepted := !strict || identifier == "oai:korpus.cz:1"
+			msg := fmt.Sprintf("strict: %t, identifier: %s", strict, identifier)
+
+			rec := hook.GetRecord(
+				context.Background(),
+				oaipmh.OAIPMHRequest{Verb: oaipmh.VerbGetRecord, MetadataPrefix: "oai_dc", Identifier: identifier},
+			)
+			assert.Equal(t, accepted, rec.NoError(), msg)
+			if accepted {
+				assert.Equal(t, "oai:korpus.cz:1", rec.Data.Header.Identifier, msg)
+			} else {
+				assert.Equal(t, oaipmh.ErrorCodeIDDoesNotExist, rec.Errors[0].Code, msg)
+			}
+
+			formats := hook.ListMetadataFormats(
+				context.Background(),
+				oaipmh.OAIPMHRequest{Verb: oaipmh.VerbListMetadataFormats, Identifier: identifier},
+			)
+			assert.Equal(t, accepted, formats.NoError(), msg)
+		}
+	}
+}
+
+func TestStrictIdentifiersSelfLink(t *testing.T) {
+	hook := newPrefixedIdentifierHook()
+	hook.conf.RepositoryInfo.StrictIdentifiers = true
+	// self-links come without a verb and use bare record IDs
+	ans := hook.GetRecord(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc", Identifier: "1"})
+	assert.True(t, ans.NoError())
+	assert.Equal(t, "oai:korpus.cz:1", ans.Data.Header.Identifier)
+}
@@ -18,6 +18,7 @@ package cnchook
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -29,107 +30,401 @@ import (
 	"golang.org/x/text/language/display"
 )
 
+// recordFromData converts DB data into a record of the required
+// metadata format. Deleted records contain only a header with
+// the `deleted` status. In case the metadata format is not
+// supported, false is returned.
+func (c *CNCHook) recordFromData(metadataPrefix string, data *cncdb.DBData) (oaipmh.OAIPMHRecord, bool) {
+	var record oaipmh.OAIPMHRecord
+	data = c.normalizeTextFields(data)
+	c.applyDefaultLocale(data)
+	switch metadataPrefix {
+	case formats.DublinCoreMetadataPrefix:
+		record = c.dcRecordFromData(data)
+	case formats.CMDIMetadataPrefix:
+		record = c.cmdiLindatClarinRecordFromData(data)
+	case formats.DataCiteMetadataPrefix:
+		record = c.dataCiteRecordFromData(data)
+	case formats.OLACMetadataPrefix:
+		record = c.olacRecordFromData(data)
+	default:
+		return record, false
+	}
+	if c.conf.Conversion.IncludeProvenance && data.Origin.BaseURL.Valid {
+		record.About = append(
+			record.About,
+			oaipmh.ElementWrapper{Value: c.getProvenance(metadataPrefix, data)},
+		)
+	}
+	if data.Deleted {
+		record.Header.Status = oaipmh.RecordStatusDeleted
+		record.Metadata = nil
+		record.About = nil
+	}
+	return record, true
+}
+
 func (c *CNCHook) dcRecordFromData(data *cncdb.DBData) oaipmh.OAIPMHRecord {
 	recordID := fmt.Sprint(data.ID)
-	metadata := formats.NewDublinCore()
-	metadata.Title.Add(data.TitleEN, "en")
-	metadata.Title.Add(data.TitleCS, "cs")
-	if data.DescCS.Valid {
-		metadata.Description.Add(data.DescCS.String, "cs")
-	}
-	if data.DescEN.Valid {
-		metadata.Description.Add(data.DescEN.String, "en")
+	metadata := formats.NewDublinCore(c.conf.Conversion.DCSchemaURL)
+	lang := primaryLanguage(data)
+	metadata.Title = orderByLanguage(getTitles(data), lang)
+	metadata.Description = orderByLanguage(c.getDescriptions(data), lang)
+	// oai_dc has no dedicated citation element
+	if citation := c.getCitation(data); citation != "" {
+		metadata.Description.Add(citation, "")
 	}
 	metadata.Date.Add(data.Date.In(time.UTC).Format(time.RFC3339), "")
-	for _, author := range getAuthorList(data) {
+	// oai_dc allows no refinements (like dcterms:available)
+	if data.DateAvailable.String != "" {
+		metadata.Date.Add(data.DateAvailable.String, "")
+	}
+	for _, author := range getAuthorList(data, c.conf.Conversion.MaxAuthors) {
 		if author.FirstName == "" {
 			metadata.Creator.Add(author.LastName, "")
 		} else {
 			metadata.Creator.Add(author.FirstName+" "+author.LastName, "")
 		}
 	}
+	// funders are contributors unless they are authors as well
+	for _, funding := range data.Funding {
+		isListed := func(v formats.MultilangElement) bool { return v.Value == funding.Organization }
+		if !slices.ContainsFunc(metadata.Creator, isListed) &&
+			!slices.ContainsFunc(metadata.Contributor, isListed) {
+			metadata.Contributor.Add(funding.Organization, "")
+		}
+	}
 	metadata.Identifier.Add(data.Name, "")
+	if pidURL := c.getPIDURL(data); pidURL != "" {
+		metadata.Identifier.Add(pidURL, "")
+	}
+	if data.SourceID.Valid {
+		metadata.Source.Add(c.getSourceRef(data), "")
+	}
 	metadata.Type.Add(data.Type, "")
 	metadata.Rights.Add(data.License, "")
+	for _, dist := range data.Distributions {
+		metadata.Relation.Add(dist.URL, "")
+		if !slices.ContainsFunc(
+			metadata.Format,
+			func(v formats.MultilangElement) bool { return v.Value == dist.MimeType },
+		) {
+			metadata.Format.Add(dist.MimeType, "")
+		}
+	}
 
 	switch MetadataType(data.Type) {
 	case CorpusMetadataType:
-		if data.CorpusData.Locale != nil {
-			base, _ := data.CorpusData.Locale.Base()
+		for _, base := range getLanguages(data) {
 			metadata.Language.Add(base.String(), "")
 		}
+		for _, period := range splitKeywords(data.CorpusData.TimePeriods.String) {
+			metadata.Coverage.Add(period, "")
+		}
+		for _, place := range splitKeywords(data.CorpusData.Places.String) {
+			metadata.Coverage.Add(place, "")
+		}
+	case ServiceMetadataType:
+	default:
+	}
+
+	record := oaipmh.NewOAIPMHRecord(metadata)
+	record.Header.Datestamp = data.Date.In(time.UTC)
+	record.Header.Identifier = c.oaiIdentifier(recordID)
+	record.Header.SetSpec = getSetSpecs(data)
+	return record
+}
+
+func (c *CNCHook) dataCiteRecordFromData(data *cncdb.DBData) oaipmh.OAIPMHRecord {
+	recordID := fmt.Sprint(data.ID)
+	metadata := formats.NewDataCite()
+	resource := &metadata.Payload
+	resource.Identifier = c.getDataCiteIdentifier(data)
+	for _, author := range getAuthorList(data, c.conf.Conversion.MaxAuthors) {
+		creator := formats.DataCiteCreator{
+			Name:       formats.DataCiteCreatorName{Type: formats.DataCiteNameTypePersonal, Value: author.LastName},
+			FamilyName: author.LastName,
+			GivenName:  author.FirstName,
+		}
+		if author.FirstName != "" {
+			creator.Name.Value = author.LastName + ", " + author.FirstName
+		}
+		resource.Creators = append(resource.Creators, creator)
+	}
+	if len(resource.Creators) == 0 {
+		// at least one creator is required
+		resource.Creators = append(
+			resource.Creators,
+			formats.DataCiteCreator{
+				Name: formats.DataCiteCreatorName{
+					Type:  formats.DataCiteNameTypeOrganizational,
+					Value: c.conf.MetadataValues.Publisher,
+				},
+			},
+		)
+	}
+	lang := primaryLanguage(data)
+	for _, title := range orderByLanguage(getTitles(data), lang) {
+		if title.Value != "" {
+			resource.Titles = append(resource.Titles, title)
+		}
+	}
+	resource.Publisher = c.conf.MetadataValues.Publisher
+	resource.PublicationYear = getIssuedYear(data.DateIssued)
+	if resource.PublicationYear == "" {
+		resource.PublicationYear = fmt.Sprint(data.Date.In(time.UTC).Year())
+	}
+	if data.License != "" {
+		rights := formats.DataCiteRights{Value: data.License}
+		if strings.HasPrefix(data.License, "http://") || strings.HasPrefix(data.License, "https://") {
+			rights = formats.DataCiteRights{URI: data.License}
+		}
+		resource.RightsList = &[]formats.DataCiteRights{rights}
+	}
+	if descs := orderByLanguage(c.getDescriptions(data), lang); len(descs) > 0 {
+		descriptions := make([]formats.DataCiteDescription, len(descs))
+		for i, desc := range descs {
+			descriptions[i] = formats.DataCiteDescription{
+				Lang:  desc.Lang,
+				Type:  formats.DataCiteDescriptionAbstract,
+				Value: desc.Value,
+			}
+		}
+		resource.Descriptions = &descriptions
+	}
+
+	switch MetadataType(data.Type) {
+	case CorpusMetadataType:
+		resource.ResourceType = formats.DataCiteResourceType{General: "Dataset", Value: data.Type}
+		if langs := getLanguages(data); len(langs) > 0 {
+			resource.Language = langs[0].String()
+		}
+	case ServiceMetadataType:
+		resource.ResourceType = formats.DataCiteResourceType{General: "Service", Value: data.Type}
+	default:
+		resource.ResourceType = formats.DataCiteResourceType{General: "Other", Value: data.Type}
+	}
+
+	record := oaipmh.NewOAIPMHRecord(metadata)
+	record.Header.Datestamp = data.Date.In(time.UTC)
+	record.Header.Identifier = c.oaiIdentifier(recordID)
+	record.Header.SetSpec = getSetSpecs(data)
+	return record
+}
+
+func (c *CNCHook) olacRecordFromData(data *cncdb.DBData) oaipmh.OAIPMHRecord {
+	recordID := fmt.Sprint(data.ID)
+	metadata := formats.NewOLAC()
+	lang := primaryLanguage(data)
+	for _, title := range orderByLanguage(getTitles(data), lang) {
+		metadata.Title.Add(title.Value, title.Lang)
+	}
+	for _, desc := range orderByLanguage(c.getDescriptions(data), lang) {
+		metadata.Description.Add(desc.Value, desc.Lang)
+	}
+	if citation := c.getCitation(data); citation != "" {
+		metadata.BibliographicCitation.Add(citation, "")
+	}
+	for _, author := range getAuthorList(data, c.conf.Conversion.MaxAuthors) {
+		name := author.LastName
+		if author.FirstName != "" {
+			name = author.LastName + ", " + author.FirstName
+		}
+		metadata.Creator.AddCoded(name, formats.OLACTypeRole, formats.OLACRoleAuthor)
+	}
+	for _, funding := range data.Funding {
+		if !slices.ContainsFunc(
+			metadata.Contributor,
+			func(v formats.OLACElement) bool { return v.Value == funding.Organization },
+		) {
+			metadata.Contributor.AddCoded(funding.Organization, formats.OLACTypeRole, formats.OLACRoleSponsor)
+		}
+	}
+	if c.conf.MetadataValues.Publisher != "" {
+		metadata.Publisher.Add(c.conf.MetadataValues.Publisher, "")
+	}
+	metadata.Modified.AddCoded(data.Date.In(time.UTC).Format(time.RFC3339), formats.OLACTypeW3CDTF, "")
+	if data.DateIssued != "" {
+		metadata.Issued.AddCoded(data.DateIssued, formats.OLACTypeW3CDTF, "")
+	}
+	if data.DateAvailable.String != "" {
+		metadata.Available.AddCoded(data.DateAvailable.String, formats.OLACTypeW3CDTF, "")
+	}
+	metadata.Identifier.Add(data.Name, "")
+	metadata.Identifier.AddCoded(c.getRecordURL(recordID), formats.OLACTypeURI, "")
+	if pidURL := c.getPIDURL(data); pidURL != "" {
+		metadata.Identifier.AddCoded(pidURL, formats.OLACTypeURI, "")
+	}
+	if data.SourceID.Valid {
+		metadata.Source.Add(c.getSourceRef(data), "")
+	}
+	if strings.HasPrefix(data.License, "http://") || strings.HasPrefix(data.License, "https://") {
+		metadata.License.AddCoded(data.License, formats.OLACTypeURI, "")
+
+	} else {
+		metadata.Rights.Add(data.License, "")
+	}
+	for _, dist := range data.Distributions {
+		metadata.Relation.AddCoded(dist.URL, formats.OLACTypeURI, "")
+		if !slices.ContainsFunc(
+			metadata.Format,
+			func(v formats.OLACElement) bool { return v.Value == dist.MimeType },
+		) {
+			metadata.Format.Add(dist.MimeType, "")
+		}
+	}
+
+	switch MetadataType(data.Type) {
+	case CorpusMetadataType:
+		metadata.Type.AddCoded("Text", formats.OLACTypeDCMIType, "")
+		metadata.Type.AddCoded("", formats.OLACTypeLinguisticType, formats.OLACLinguisticTypePrimaryText)
+		for _, base := range getLanguages(data) {
+			metadata.Language.AddCoded(
+				display.English.Languages().Name(base), formats.OLACTypeLanguage, base.ISO3())
+		}
+		for _, keyword := range splitKeywords(data.CorpusData.Keywords.String) {
+			metadata.Subject.Add(keyword, "")
+		}
+		for _, period := range splitKeywords(data.CorpusData.TimePeriods.String) {
+			metadata.Temporal.Add(period, "")
+		}
+		for _, place := range splitKeywords(data.CorpusData.Places.String) {
+			metadata.Spatial.Add(place, "")
+		}
 	case ServiceMetadataType:
+		metadata.Type.AddCoded("Service", formats.OLACTypeDCMIType, "")
 	default:
+		metadata.Type.Add(data.Type, "")
 	}
 
 	record := oaipmh.NewOAIPMHRecord(metadata)
 	record.Header.Datestamp = data.Date.In(time.UTC)
-	record.Header.Identifier = recordID
+	record.Header.Identifier = c.oaiIdentifier(recordID)
+	record.Header.SetSpec = getSetSpecs(data)
 	return record
 }
 
 func (c *CNCHook) cmdiLindatClarinRecordFromData(data *cncdb.DBData) oaipmh.OAIPMHRecord {
 	recordID := fmt.Sprint(data.ID)
+	lang := primaryLanguage(data)
 	profile := &profiles.CNCResourceProfile{
 		BibliographicInfo: components.BibliographicInfoComponent{
-			Titles: formats.MultilangArray{
-				{Lang: "en", Value: data.TitleEN},
-				{Lang: "cs", Value: data.TitleCS},
-			},
+			Titles: orderByLanguage(getTitles(data), lang),
 			Identifiers: []formats.TypedElement{
-				{Value: data.Name},
-			},
-			Authors: getAuthorList(data),
-			ContactPerson: components.ContactPersonComponent{
-				LastName:    data.ContactPerson.Lastname,
-				FirstName:   data.ContactPerson.Firstname,
-				Email:       data.ContactPerson.Email,
-				Affiliation: data.ContactPerson.Affiliation.String,
+				{Value: recordID, Type: IdentifierTypeInternal},
+				{Value: data.Name, Type: getIdentifierType(data.Name)},
+				{Value: c.getRecordURL(recordID), Type: IdentifierTypeURL},
 			},
+			Authors:       getAuthorList(data, c.conf.Conversion.MaxAuthors),
+			ContactPerson: c.getContactPerson(data),
 			Publishers: []string{
 				c.conf.MetadataValues.Publisher,
 			},
 		},
 		DataInfo: components.DataInfoComponent{
-			Type: data.Type,
-			Description: formats.MultilangArray{
-				{Lang: "en", Value: data.DescEN.String},
-				{Lang: "cs", Value: data.DescCS.String},
-			},
+			Type:        data.Type,
+			Description: orderByLanguage(c.getDescriptions(data), lang),
 		},
 		LicenseInfo: []profiles.LicenseElement{
 			{URI: data.License},
 		},
 	}
-	if data.DateIssued == "" {
+	if data.DateIssued != "" {
 		profile.BibliographicInfo.Dates = &components.DatesComponent{DateIssued: data.DateIssued}
 	}
-	metadata := formats.NewCMDI(profile)
-	metadata.Header.MdSelfLink = fmt.Sprintf("%s/record/%s?format=cmdi", c.conf.RepositoryInfo.BaseURL, recordID)
+	if data.DateAvailable.String != "" {
+		if profile.BibliographicInfo.Dates == nil {
+			profile.BibliographicInfo.Dates = &components.DatesComponent{}
+		}
+		profile.BibliographicInfo.Dates.Dates = append(
+			profile.BibliographicInfo.Dates.Dates,
+			formats.TypedElement{Type: DateTypeAvailable, Value: data.DateAvailable.String},
+		)
+	}
+	if len(data.Funding) > 0 {
+		funds := make([]components.FundingComponent, len(data.Funding))
+		for i, funding := range data.Funding {
+			funds[i] = components.FundingComponent{
+				Organization: funding.Organization,
+				Code:         funding.Code,
+				ProjectName:  funding.ProjectName,
+				FundsType:    funding.FundsType,
+			}
+		}
+		profile.BibliographicInfo.Funds = &funds
+	}
+	if relations := c.getRelations(data); len(relations) > 0 {
+		profile.RelationsInfo = &relations
+	}
+	metadata := formats.NewCMDI(profile, getCMDIEnvelope(c.conf))
+	metadata.Header.MdSelfLink = c.getRecordURL(recordID) + "?format=cmdi"
+	if pidURL := c.getPIDURL(data); pidURL != "" {
+		profile.BibliographicInfo.Identifiers = append(
+			profile.BibliographicInfo.Identifiers,
+			formats.TypedElement{Value: pidURL, Type: IdentifierTypePID},
+		)
+		metadata.Header.MdSelfLink = pidURL
+	}
 
 	switch MetadataType(data.Type) {
 	case CorpusMetadataType:
 		profile.DataInfo.SizeInfo = &[]components.SizeComponent{
 			{Size: fmt.Sprint(data.CorpusData.Size.Int64), Unit: "words"},
 		}
-		if data.CorpusData.Locale != nil {
-			base, _ := data.CorpusData.Locale.Base()
-			profile.DataInfo.Languages = &[]components.LanguageComponent{
-				{Name: display.English.Languages().Name(base), Code: base.String()},
+		if langs := getLanguages(data); len(langs) > 0 {
+			languages := make([]components.LanguageComponent, len(langs))
+			for i, base := range langs {
+				languages[i] = components.LanguageComponent{
+					Name: display.English.Languages().Name(base),
+					Code: base.String(),
+				}
 			}
+			profile.DataInfo.Languages = &languages
 		}
-		if data.CorpusData.Keywords.String != "" {
-			keywords := strings.Split(data.CorpusData.Keywords.String, ",")
+		if keywords := splitKeywords(data.CorpusData.Keywords.String); len(keywords) > 0 {
 			profile.DataInfo.Keywords = &keywords
 		}
-		metadata.Resources.ResourceProxyList = append(
-			metadata.Resources.ResourceProxyList,
-			formats.CMDIResourceProxy{
-				ID:           fmt.Sprintf("sp_%s", recordID),
-				ResourceType: formats.CMDIResourceType{MimeType: "text/html", Value: formats.RTSearchPage},
-				ResourceRef:  getKontextPath(data.Name),
-			},
-		)
+		setRegistryAttrs(&profile.DataInfo, data.RegistryAttrs, c.conf.Conversion.AnnotationTagsets)
+		timePeriods := splitKeywords(data.CorpusData.TimePeriods.String)
+		places := splitKeywords(data.CorpusData.Places.String)
+		if len(timePeriods) > 0 || len(places) > 0 {
+			profile.DataInfo.CollectionInfo = &components.CollectionInfoComponent{
+				TimePeriods: timePeriods,
+				Places:      places,
+			}
+		}
+		// restricted corpora cannot be queried without an access
+		// request so they link to the access page instead
+		if c.conf.Conversion.RestrictedAccess.Applies(data.ID, data.License) {
+			metadata.Resources.ResourceProxyList = append(
+				metadata.Resources.ResourceProxyList,
+				formats.CMDIResourceProxy{
+					ID:           fmt.Sprintf("lp_%s", recordID),
+					ResourceType: formats.CMDIResourceType{MimeType: "text/html", Value: formats.RTLandingPage},
+					ResourceRef:  c.getAccessPageURL(data),
+				},
+			)
+
+		} else {
+			metadata.Resources.ResourceProxyList = append(
+				metadata.Resources.ResourceProxyList,
+				formats.CMDIResourceProxy{
+					ID:           fmt.Sprintf("sp_%s", recordID),
+					ResourceType: formats.CMDIResourceType{MimeType: "text/html", Value: formats.RTSearchPage},
+					ResourceRef:  getKontextPath(c.conf.MetadataValues.KontextQueryTemplate, data.Name),
+				},
+			)
+		}
+		if fcsURL := c.getFCSEndpointURL(); fcsURL != "" {
+			metadata.Resources.ResourceProxyList = append(
+				metadata.Resources.ResourceProxyList,
+				formats.CMDIResourceProxy{
+					ID:           fmt.Sprintf("fcs_%s", recordID),
+					ResourceType: formats.CMDIResourceType{MimeType: formats.MimeTypeSRU, Value: formats.RTSearchService},
+					ResourceRef:  fcsURL,
+				},
+			)
+		}
 
 	case ServiceMetadataType:
 	default:
@@ -151,9 +446,21 @@ func (c *CNCHook) cmdiLindatClarinRecordFromData(data *cncdb.DBData) oaipmh.OAIP
 			},
 		)
 	}
+	for i, dist := range data.Distributions {
+		metadata.Resources.ResourceProxyList = append(
+			metadata.Resources.ResourceProxyList,
+			formats.CMDIResourceProxy{
+				ID:           fmt.Sprintf("dist_%s_%d", recordID, i+1),
+				ResourceType: formats.CMDIResourceType{MimeType: dist.MimeType, Value: formats.RTResource},
+				ResourceRef:  dist.URL,
+			},
+		)
+	}
+	sortResourceProxies(metadata.Resources.ResourceProxyList, c.conf.Conversion.ResourceProxyOrder)
 
 	record := oaipmh.NewOAIPMHRecord(metadata)
 	record.Header.Datestamp = data.Date.In(time.UTC)
-	record.Header.Identifier = recordID
+	record.Header.Identifier = c.oaiIdentifier(recordID)
+	record.Header.SetSpec = getSetSpecs(data)
 	return record
 }
@@ -0,0 +1,1032 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/czcorpus/cnc-vlo/cnchook/profiles"
+	"github.com/czcorpus/cnc-vlo/cnf"
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+	"github.com/czcorpus/cnc-vlo/oaipmh/formats"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func newTestHook() *CNCHook {
+	return NewCNCHook(
+		&cnf.Conf{
+			RepositoryInfo: cnf.RepositoryInfo{
+				Name:       "CNC metadata repository",
+				BaseURL:    "http://localhost:8080",
+				AdminEmail: []string{"admin@korpus.cz"},
+			},
+			MetadataValues: cnf.MetadataValues{
+				Publisher:            "UCNK",
+				SourceEntityBase:     "http://localhost:8080/record/",
+				PIDResolverURL:       "https://hdl.handle.net/",
+				KontextQueryTemplate: "https://www.korpus.cz/kontext/query?corpname={corpus}",
+			},
+		},
+		nil,
+	)
+}
+
+func newTestData() *cncdb.DBData {
+	return &cncdb.DBData{
+		ID:      42,
+		Date:    time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+		Type:    string(CorpusMetadataType),
+		Name:    "syn2020",
+		TitleEN: "SYN2020",
+		TitleCS: "SYN2020",
+		License: "https://creativecommons.org/licenses/by/4.0/",
+		Authors: "Jan Novák",
+	}
+}
+
+func TestRecordInMultipleSets(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.CorplistSetSpecs = []string{"public", "Korpusy_CNK"}
+	dc := hook.dcRecordFromData(data)
+	assert.Equal(t, []string{"public", "Korpusy_CNK"}, dc.Header.SetSpec)
+	cmdi := hook.cmdiLindatClarinRecordFromData(data)
+	assert.Equal(t, []string{"public", "Korpusy_CNK"}, cmdi.Header.SetSpec)
+}
+
+func TestRecordInKeywordSets(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.CorplistSetSpecs = []string{"public"}
+	data.KeywordIDs = []string{"fiction", "written text"}
+	record := hook.dcRecordFromData(data)
+	assert.Equal(t, []string{"public", "keyword:fiction", "keyword:written_text"}, record.Header.SetSpec)
+}
+
+func TestRecordWithoutSets(t *testing.T) {
+	hook := newTestHook()
+	record := hook.dcRecordFromData(newTestData())
+	assert.Empty(t, record.Header.SetSpec)
+}
+
+func TestCrosswalkContainsAllFormats(t *testing.T) {
+	hook := newTestHook()
+	out, err := xml.Marshal(hook.crosswalkFromData(newTestData()))
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `<crosswalk identifier="42">`)
+	assert.Contains(t, string(out), `<section metadataPrefix="oai_dc">`)
+	assert.Contains(t, string(out), `<section metadataPrefix="cmdi">`)
+	assert.Contains(t, string(out), "<oai_dc:dc ")
+	assert.Contains(t, string(out), "<cmd:CMD ")
+}
+
+func TestDeletedRecordHeaderOnly(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.Deleted = true
+	for _, prefix := range hook.SupportedMetadataPrefixes() {
+		record, ok := hook.recordFromData(prefix, data)
+		assert.True(t, ok)
+		assert.Equal(t, oaipmh.RecordStatusDeleted, record.Header.Status)
+		assert.Equal(t, data.Date, record.Header.Datestamp)
+		assert.Nil(t, record.Metadata)
+	}
+}
+
+func TestRecordFromDataUnknownFormat(t *testing.T) {
+	_, ok := newTestHook().recordFromData("marc21", newTestData())
+	assert.False(t, ok)
+}
+
+func TestAvailabilityDate(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.DateIssued = "2020-01-15"
+	data.DateAvailable = sql.NullString{String: "2021-06-01", Valid: true}
+
+	dc, err := xml.Marshal(hook.dcRecordFromData(data).Metadata)
+	assert.NoError(t, err)
+	assert.Contains(t, string(dc), "<dc:date>2021-06-01</dc:date>")
+	assert.NotContains(t, string(dc), "<dcterms:")
+
+	cmdi, err := xml.Marshal(hook.cmdiLindatClarinRecordFromData(data).Metadata)
+	assert.NoError(t, err)
+	assert.Contains(t, string(cmdi), `<cmdp:date type="available">2021-06-01</cmdp:date>`)
+}
+
+func TestCMDIDateIssued(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.DateIssued = "2020-01-15"
+	cmdi, err := xml.Marshal(hook.cmdiLindatClarinRecordFromData(data).Metadata)
+	assert.NoError(t, err)
+	assert.Contains(t, string(cmdi), "<cmdp:dates><cmdp:dateIssued>2020-01-15</cmdp:dateIssued></cmdp:dates>")
+}
+
+func TestCMDINoDates(t *testing.T) {
+	hook := newTestHook()
+	record := hook.cmdiLindatClarinRecordFromData(newTestData())
+	profile := record.Metadata.Value.(formats.CMDIFormat).Components.(*profiles.CNCResourceProfile)
+	assert.Nil(t, profile.BibliographicInfo.Dates)
+	cmdi, err := xml.Marshal(record.Metadata)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(cmdi), "cmdp:dates")
+	assert.NotContains(t, string(cmdi), "cmdp:dateIssued")
+}
+
+func TestNoAvailabilityDate(t *testing.T) {
+	dc, err := xml.Marshal(newTestHook().dcRecordFromData(newTestData()).Metadata)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(dc), "<dc:date>"))
+}
+
+func TestDerivedCorpusSource(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.SourceID = sql.NullInt64{Int64: 7, Valid: true}
+
+	dc, err := xml.Marshal(hook.dcRecordFromData(data).Metadata)
+	assert.NoError(t, err)
+	assert.Contains(t, string(dc), "<dc:source>http://localhost:8080/record/7</dc:source>")
+
+	cmdi, err := xml.Marshal(hook.cmdiLindatClarinRecordFromData(data).Metadata)
+	assert.NoError(t, err)
+	assert.Contains(
+		t, string(cmdi),
+		`<cmdp:relationsInfo><cmdp:relation type="isDerivedFrom">http://localhost:8080/record/7</cmdp:relation></cmdp:relationsInfo>`,
+	)
+}
+
+func TestNoSource(t *testing.T) {
+	hook := newTestHook()
+	dc, err := xml.Marshal(hook.dcRecordFromData(newTestData()).Metadata)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(dc), "<dc:source>")
+	cmdi, err := xml.Marshal(hook.cmdiLindatClarinRecordFromData(newTestData()).Metadata)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(cmdi), "relationsInfo")
+}
+
+func TestRegistryAttrsForTaggedCorpus(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.RegistryAttrs = cncdb.RegistryAttrs{
+		Tagsets:        []string{"cs_cnc2020"},
+		AlignedCorpora: []string{"intercorp_v16ud_en"},
+	}
+	out, err := xml.Marshal(hook.cmdiLindatClarinRecordFromData(data))
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `<cmdp:formats><cmdp:format cmdp:type="tagset"><cmdp:name>cs_cnc2020</cmdp:name></cmdp:format></cmdp:formats>`)
+	assert.Contains(t, string(out), "<cmdp:annotationInfo><cmdp:annotationType>tags</cmdp:annotationType><cmdp:annotationType>alignment</cmdp:annotationType></cmdp:annotationInfo>")
+}
+
+func TestRegistryAttrsMissing(t *testing.T) {
+	hook := newTestHook()
+	out, err := xml.Marshal(hook.cmdiLindatClarinRecordFromData(newTestData()))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "cmdp:annotationInfo")
+	assert.NotContains(t, string(out), "cmdp:formats")
+}
+
+func TestContactEmailRedactedByLicense(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.ContactRedaction.Licenses = []string{"https://creativecommons.org/licenses/by/4.0/"}
+	data := newTestData()
+	data.ContactPerson = cncdb.ContactPersonData{Firstname: "Jan", Lastname: "Novák", Email: "jan.novak@example.com"}
+	for _, prefix := range []string{"oai_dc", "cmdi"} {
+		record, ok := hook.recordFromData(prefix, data)
+		assert.True(t, ok)
+		out, err := xml.Marshal(record)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(out), "jan.novak@example.com")
+	}
+	record, _ := hook.recordFromData("cmdi", data)
+	out, _ := xml.Marshal(record)
+	assert.Contains(t, string(out), "admin@korpus.cz")
+}
+
+func TestContactEmailRedactedByRecordID(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.ContactRedaction.RecordIDs = []int{42}
+	data := newTestData()
+	data.ContactPerson = cncdb.ContactPersonData{Firstname: "Jan", Lastname: "Novák", Email: "jan.novak@example.com"}
+	assert.Equal(t, "admin@korpus.cz", hook.getContactEmail(data))
+}
+
+func TestContactEmailNotRedacted(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.ContactRedaction.RecordIDs = []int{43}
+	data := newTestData()
+	data.ContactPerson = cncdb.ContactPersonData{Firstname: "Jan", Lastname: "Novák", Email: "jan.novak@example.com"}
+	record, _ := hook.recordFromData("cmdi", data)
+	out, err := xml.Marshal(record)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "jan.novak@example.com")
+}
+
+func TestCachedMetadataFormats(t *testing.T) {
+	hook := newTestHook()
+	ans := hook.ListMetadataFormats(context.Background(), oaipmh.OAIPMHRequest{})
+	assert.Equal(t, getMetadataFormats(hook.conf), ans.Data)
+}
+
+func TestCoverageForCorpus(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.CorpusData.TimePeriods = sql.NullString{String: "2016-2019", Valid: true}
+	data.CorpusData.Places = sql.NullString{String: "Czech Republic, Slovakia", Valid: true}
+
+	out, err := xml.Marshal(hook.dcRecordFromData(data))
+	assert.NoError(t, err)
+	assert.Contains(
+		t,
+		string(out),
+		"<dc:coverage>2016-2019</dc:coverage><dc:coverage>Czech Republic</dc:coverage><dc:coverage>Slovakia</dc:coverage>",
+	)
+
+	out, err = xml.Marshal(hook.cmdiLindatClarinRecordFromData(data))
+	assert.NoError(t, err)
+	assert.Contains(
+		t,
+		string(out),
+		"<cmdp:collectionInfo><cmdp:timePeriod>2016-2019</cmdp:timePeriod>"+
+			"<cmdp:place>Czech Republic</cmdp:place><cmdp:place>Slovakia</cmdp:place>",
+	)
+}
+
+func TestExportRecords(t *testing.T) {
+	hook := newTestHook()
+	data1 := newTestData()
+	data2 := newTestData()
+	data2.ID = 43
+	data2.Name = "syn2015"
+	var buff bytes.Buffer
+	num, err := hook.exportRecords(&buff, "oai_dc", []cncdb.DBData{*data1, *data2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, num)
+	var doc struct {
+		Identifiers []string `xml:"ListRecords>record>header>identifier"`
+	}
+	assert.NoError(t, xml.Unmarshal(buff.Bytes(), &doc))
+	assert.Equal(t, []string{"42", "43"}, doc.Identifiers)
+	assert.Contains(t, buff.String(), `<request verb="ListRecords" metadataPrefix="oai_dc">http://localhost:8080/oai</request>`)
+}
+
+func TestExportRecordsUnknownFormat(t *testing.T) {
+	hook := newTestHook()
+	var buff bytes.Buffer
+	_, err := hook.exportRecords(&buff, "marc21", []cncdb.DBData{*newTestData()})
+	assert.Error(t, err)
+}
+
+func getProxyTypes(record oaipmh.OAIPMHRecord) []formats.ResourceType {
+	cmdi := record.Metadata.Value.(formats.CMDIFormat)
+	ans := make([]formats.ResourceType, len(cmdi.Resources.ResourceProxyList))
+	for i, proxy := range cmdi.Resources.ResourceProxyList {
+		ans[i] = proxy.ResourceType.Value
+	}
+	return ans
+}
+
+func TestResourceProxyDefaultOrder(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.Link = sql.NullString{String: "https://wiki.korpus.cz/doku.php/cnk:syn2020", Valid: true}
+	assert.Equal(
+		t,
+		[]formats.ResourceType{formats.RTSearchPage, formats.RTResource},
+		getProxyTypes(hook.cmdiLindatClarinRecordFromData(data)),
+	)
+}
+
+func TestResourceProxyConfiguredOrderCorpus(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.ResourceProxyOrder = []string{"Resource", "SearchPage"}
+	data := newTestData()
+	data.Link = sql.NullString{String: "https://wiki.korpus.cz/doku.php/cnk:syn2020", Valid: true}
+	assert.Equal(
+		t,
+		[]formats.ResourceType{formats.RTResource, formats.RTSearchPage},
+		getProxyTypes(hook.cmdiLindatClarinRecordFromData(data)),
+	)
+}
+
+func TestResourceProxyConfiguredOrderService(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.ResourceProxyOrder = []string{"SearchPage"}
+	data := newTestData()
+	data.Type = string(ServiceMetadataType)
+	data.Link = sql.NullString{String: "https://www.korpus.cz/treq", Valid: true}
+	assert.Equal(
+		t,
+		[]formats.ResourceType{formats.RTResource},
+		getProxyTypes(hook.cmdiLindatClarinRecordFromData(data)),
+	)
+}
+
+func newRestrictedAccessHook() *CNCHook {
+	hook := newTestHook()
+	hook.conf.Conversion.RestrictedAccess = cnf.AccessRestriction{
+		Licenses:      []string{"https://www.korpus.cz/restricted"},
+		AccessPageURL: "https://www.korpus.cz/access?corpus={corpus}&id={id}",
+	}
+	return hook
+}
+
+func TestOpenCorpusSearchPage(t *testing.T) {
+	hook := newRestrictedAccessHook()
+	record := hook.cmdiLindatClarinRecordFromData(newTestData())
+	assert.Equal(t, []formats.ResourceType{formats.RTSearchPage}, getProxyTypes(record))
+	cmdi := record.Metadata.Value.(formats.CMDIFormat)
+	assert.Equal(
+		t,
+		"https://www.korpus.cz/kontext/query?corpname=syn2020",
+		cmdi.Resources.ResourceProxyList[0].ResourceRef,
+	)
+}
+
+func TestConfiguredKontextSearchPage(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.MetadataValues.KontextQueryTemplate = "https://staging.korpus.cz/kontext/query?q=&corpname={corpus}"
+	data := newTestData()
+	data.Name = "omezeni/syn2020"
+	cmdi := hook.cmdiLindatClarinRecordFromData(data).Metadata.Value.(formats.CMDIFormat)
+	assert.Equal(
+		t,
+		"https://staging.korpus.cz/kontext/query?q=&corpname=omezeni%2Fsyn2020",
+		cmdi.Resources.ResourceProxyList[0].ResourceRef,
+	)
+}
+
+func TestRestrictedCorpusAccessPage(t *testing.T) {
+	hook := newRestrictedAccessHook()
+	data := newTestData()
+	data.Name = "syn2020 restricted"
+	data.License = "https://www.korpus.cz/restricted"
+	record := hook.cmdiLindatClarinRecordFromData(data)
+	assert.Equal(t, []formats.ResourceType{formats.RTLandingPage}, getProxyTypes(record))
+	cmdi := record.Metadata.Value.(formats.CMDIFormat)
+	assert.Equal(
+		t,
+		"https://www.korpus.cz/access?corpus=syn2020+restricted&id=42",
+		cmdi.Resources.ResourceProxyList[0].ResourceRef,
+	)
+}
+
+func TestRestrictedCorpusByRecordID(t *testing.T) {
+	hook := newRestrictedAccessHook()
+	hook.conf.Conversion.RestrictedAccess.RecordIDs = []int{42}
+	assert.Equal(
+		t,
+		[]formats.ResourceType{formats.RTLandingPage},
+		getProxyTypes(hook.cmdiLindatClarinRecordFromData(newTestData())),
+	)
+}
+
+func TestRecordWithPID(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.PID = sql.NullString{String: "hdl:11234/1-1234", Valid: true}
+
+	out, err := xml.Marshal(hook.dcRecordFromData(data))
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "<dc:identifier>https://hdl.handle.net/11234/1-1234</dc:identifier>")
+
+	record := hook.cmdiLindatClarinRecordFromData(data)
+	cmdi := record.Metadata.Value.(formats.CMDIFormat)
+	assert.Equal(t, "https://hdl.handle.net/11234/1-1234", cmdi.Header.MdSelfLink)
+	out, err = xml.Marshal(record)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `<cmdp:identifier type="PID">https://hdl.handle.net/11234/1-1234</cmdp:identifier>`)
+}
+
+func TestRecordWithoutPID(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+
+	out, err := xml.Marshal(hook.dcRecordFromData(data))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "hdl.handle.net")
+
+	record := hook.cmdiLindatClarinRecordFromData(data)
+	cmdi := record.Metadata.Value.(formats.CMDIFormat)
+	assert.Equal(t, "http://localhost:8080/record/42?format=cmdi", cmdi.Header.MdSelfLink)
+	out, err = xml.Marshal(record)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), `type="PID"`)
+}
+
+func TestNullDescriptionsOmitted(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	out, err := xml.Marshal(hook.dcRecordFromData(data))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "dc:description")
+	out, err = xml.Marshal(hook.cmdiLindatClarinRecordFromData(data))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "cmdp:description")
+}
+
+func TestNullDescriptionsDefault(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.MetadataValues.DefaultDescription = "A corpus of the CNC"
+	data := newTestData()
+	out, err := xml.Marshal(hook.dcRecordFromData(data))
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `<dc:description xml:lang="en">A corpus of the CNC</dc:description>`)
+	out, err = xml.Marshal(hook.cmdiLindatClarinRecordFromData(data))
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `<cmdp:description xml:lang="en">A corpus of the CNC</cmdp:description>`)
+}
+
+func TestSingleDescription(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.MetadataValues.DefaultDescription = "A corpus of the CNC"
+	data := newTestData()
+	data.DescCS = sql.NullString{String: "Korpus ČNK", Valid: true}
+	out, err := xml.Marshal(hook.cmdiLindatClarinRecordFromData(data))
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `<cmdp:description xml:lang="cs">Korpus ČNK</cmdp:description>`)
+	assert.NotContains(t, string(out), `xml:lang="en">A corpus`)
+}
+
+func TestMismatchedLanguagePairing(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.DescCS = sql.NullString{String: "Korpus ČNK", Valid: true}
+	dc := hook.dcRecordFromData(data).Metadata.Value.(formats.DublinCore)
+	assert.Equal(t, "cs", dc.Title[0].Lang)
+	assert.Equal(t, "en", dc.Title[1].Lang)
+	assert.Equal(t, formats.MultilangArray{{Lang: "cs", Value: "Korpus ČNK"}}, dc.Description)
+	out, err := xml.Marshal(hook.cmdiLindatClarinRecordFromData(data))
+	assert.NoError(t, err)
+	assert.Regexp(t, `<cmdp:title xml:lang="cs">.*</cmdp:title><cmdp:title xml:lang="en">`, string(out))
+}
+
+func TestMatchingLanguagePairing(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.DescEN = sql.NullString{String: "CNC corpus", Valid: true}
+	data.DescCS = sql.NullString{String: "Korpus ČNK", Valid: true}
+	dc := hook.dcRecordFromData(data).Metadata.Value.(formats.DublinCore)
+	assert.Equal(t, "en", dc.Title[0].Lang)
+	assert.Equal(t, "en", dc.Description[0].Lang)
+	assert.Equal(t, "cs", dc.Description[1].Lang)
+}
+
+func TestDublinCoreSchemaOverride(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.DCSchemaURL = "http://schemas.local/oai_dc.xsd"
+	out, err := xml.Marshal(hook.dcRecordFromData(newTestData()))
+	assert.NoError(t, err)
+	assert.Contains(
+		t,
+		string(out),
+		`xsi:schemaLocation="http://www.openarchives.org/OAI/2.0/oai_dc/ http://schemas.local/oai_dc.xsd"`,
+	)
+}
+
+func TestDublinCoreSchemaDefault(t *testing.T) {
+	hook := newTestHook()
+	out, err := xml.Marshal(hook.dcRecordFromData(newTestData()))
+	assert.NoError(t, err)
+	assert.Contains(
+		t,
+		string(out),
+		`xsi:schemaLocation="http://www.openarchives.org/OAI/2.0/oai_dc/ http://www.openarchives.org/OAI/2.0/oai_dc.xsd"`,
+	)
+}
+
+func TestNormalizedTextFields(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.TitleEN = "\uFEFFSYN2020 "
+	data.DescEN = sql.NullString{String: " A  representative corpus\n", Valid: true}
+	data.Authors = "\uFEFF Jan Novák;  Petr Dvořák "
+	data.CorpusData.Keywords = sql.NullString{String: "\uFEFFwritten, news ", Valid: true}
+
+	record, ok := hook.recordFromData("oai_dc", data)
+	assert.True(t, ok)
+	out, err := xml.Marshal(record)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `<dc:title xml:lang="en">SYN2020</dc:title>`)
+	assert.Contains(t, string(out), "<dc:description xml:lang=\"en\">A  representative corpus</dc:description>")
+	assert.Contains(t, string(out), "<dc:creator>Jan Novák</dc:creator><dc:creator>Petr Dvořák</dc:creator>")
+	assert.NotContains(t, string(out), "\uFEFF")
+
+	hook.conf.Conversion.CollapseWhitespace = true
+	record, _ = hook.recordFromData("cmdi", data)
+	out, err = xml.Marshal(record)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `<cmdp:description xml:lang="en">A representative corpus</cmdp:description>`)
+	assert.Contains(t, string(out), "<cmdp:keyword>written</cmdp:keyword>")
+	assert.NotContains(t, string(out), "\uFEFF")
+}
+
+func TestNormalizedAuthorLines(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.CollapseWhitespace = true
+	data := newTestData()
+	data.Authors = "\uFEFFJan  Novák \r\n Petr\tSvoboda\n"
+
+	record, ok := hook.recordFromData("oai_dc", data)
+	assert.True(t, ok)
+	out, err := xml.Marshal(record)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "<dc:creator>Jan Novák</dc:creator><dc:creator>Petr Svoboda</dc:creator>")
+}
+
+func TestCMDIMultipleIdentifiers(t *testing.T) {
+	hook := newTestHook()
+	out, err := xml.Marshal(hook.cmdiLindatClarinRecordFromData(newTestData()))
+	assert.NoError(t, err)
+	assert.Contains(
+		t,
+		string(out),
+		"<cmdp:identifiers>"+
+			`<cmdp:identifier type="internal">42</cmdp:identifier>`+
+			`<cmdp:identifier type="local">syn2020</cmdp:identifier>`+
+			`<cmdp:identifier type="URL">http://localhost:8080/record/42</cmdp:identifier>`+
+			"</cmdp:identifiers>",
+	)
+}
+
+func newOriginData() *cncdb.DBData {
+	data := newTestData()
+	data.Origin = cncdb.OriginData{
+		BaseURL:    sql.NullString{String: "https://upstream.example.org/oai", Valid: true},
+		Identifier: sql.NullString{String: "oai:upstream:123", Valid: true},
+		Datestamp:  sql.NullTime{Time: time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC), Valid: true},
+		Synced:     sql.NullTime{Time: time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC), Valid: true},
+	}
+	return data
+}
+
+func TestProvenanceSerialization(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.IncludeProvenance = true
+	record, ok := hook.recordFromData("oai_dc", newOriginData())
+	assert.True(t, ok)
+	out, err := xml.Marshal(record)
+	assert.NoError(t, err)
+	assert.Contains(
+		t,
+		string(out),
+		`</metadata><about><provenance xmlns="http://www.openarchives.org/OAI/2.0/provenance" `+
+			`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" `+
+			`xsi:schemaLocation="http://www.openarchives.org/OAI/2.0/provenance http://www.openarchives.org/OAI/2.0/provenance.xsd">`+
+			`<originDescription harvestDate="2024-03-01T10:30:00Z" altered="true">`+
+			`<baseURL>https://upstream.example.org/oai</baseURL>`+
+			`<identifier>oai:upstream:123</identifier>`+
+			`<datestamp>2024-02-01T08:00:00Z</datestamp>`+
+			`<metadataNamespace>http://www.openarchives.org/OAI/2.0/oai_dc/</metadataNamespace>`+
+			`</originDescription></provenance></about></OAIPMHRecord>`,
+	)
+}
+
+func TestProvenanceDisabled(t *testing.T) {
+	hook := newTestHook()
+	record, _ := hook.recordFromData("oai_dc", newOriginData())
+	assert.Empty(t, record.About)
+}
+
+func TestProvenanceWithoutOrigin(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.IncludeProvenance = true
+	record, _ := hook.recordFromData("cmdi", newTestData())
+	assert.Empty(t, record.About)
+}
+
+func newCitedData() *cncdb.DBData {
+	data := newTestData()
+	data.Authors = "Jan Novák; Svoboda, Petr Karel"
+	data.DateIssued = "2020-05-01"
+	data.PID = sql.NullString{String: "doi:10.1234/syn2020", Valid: true}
+	return data
+}
+
+func TestCitationAPA(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.CitationFormat = cnf.CitationFormatAPA
+	assert.Equal(
+		t,
+		"Novák, J., & Svoboda, P. K. (2020). SYN2020. UCNK. https://doi.org/10.1234/syn2020",
+		hook.getCitation(newCitedData()),
+	)
+}
+
+func TestCitationPlain(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.CitationFormat = cnf.CitationFormatPlain
+	assert.Equal(
+		t,
+		"Jan Novák, Petr Karel Svoboda: SYN2020. UCNK, 2020. https://doi.org/10.1234/syn2020",
+		hook.getCitation(newCitedData()),
+	)
+}
+
+func TestCitationDublinCore(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.CitationFormat = cnf.CitationFormatAPA
+	out, err := xml.Marshal(hook.dcRecordFromData(newCitedData()))
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "<dc:description>Novák, J., &amp; Svoboda, P. K. (2020).")
+	assert.NotContains(t, string(out), "dcterms")
+}
+
+func TestCitationWithoutDOI(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.CitationFormat = cnf.CitationFormatAPA
+	data := newCitedData()
+	data.PID.String = "11234/1-5678"
+	assert.Empty(t, hook.getCitation(data))
+	hook.conf.Conversion.CitationFormat = ""
+	assert.Empty(t, hook.getCitation(newCitedData()))
+}
+
+func newDistributedData() *cncdb.DBData {
+	data := newTestData()
+	data.Distributions = []cncdb.Distribution{
+		{URL: "https://data.korpus.cz/syn2020.vert.gz", MimeType: "application/gzip", Size: sql.NullInt64{Int64: 1024, Valid: true}},
+		{URL: "https://data.korpus.cz/syn2020.conllu", MimeType: "text/plain"},
+	}
+	return data
+}
+
+func TestDistributionsCMDI(t *testing.T) {
+	hook := newTestHook()
+	out, err := xml.Marshal(hook.cmdiLindatClarinRecordFromData(newDistributedData()))
+	assert.NoError(t, err)
+	assert.Contains(
+		t,
+		string(out),
+		`<cmd:ResourceProxy id="dist_42_1"><cmd:ResourceType mimetype="application/gzip">Resource</cmd:ResourceType>`+
+			`<cmd:ResourceRef>https://data.korpus.cz/syn2020.vert.gz</cmd:ResourceRef></cmd:ResourceProxy>`+
+			`<cmd:ResourceProxy id="dist_42_2"><cmd:ResourceType mimetype="text/plain">Resource</cmd:ResourceType>`+
+			`<cmd:ResourceRef>https://data.korpus.cz/syn2020.conllu</cmd:ResourceRef></cmd:ResourceProxy>`,
+	)
+}
+
+func TestDistributionsDublinCore(t *testing.T) {
+	hook := newTestHook()
+	data := newDistributedData()
+	data.Distributions = append(
+		data.Distributions,
+		cncdb.Distribution{URL: "https://data.korpus.cz/syn2020.txt", MimeType: "text/plain"},
+	)
+	dc := hook.dcRecordFromData(data).Metadata.Value.(formats.DublinCore)
+	assert.Equal(
+		t,
+		formats.MultilangArray{
+			{Value: "https://data.korpus.cz/syn2020.vert.gz"},
+			{Value: "https://data.korpus.cz/syn2020.conllu"},
+			{Value: "https://data.korpus.cz/syn2020.txt"},
+		},
+		dc.Relation,
+	)
+	assert.Equal(t, formats.MultilangArray{{Value: "application/gzip"}, {Value: "text/plain"}}, dc.Format)
+}
+
+func TestDefaultCorpusLanguage(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.DefaultCorpusLanguage = "cs"
+	data := newTestData()
+	record, ok := hook.recordFromData(formats.DublinCoreMetadataPrefix, data)
+	assert.True(t, ok)
+	out, err := xml.Marshal(record.Metadata)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "<dc:language>cs</dc:language>")
+	assert.Nil(t, data.CorpusData.Locale)
+
+	record, ok = hook.recordFromData(formats.CMDIMetadataPrefix, data)
+	assert.True(t, ok)
+	out, err = xml.Marshal(record.Metadata)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "<cmdp:code>cs</cmdp:code>")
+}
+
+func TestDefaultCorpusLanguageDisabled(t *testing.T) {
+	record, ok := newTestHook().recordFromData(formats.DublinCoreMetadataPrefix, newTestData())
+	assert.True(t, ok)
+	out, err := xml.Marshal(record.Metadata)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "<dc:language>")
+}
+
+func TestDefaultCorpusLanguageKeepsLocale(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.DefaultCorpusLanguage = "cs"
+	data := newTestData()
+	en := language.English
+	data.CorpusData.Locale = &en
+	hook.applyDefaultLocale(data)
+	assert.Equal(t, language.English, *data.CorpusData.Locale)
+
+	data = newTestData()
+	data.CorpusData.ParallelLocales = []language.Tag{language.English, language.German}
+	hook.applyDefaultLocale(data)
+	assert.Nil(t, data.CorpusData.Locale)
+
+	data = newTestData()
+	data.Type = string(ServiceMetadataType)
+	hook.applyDefaultLocale(data)
+	assert.Nil(t, data.CorpusData.Locale)
+}
+
+func getFCSProxy(t *testing.T, record oaipmh.OAIPMHRecord) *formats.CMDIResourceProxy {
+	cmdi, ok := record.Metadata.Value.(formats.CMDIFormat)
+	assert.True(t, ok)
+	for _, proxy := range cmdi.Resources.ResourceProxyList {
+		if proxy.ResourceType.Value == formats.RTSearchService {
+			return &proxy
+		}
+	}
+	return nil
+}
+
+func TestFCSEndpointProxy(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.FCSEndpoint = cnf.FCSEndpoint{
+		URL: "https://www.korpus.cz/fcs/sru", Version: cnf.FCSVersion2}
+	proxy := getFCSProxy(t, hook.cmdiLindatClarinRecordFromData(newTestData()))
+	assert.NotNil(t, proxy)
+	assert.Equal(t, "fcs_42", proxy.ID)
+	assert.Equal(t, "application/sru+xml", proxy.ResourceType.MimeType)
+	assert.Equal(t, "https://www.korpus.cz/fcs/sru?version=2.0", proxy.ResourceRef)
+
+	out, err := xml.Marshal(proxy)
+	assert.NoError(t, err)
+	assert.Contains(
+		t,
+		string(out),
+		`<cmd:ResourceType mimetype="application/sru+xml">SearchService</cmd:ResourceType>`,
+	)
+}
+
+func TestFCSEndpointProxyVersion1(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.FCSEndpoint = cnf.FCSEndpoint{
+		URL: "https://www.korpus.cz/fcs/sru?x-fcs-context=syn", Version: cnf.FCSVersion1}
+	proxy := getFCSProxy(t, hook.cmdiLindatClarinRecordFromData(newTestData()))
+	assert.NotNil(t, proxy)
+	assert.Equal(t, "https://www.korpus.cz/fcs/sru?version=1.2&x-fcs-context=syn", proxy.ResourceRef)
+}
+
+func TestFCSEndpointProxyDisabled(t *testing.T) {
+	hook := newTestHook()
+	assert.Nil(t, getFCSProxy(t, hook.cmdiLindatClarinRecordFromData(newTestData())))
+
+	hook.conf.Conversion.FCSEndpoint = cnf.FCSEndpoint{URL: "https://www.korpus.cz/fcs/sru"}
+	data := newTestData()
+	data.Type = string(ServiceMetadataType)
+	assert.Nil(t, getFCSProxy(t, hook.cmdiLindatClarinRecordFromData(data)))
+}
+
+func TestCMDIVersionFromConfig(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.CMDIVersion = "1.3"
+	cmdi, ok := hook.cmdiLindatClarinRecordFromData(newTestData()).Metadata.Value.(formats.CMDIFormat)
+	assert.True(t, ok)
+	assert.Equal(t, "1.3", cmdi.Version)
+}
+
+func TestDataCiteRecord(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.PID = sql.NullString{String: "https://doi.org/10.1234/syn2020", Valid: true}
+	data.DateIssued = "2020-06-01"
+	data.DescEN = sql.NullString{String: "Representative corpus", Valid: true}
+	record, ok := hook.recordFromData(formats.DataCiteMetadataPrefix, data)
+	assert.True(t, ok)
+	out, err := xml.Marshal(record.Metadata.Value)
+	assert.NoError(t, err)
+
+	var doc struct {
+		SchemaVersion string `xml:"schemaVersion"`
+		Resource      struct {
+			XMLName    xml.Name
+			Identifier struct {
+				Type  string `xml:"identifierType,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"identifier"`
+			Creators        []string `xml:"creators>creator>creatorName"`
+			Titles          []string `xml:"titles>title"`
+			Publisher       string   `xml:"publisher"`
+			PublicationYear string   `xml:"publicationYear"`
+			ResourceType    struct {
+				General string `xml:"resourceTypeGeneral,attr"`
+			} `xml:"resourceType"`
+			Rights []struct {
+				URI string `xml:"rightsURI,attr"`
+			} `xml:"rightsList>rights"`
+		} `xml:"payload>resource"`
+	}
+	assert.NoError(t, xml.Unmarshal(out, &doc))
+	assert.Equal(t, "4", doc.SchemaVersion)
+	assert.Equal(t, formats.DataCiteNamespace, doc.Resource.XMLName.Space)
+	assert.Equal(t, "DOI", doc.Resource.Identifier.Type)
+	assert.Equal(t, "10.1234/syn2020", doc.Resource.Identifier.Value)
+	assert.Equal(t, []string{"Novák, Jan"}, doc.Resource.Creators)
+	assert.Equal(t, []string{"SYN2020", "SYN2020"}, doc.Resource.Titles)
+	assert.Equal(t, "UCNK", doc.Resource.Publisher)
+	assert.Equal(t, "2020", doc.Resource.PublicationYear)
+	assert.Equal(t, "Dataset", doc.Resource.ResourceType.General)
+	assert.Len(t, doc.Resource.Rights, 1)
+	assert.Equal(t, "https://creativecommons.org/licenses/by/4.0/", doc.Resource.Rights[0].URI)
+}
+
+func TestDataCiteRecordFallbacks(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.Authors = ""
+	record := hook.dataCiteRecordFromData(data)
+	resource := record.Metadata.Value.(formats.DataCite).Payload
+	assert.Equal(t, formats.DataCiteIdentifier{Type: "URL", Value: "http://localhost:8080/record/42"}, resource.Identifier)
+	assert.Len(t, resource.Creators, 1)
+	assert.Equal(t, formats.DataCiteNameTypeOrganizational, resource.Creators[0].Name.Type)
+	assert.Equal(t, "UCNK", resource.Creators[0].Name.Value)
+	// no issue date, the datestamp year is used
+	assert.Equal(t, "2024", resource.PublicationYear)
+}
+
+func TestDataCiteFormatListed(t *testing.T) {
+	hook := newTestHook()
+	assert.Contains(t, hook.SupportedMetadataPrefixes(), "oai_datacite")
+	ans := hook.ListMetadataFormats(context.Background(), oaipmh.OAIPMHRequest{})
+	assert.Contains(t, ans.Data, formats.GetDataCiteFormat())
+}
+
+func newFundedTestData() *cncdb.DBData {
+	data := newTestData()
+	data.Funding = []cncdb.Funding{
+		{Organization: "Ministry of Education, Youth and Sports", Code: "LM2023044", FundsType: "nationalFunds"},
+		{Organization: "Ministry of Education, Youth and Sports", Code: "LM2018137", FundsType: "nationalFunds"},
+		{Organization: "Czech Science Foundation", Code: "GA20-12345S", ProjectName: "SYN", FundsType: "nationalFunds"},
+	}
+	return data
+}
+
+func TestDCFundersAsContributors(t *testing.T) {
+	hook := newTestHook()
+	dc, err := xml.Marshal(hook.dcRecordFromData(newFundedTestData()).Metadata)
+	assert.NoError(t, err)
+	assert.Contains(
+		t, string(dc),
+		"<dc:contributor>Ministry of Education, Youth and Sports</dc:contributor>"+
+			"<dc:contributor>Czech Science Foundation</dc:contributor>",
+	)
+	assert.Equal(t, 2, strings.Count(string(dc), "<dc:contributor>"))
+	assert.Contains(t, string(dc), "<dc:creator>Jan Novák</dc:creator>")
+}
+
+func TestDCFunderAlsoAuthor(t *testing.T) {
+	hook := newTestHook()
+	data := newFundedTestData()
+	data.Authors = "ÚČNK"
+	data.Funding = append(data.Funding, cncdb.Funding{Organization: "ÚČNK", FundsType: "ownFunds"})
+	dc, err := xml.Marshal(hook.dcRecordFromData(data).Metadata)
+	assert.NoError(t, err)
+	assert.Contains(t, string(dc), "<dc:creator>ÚČNK</dc:creator>")
+	assert.NotContains(t, string(dc), "<dc:contributor>ÚČNK</dc:contributor>")
+	assert.Contains(t, string(dc), "<dc:contributor>Ministry of Education, Youth and Sports</dc:contributor>")
+}
+
+func TestDCNoFunding(t *testing.T) {
+	hook := newTestHook()
+	dc, err := xml.Marshal(hook.dcRecordFromData(newTestData()).Metadata)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(dc), "dc:contributor")
+	cmdi, err := xml.Marshal(hook.cmdiLindatClarinRecordFromData(newTestData()).Metadata)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(cmdi), "cmdp:funding")
+}
+
+func TestCMDIFunding(t *testing.T) {
+	hook := newTestHook()
+	cmdi, err := xml.Marshal(hook.cmdiLindatClarinRecordFromData(newFundedTestData()).Metadata)
+	assert.NoError(t, err)
+	assert.Contains(
+		t, string(cmdi),
+		"<cmdp:funds><cmdp:organization>Czech Science Foundation</cmdp:organization>"+
+			"<cmdp:code>GA20-12345S</cmdp:code><cmdp:projectName>SYN</cmdp:projectName>"+
+			"<cmdp:fundsType>nationalFunds</cmdp:fundsType></cmdp:funds>",
+	)
+	assert.Equal(t, 3, strings.Count(string(cmdi), "<cmdp:funds>"))
+}
+
+func TestOLACRecord(t *testing.T) {
+	hook := newTestHook()
+	data := newFundedTestData()
+	locale := language.Czech
+	data.CorpusData.Locale = &locale
+	data.CorpusData.Keywords = sql.NullString{String: "written, fiction", Valid: true}
+	data.DateIssued = "2020-06-01"
+	record, ok := hook.recordFromData(formats.OLACMetadataPrefix, data)
+	assert.True(t, ok)
+	out, err := xml.Marshal(record.Metadata.Value)
+	assert.NoError(t, err)
+
+	// namespace declarations
+	var root struct {
+		XMLName xml.Name
+		Attrs   []xml.Attr `xml:",any,attr"`
+	}
+	assert.NoError(t, xml.Unmarshal(out, &root))
+	assert.Equal(t, xml.Name{Space: formats.OLACNamespace, Local: "olac"}, root.XMLName)
+	declared := make(map[string]string)
+	for _, attr := range root.Attrs {
+		if attr.Name.Space == "xmlns" {
+			declared[attr.Name.Local] = attr.Value
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"olac":    formats.OLACNamespace,
+		"dc":      "http://purl.org/dc/elements/1.1/",
+		"dcterms": "http://purl.org/dc/terms/",
+		"xsi":     "http://www.w3.org/2001/XMLSchema-instance",
+	}, declared)
+
+	// elements
+	type coded struct {
+		Type  string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+		Code  string `xml:"http://www.language-archives.org/OLAC/1.1/ code,attr"`
+		Value string `xml:",chardata"`
+	}
+	var doc struct {
+		Creator     []coded  `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		Contributor []coded  `xml:"http://purl.org/dc/elements/1.1/ contributor"`
+		Type        []coded  `xml:"http://purl.org/dc/elements/1.1/ type"`
+		Language    []coded  `xml:"http://purl.org/dc/elements/1.1/ language"`
+		Subject     []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+		Issued      []coded  `xml:"http://purl.org/dc/terms/ issued"`
+		License     []coded  `xml:"http://purl.org/dc/terms/ license"`
+	}
+	assert.NoError(t, xml.Unmarshal(out, &doc))
+	assert.Equal(t, []coded{{Type: "olac:role", Code: "author", Value: "Novák, Jan"}}, doc.Creator)
+	assert.Equal(
+		t,
+		[]coded{
+			{Type: "olac:role", Code: "sponsor", Value: "Ministry of Education, Youth and Sports"},
+			{Type: "olac:role", Code: "sponsor", Value: "Czech Science Foundation"},
+		},
+		doc.Contributor,
+	)
+	assert.Equal(
+		t,
+		[]coded{
+			{Type: "dcterms:DCMIType", Value: "Text"},
+			{Type: "olac:linguistic-type", Code: "primary_text"},
+		},
+		doc.Type,
+	)
+	assert.Equal(t, []coded{{Type: "olac:language", Code: "ces", Value: "Czech"}}, doc.Language)
+	assert.Equal(t, []string{"written", "fiction"}, doc.Subject)
+	assert.Equal(t, []coded{{Type: "dcterms:W3CDTF", Value: "2020-06-01"}}, doc.Issued)
+	assert.Equal(
+		t,
+		[]coded{{Type: "dcterms:URI", Value: "https://creativecommons.org/licenses/by/4.0/"}},
+		doc.License,
+	)
+}
+
+func TestOLACFormatListed(t *testing.T) {
+	hook := newTestHook()
+	assert.Contains(t, hook.SupportedMetadataPrefixes(), "olac")
+	ans := hook.ListMetadataFormats(context.Background(), oaipmh.OAIPMHRequest{})
+	assert.Contains(t, ans.Data, formats.GetOLACFormat())
+}
+
+func TestDCNoEmptyElements(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.TitleCS = ""
+	data.License = " "
+	out, err := xml.Marshal(hook.dcRecordFromData(data).Metadata)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `<dc:title xml:lang="en">SYN2020</dc:title>`)
+	assert.NotContains(t, string(out), `xml:lang="cs"`)
+	assert.NotContains(t, string(out), "<dc:rights>")
+	assert.NotContains(t, string(out), "<dc:subject")
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+)
+
+// Crosswalk is a diagnostic document containing all the supported
+// metadata representations of a single record so curators can
+// easily spot inconsistencies between formats
+type Crosswalk struct {
+	XMLName    xml.Name           `xml:"crosswalk"`
+	Identifier string             `xml:"identifier,attr"`
+	Sections   []CrosswalkSection `xml:"section"`
+}
+
+type CrosswalkSection struct {
+	MetadataPrefix string                     `xml:"metadataPrefix,attr"`
+	Header         *oaipmh.OAIPMHRecordHeader `xml:"header"`
+	Metadata       *oaipmh.ElementWrapper     `xml:"metadata"`
+}
+
+func (c *CNCHook) crosswalkFromData(data *cncdb.DBData) *Crosswalk {
+	ans := &Crosswalk{Identifier: fmt.Sprint(data.ID)}
+	localizeContact(data, c.conf.DefaultLanguage())
+	for _, prefix := range c.SupportedMetadataPrefixes() {
+		record, ok := c.recordFromData(prefix, data)
+		if !ok {
+			continue
+		}
+		ans.Sections = append(
+			ans.Sections,
+			CrosswalkSection{
+				MetadataPrefix: prefix,
+				Header:         record.Header,
+				Metadata:       record.Metadata,
+			},
+		)
+	}
+	return ans
+}
+
+// GetCrosswalk creates a crosswalk document for a record
+// with the provided identifier. In case no such record
+// exists, nil is returned.
+func (c *CNCHook) GetCrosswalk(ctx context.Context, identifier string) (*Crosswalk, error) {
+	data, err := c.db.GetRecordInfo(ctx, identifier, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create crosswalk: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	if err := c.completeData(ctx, data); err != nil {
+		return nil, fmt.Errorf("failed to create crosswalk: %w", err)
+	}
+	return c.crosswalkFromData(data), nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+)
+
+// ExportRecords writes all the visible records in the specified metadata
+// format to `w` as a single ListRecords OAI-PMH document. The number
+// of exported records is returned.
+func (c *CNCHook) ExportRecords(ctx context.Context, w io.Writer, metadataPrefix string) (int, error) {
+	data, err := c.db.ListRecordInfo(ctx, nil, nil, cncdb.SetFilter{}, false, nil, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to export records: %w", err)
+	}
+	if err := c.completeData(ctx, sliceToPointers(data)...); err != nil {
+		return 0, fmt.Errorf("failed to export records: %w", err)
+	}
+	return c.exportRecords(w, metadataPrefix, data)
+}
+
+func (c *CNCHook) exportRecords(w io.Writer, metadataPrefix string, data []cncdb.DBData) (int, error) {
+	OAIURL, err := url.JoinPath(c.conf.RepositoryInfo.BaseURL, "oai")
+	if err != nil {
+		return 0, fmt.Errorf("failed to export records: %w", err)
+	}
+	records := make([]oaipmh.OAIPMHRecord, 0, len(data))
+	for _, d := range data {
+		localizeContact(&d, c.conf.DefaultLanguage())
+		record, ok := c.recordFromData(metadataPrefix, &d)
+		if !ok {
+			return 0, fmt.Errorf("failed to export records: unknown metadata format %s", metadataPrefix)
+		}
+		records = append(records, record)
+	}
+	resp := oaipmh.NewOAIPMHResponse(
+		&oaipmh.OAIPMHRequest{
+			URL:            OAIURL,
+			Verb:           oaipmh.VerbListRecords,
+			MetadataPrefix: metadataPrefix,
+		},
+	)
+	resp.ListRecords = &records
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return 0, fmt.Errorf("failed to export records: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(resp); err != nil {
+		return 0, fmt.Errorf("failed to export records: %w", err)
+	}
+	return len(records), nil
+}
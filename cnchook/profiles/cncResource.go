@@ -35,6 +35,10 @@ type CNCResourceProfile struct {
 	RelationsInfo     *[]formats.TypedElement               `xml:"cmdp:CNC_Resource>cmdp:relationsInfo>cmdp:relation,omitempty"`
 }
 
+func (c *CNCResourceProfile) GetProfileID() string {
+	return CNCResourceProfileID
+}
+
 func (c *CNCResourceProfile) GetSchemaURL() string {
 	return fmt.Sprintf("http://www.clarin.eu/cmd/1/profiles/%s", CNCResourceProfileID)
 }
@@ -0,0 +1,266 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiles
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/czcorpus/cnc-vlo/cnchook/profiles/components"
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+	"github.com/czcorpus/cnc-vlo/oaipmh/formats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCMDIProfileIDAndNamespace(t *testing.T) {
+	metadata := formats.NewCMDI(&CNCResourceProfile{}, formats.CMDIEnvelope{})
+	assert.Equal(t, "clarin.eu:cr1:p_1712653174418", metadata.Header.MdProfile)
+	assert.Equal(t, "http://www.clarin.eu/cmd/1/profiles/clarin.eu:cr1:p_1712653174418", metadata.XMLNSCMDP)
+}
+
+func TestNewCMDIDefaultVersion(t *testing.T) {
+	metadata := formats.NewCMDI(&CNCResourceProfile{}, formats.CMDIEnvelope{})
+	assert.Equal(t, "1.2", metadata.Version)
+	assert.True(t, strings.HasPrefix(
+		metadata.XSISchemaLocation,
+		"http://www.clarin.eu/cmd/1 http://www.clarin.eu/cmd/1/xsd/cmd-envelop.xsd ",
+	))
+}
+
+func TestNewCMDINonDefaultVersion(t *testing.T) {
+	metadata := formats.NewCMDI(&CNCResourceProfile{}, formats.CMDIEnvelope{Version: "1.3"})
+	assert.Equal(t, "1.3", metadata.Version)
+	assert.Equal(t, "http://www.clarin.eu/cmd/1", metadata.XMLNSCMD)
+	assert.True(t, strings.HasPrefix(
+		metadata.XSISchemaLocation,
+		"http://www.clarin.eu/cmd/1 https://infra.clarin.eu/CMDI/1.3/xsd/cmd-envelop.xsd ",
+	))
+	assert.Equal(
+		t,
+		"https://infra.clarin.eu/CMDI/1.3/xsd/cmd-envelop.xsd",
+		formats.GetCMDIFormat(formats.CMDIEnvelope{Version: "1.3"}).Schema,
+	)
+
+	metadata = formats.NewCMDI(
+		&CNCResourceProfile{},
+		formats.CMDIEnvelope{Version: "1.3", SchemaURL: "http://localhost/cmd-envelop.xsd"},
+	)
+	assert.Contains(t, metadata.XSISchemaLocation, " http://localhost/cmd-envelop.xsd ")
+}
+
+// newTestProfile creates a profile with all the optional
+// components filled in
+func newTestProfile() *CNCResourceProfile {
+	return &CNCResourceProfile{
+		BibliographicInfo: components.BibliographicInfoComponent{
+			Titles:      formats.MultilangArray{{Lang: "en", Value: "Test corpus"}},
+			Authors:     []components.AuthorComponent{{LastName: "Novák", FirstName: "Jan"}},
+			Dates:       &components.DatesComponent{DateIssued: "2024"},
+			Identifiers: []formats.TypedElement{{Type: "Handle", Value: "11234/1-0000"}},
+			Funds:       &[]components.FundingComponent{{Organization: "GAČR", Code: "1"}},
+			Publishers:  []string{"ÚČNK"},
+		},
+		DataInfo: components.DataInfoComponent{
+			Type:        "corpus",
+			Description: formats.MultilangArray{{Lang: "en", Value: "Description"}},
+			Languages:   &[]components.LanguageComponent{{Name: "Czech", Code: "ces"}},
+			Keywords:    &[]string{"written"},
+			Links:       &[]formats.TypedElement{{Type: "documentation", Value: "https://wiki.korpus.cz/"}},
+			SizeInfo:    &[]components.SizeComponent{{Size: "1000", Unit: "words"}},
+			Formats:     &[]components.FormatComponent{{Type: "text", Name: "vertical"}},
+			CollectionInfo: &components.CollectionInfoComponent{
+				TimePeriods: []string{"2020-2024"},
+				Forms:       []string{"written"},
+				Genres:      []string{"fiction", "news"},
+			},
+			AnnotationInfo: &components.AnnotationInfoComponent{
+				AnnotationTypes: []string{"lemmas"},
+				Tagsets:         []string{"PDT"},
+			},
+		},
+		LicenseInfo:   []LicenseElement{{Name: "CC BY 4.0", URI: "https://creativecommons.org/licenses/by/4.0/"}},
+		RelationsInfo: &[]formats.TypedElement{{Type: "isPartOf", Value: "syn"}},
+	}
+}
+
+// xmlElementNames walks the XML struct tags of the type and returns
+// all the element names (incl. `a>b` path parts) found, keyed by the Go
+// field path. Attributes and character data are skipped as they are
+// mostly unqualified in CMDI.
+func xmlElementNames(tp reflect.Type, fieldPath string, ans map[string][]string) {
+	for tp.Kind() == reflect.Pointer || tp.Kind() == reflect.Slice || tp.Kind() == reflect.Array {
+		tp = tp.Elem()
+	}
+	if tp.Kind() != reflect.Struct || tp.Implements(textMarshalerType) ||
+		reflect.PointerTo(tp).Implements(textMarshalerType) {
+		return
+	}
+	for i := 0; i < tp.NumField(); i++ {
+		field := tp.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, opts, _ := strings.Cut(field.Tag.Get("xml"), ",")
+		if tag == "-" || strings.Contains(opts, "attr") || strings.Contains(opts, "chardata") {
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		path := fieldPath + "." + field.Name
+		ans[path] = strings.Split(tag, ">")
+		if field.Name != "XMLName" {
+			xmlElementNames(field.Type, path, ans)
+		}
+	}
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+func TestCMDIElementPrefixes(t *testing.T) {
+	for _, tc := range []struct {
+		tp     reflect.Type
+		prefix string
+	}{
+		{reflect.TypeOf(formats.CMDIFormat{}), "cmd:"},
+		{reflect.TypeOf(CNCResourceProfile{}), "cmdp:"},
+	} {
+		names := make(map[string][]string)
+		xmlElementNames(tc.tp, tc.tp.Name(), names)
+		assert.NotEmpty(t, names)
+		for path, elms := range names {
+			for _, elm := range elms {
+				assert.Truef(
+					t, strings.HasPrefix(elm, tc.prefix),
+					"element %s of %s must use the %s prefix", elm, path, tc.prefix)
+			}
+		}
+	}
+}
+
+func TestCMDIMarshaledPrefixes(t *testing.T) {
+	profile := newTestProfile()
+	metadata := formats.NewCMDI(profile, formats.CMDIEnvelope{})
+	metadata.IsPartOf = &[]string{"https://www.korpus.cz/"}
+	metadata.Resources.ResourceProxyList = []formats.CMDIResourceProxy{
+		{ID: "sp_1", ResourceType: formats.CMDIResourceType{Value: formats.RTSearchPage}, ResourceRef: "https://www.korpus.cz/kontext"},
+	}
+	doc, err := xml.Marshal(metadata)
+	assert.NoError(t, err)
+
+	// check prefixes as written
+	declared := make(map[string]string)
+	inComponents := false
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		switch tt := tok.(type) {
+		case xml.StartElement:
+			for _, attr := range tt.Attr {
+				if attr.Name.Space == "xmlns" {
+					declared[attr.Name.Local] = attr.Value
+				}
+			}
+			if inComponents {
+				assert.Equalf(t, "cmdp", tt.Name.Space, "profile element %s", tt.Name.Local)
+			} else {
+				assert.Equalf(t, "cmd", tt.Name.Space, "envelope element %s", tt.Name.Local)
+			}
+			if tt.Name.Local == "Components" {
+				inComponents = true
+			}
+		case xml.EndElement:
+			if tt.Name.Local == "Components" {
+				inComponents = false
+			}
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"xsi":  "http://www.w3.org/2001/XMLSchema-instance",
+		"cmd":  formats.CMDINamespace,
+		"cmdp": profile.GetSchemaURL(),
+	}, declared)
+
+	// check the prefixes resolve to the expected namespaces
+	namespaces := make(map[string]bool)
+	dec = xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		if se, ok := tok.(xml.StartElement); ok {
+			namespaces[se.Name.Space] = true
+		}
+	}
+	assert.Equal(t, map[string]bool{formats.CMDINamespace: true, profile.GetSchemaURL(): true}, namespaces)
+}
+
+func TestCMDINestedElementsRoundTrip(t *testing.T) {
+	profile := newTestProfile()
+	record := oaipmh.NewOAIPMHRecord(formats.NewCMDI(profile, formats.CMDIEnvelope{}))
+	resp := oaipmh.NewOAIPMHResponse(&oaipmh.OAIPMHRequest{Verb: oaipmh.VerbGetRecord})
+	resp.GetRecord = &record
+	doc, err := xml.Marshal(resp)
+	assert.NoError(t, err)
+
+	// the record is embedded in the OAI-PMH response with a different
+	// default namespace, nested profile elements must still be
+	// qualified by the namespace declared on the CMDI root
+	var parsed struct {
+		Genres []string `xml:"GetRecord>record>metadata>CMD>Components>CNC_Resource>dataInfo>collectionInfo>genres>genre"`
+		Forms  []string `xml:"GetRecord>record>metadata>CMD>Components>CNC_Resource>dataInfo>collectionInfo>forms>form"`
+		Tagset []string `xml:"GetRecord>record>metadata>CMD>Components>CNC_Resource>dataInfo>annotationInfo>tagset"`
+	}
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	dec.Strict = true
+	assert.NoError(t, dec.Decode(&parsed))
+	assert.Equal(t, []string{"fiction", "news"}, parsed.Genres)
+	assert.Equal(t, []string{"written"}, parsed.Forms)
+	assert.Equal(t, []string{"PDT"}, parsed.Tagset)
+
+	spaces := make(map[string]string)
+	dec = xml.NewDecoder(bytes.NewReader(doc))
+	dec.Strict = true
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		if se, ok := tok.(xml.StartElement); ok {
+			spaces[se.Name.Local] = se.Name.Space
+		}
+	}
+	for _, elm := range []string{"CNC_Resource", "dataInfo", "collectionInfo", "genres", "genre", "forms", "form"} {
+		assert.Equal(t, profile.GetSchemaURL(), spaces[elm], elm)
+	}
+	for _, elm := range []string{"CMD", "Header", "Components"} {
+		assert.Equal(t, formats.CMDINamespace, spaces[elm], elm)
+	}
+	assert.Equal(t, "http://www.openarchives.org/OAI/2.0/", spaces["metadata"])
+}
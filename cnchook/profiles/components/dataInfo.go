@@ -31,7 +31,12 @@ type DataInfoComponent struct {
 	Formats        *[]FormatComponent       `xml:"cmdp:formats>cmdp:format,omitempty"`
 	Requirements   *[]string                `xml:"cmdp:requirements>cmdp:requirement,omitempty"` // e.g. OS, prerequisities
 	CollectionInfo *CollectionInfoComponent `xml:"cmdp:collectionInfo,omitempty"`
-	AnnotationInfo *[]string                `xml:"cmdp:annotationInfo>cmdp:annotationType,omitempty"` // tags, lemmas, phrase alignment, coreference, ...
+	AnnotationInfo *AnnotationInfoComponent `xml:"cmdp:annotationInfo,omitempty"`
+}
+
+type AnnotationInfoComponent struct {
+	AnnotationTypes []string `xml:"cmdp:annotationType"`   // tags, lemmas, phrase alignment, coreference, ...
+	Tagsets         []string `xml:"cmdp:tagset,omitempty"` // annotation scheme, e.g. PDT, desamb
 }
 
 type LanguageComponent struct {
@@ -0,0 +1,154 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+	"github.com/rs/zerolog/log"
+)
+
+// listState contains everything needed to continue an incomplete
+// list. It is passed to harvesters as an encoded resumption token
+// so the server does not have to keep any state.
+type listState struct {
+	MetadataPrefix string              `json:"p"`
+	From           *time.Time          `json:"f,omitempty"`
+	Until          *time.Time          `json:"u,omitempty"`
+	Set            string              `json:"s,omitempty"`
+	After          *cncdb.RecordCursor `json:"a,omitempty"`
+	Cursor         int                 `json:"c"`
+	Expires        time.Time           `json:"e"`
+}
+
+func (s listState) encode() string {
+	data, err := json.Marshal(s)
+	if err != nil {
+		// the struct contains only plain values
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeResumptionToken(token string, now time.Time) (listState, error) {
+	var ans listState
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return ans, fmt.Errorf("failed to decode resumption token: %w", err)
+	}
+	if err := json.Unmarshal(data, &ans); err != nil {
+		return ans, fmt.Errorf("failed to decode resumption token: %w", err)
+	}
+	if ans.MetadataPrefix == "" || ans.After == nil {
+		return ans, fmt.Errorf("failed to decode resumption token: incomplete state")
+	}
+	if now.After(ans.Expires) {
+		return ans, fmt.Errorf("resumption token expired at %s", ans.Expires)
+	}
+	return ans, nil
+}
+
+// listPage is a single batch of records for list verbs
+type listPage struct {
+	metadataPrefix string
+	data           []cncdb.DBData
+	token          *oaipmh.OAIPMHResumptionToken
+	errors         oaipmh.OAIPMHErrors
+	httpCode       int
+}
+
+// fetchListPage loads a batch of records requested either by list
+// arguments or by a resumption token. For incomplete lists (and
+// for the last batch of a resumed list), a resumption token is attached.
+func (c *CNCHook) fetchListPage(ctx context.Context, req oaipmh.OAIPMHRequest, now time.Time) (listPage, error) {
+	ans := listPage{httpCode: http.StatusOK}
+	state := listState{
+		MetadataPrefix: req.MetadataPrefix,
+		From:           req.From,
+		Until:          req.Until,
+		Set:            req.Set,
+	}
+	if req.ResumptionToken != "" {
+		var err error
+		state, err = decodeResumptionToken(req.ResumptionToken, now)
+		if err != nil {
+			log.Debug().Err(err).Msg("bad resumption token")
+			ans.errors.Add(oaipmh.ErrorCodeBadResumptionToken, "Invalid or expired resumption token")
+			return ans, nil
+		}
+	}
+	ans.metadataPrefix = state.MetadataPrefix
+	set, ok, err := c.resolveSet(ctx, state.Set)
+	if err != nil {
+		return ans, err
+	}
+	if !ok {
+		ans.errors.Add(oaipmh.ErrorCodeNoRecordsMatch, fmt.Sprintf("Unknown set `%s`", state.Set))
+		return ans, nil
+	}
+	from := c.clampFrom(ctx, state.From)
+	until := c.clampUntil(state.Until, now)
+	includeDeleted := c.conf.TracksDeletedRecords()
+	data, err := c.db.ListRecordInfo(
+		ctx, from, until, set, includeDeleted, state.After, c.conf.PageSize+1)
+	if err != nil {
+		return ans, err
+	}
+	hasMore := len(data) > c.conf.PageSize
+	if hasMore {
+		data = data[:c.conf.PageSize]
+	}
+	if hasMore || state.Cursor > 0 {
+		// the list may change during harvesting so the count
+		// is adjusted to be consistent with the current batch
+		completeListSize := state.Cursor + len(data)
+		if hasMore {
+			count, err := c.db.CountRecords(ctx, from, until, set, includeDeleted)
+			if err != nil {
+				return ans, err
+			}
+			completeListSize = max(count, completeListSize+1)
+		}
+		next := state
+		next.Cursor += len(data)
+		next.Expires = now.Add(time.Duration(c.conf.ResumptionTokenTTLSecs) * time.Second)
+		if len(data) > 0 {
+			last := data[len(data)-1]
+			next.After = &cncdb.RecordCursor{Datestamp: last.Date, ID: last.ID}
+		}
+		ans.token = oaipmh.NewResumptionToken(next.encode(), state.Cursor, len(data), completeListSize)
+		if ans.token.Token != "" {
+			expires := oaipmh.UTCTime(next.Expires)
+			ans.token.ExpirationDate = &expires
+		}
+	}
+	// note: the cursor is based on datestamps stored in DB so records
+	// with future datestamps must be clamped after creating the token
+	// (excluded ones are already filtered out by clampUntil)
+	ans.data = c.handleFutureDatestamps(data, now)
+	if len(ans.data) == 0 && ans.token == nil {
+		ans.errors.Add(oaipmh.ErrorCodeNoRecordsMatch, "No records")
+	}
+	return ans, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/czcorpus/cnc-vlo/cnf"
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPagingHook(numRecords int) *CNCHook {
+	db := &fakeRecordStore{}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < numRecords; i++ {
+		// several records share a datestamp to test the ID tiebreaker
+		db.records = append(db.records, cncdb.DBData{
+			ID:   numRecords - i,
+			Date: base.Add(time.Duration(i/7) * time.Hour),
+			Type: string(CorpusMetadataType),
+			Name: fmt.Sprintf("corpus%d", i),
+		})
+	}
+	return &CNCHook{
+		conf: &cnf.Conf{PageSize: 100, ResumptionTokenTTLSecs: 60},
+		db:   db,
+	}
+}
+
+func TestHarvestAllPages(t *testing.T) {
+	hook := newPagingHook(1234)
+	seen := make(map[string]bool)
+	req := oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"}
+	numPages := 0
+	for {
+		ans := hook.ListIdentifiers(context.Background(), req)
+		assert.True(t, ans.NoError())
+		assert.NotNil(t, ans.ResumptionToken)
+		assert.Equal(t, numPages*100, ans.ResumptionToken.Cursor)
+		assert.Equal(t, 1234, ans.ResumptionToken.CompleteListSize)
+		for _, h := range ans.Data {
+			assert.False(t, seen[h.Identifier], "duplicate record %s", h.Identifier)
+			seen[h.Identifier] = true
+		}
+		numPages++
+		if ans.ResumptionToken.Token == "" {
+			assert.Nil(t, ans.ResumptionToken.ExpirationDate)
+			break
+		}
+		assert.NotNil(t, ans.ResumptionToken.ExpirationDate)
+		req = oaipmh.OAIPMHRequest{ResumptionToken: ans.ResumptionToken.Token}
+	}
+	assert.Equal(t, 13, numPages)
+	assert.Len(t, seen, 1234)
+	for i := 1; i <= 1234; i++ {
+		assert.True(t, seen[fmt.Sprint(i)], "missing record %d", i)
+	}
+}
+
+func TestHarvestRecordsKeepsArguments(t *testing.T) {
+	hook := newPagingHook(500)
+	from := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	req := oaipmh.OAIPMHRequest{MetadataPrefix: "cmdi", From: &from}
+	var total int
+	for {
+		ans := hook.ListRecords(context.Background(), req)
+		assert.True(t, ans.NoError())
+		for _, r := range ans.Data {
+			assert.False(t, r.Header.Datestamp.Before(from))
+			assert.NotNil(t, r.Metadata)
+		}
+		total += len(ans.Data)
+		if ans.ResumptionToken == nil || ans.ResumptionToken.Token == "" {
+			break
+		}
+		req = oaipmh.OAIPMHRequest{ResumptionToken: ans.ResumptionToken.Token}
+	}
+	// 24 hours x 7 records per hour precede `from`
+	assert.Equal(t, 500-24*7, total)
+}
+
+func TestSinglePageWithoutToken(t *testing.T) {
+	hook := newPagingHook(50)
+	ans := hook.ListIdentifiers(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	assert.True(t, ans.NoError())
+	assert.Len(t, ans.Data, 50)
+	assert.Nil(t, ans.ResumptionToken)
+}
+
+func TestBadResumptionToken(t *testing.T) {
+	hook := newPagingHook(10)
+	for _, token := range []string{"garbage!", "bm90IGpzb24", listState{Expires: time.Now().Add(time.Hour)}.encode()} {
+		ans := hook.ListRecords(context.Background(), oaipmh.OAIPMHRequest{ResumptionToken: token})
+		assert.Len(t, ans.Errors, 1)
+		assert.Equal(t, oaipmh.ErrorCodeBadResumptionToken, ans.Errors[0].Code)
+	}
+}
+
+func TestExpiredResumptionToken(t *testing.T) {
+	hook := newPagingHook(10)
+	token := listState{
+		MetadataPrefix: "oai_dc",
+		After:          &cncdb.RecordCursor{ID: 1},
+		Expires:        time.Now().Add(-time.Second),
+	}.encode()
+	ans := hook.ListIdentifiers(context.Background(), oaipmh.OAIPMHRequest{ResumptionToken: token})
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, oaipmh.ErrorCodeBadResumptionToken, ans.Errors[0].Code)
+}
+
+func TestHarvestExcludedFutureDatestamps(t *testing.T) {
+	hook := newPagingHook(250)
+	hook.conf.Conversion.FutureDatestamps = cnf.FutureDatestampsExclude
+	db := hook.db.(*fakeRecordStore)
+	// future records start in the second page and fill the third one
+	future := time.Now().Add(time.Hour)
+	for i := 190; i < 250; i++ {
+		db.records[i].Date = future.Add(time.Duration(i) * time.Minute)
+	}
+	seen := make(map[string]bool)
+	req := oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"}
+	numPages := 0
+	for {
+		ans := hook.ListIdentifiers(context.Background(), req)
+		assert.True(t, ans.NoError())
+		assert.NotNil(t, ans.ResumptionToken)
+		assert.Equal(t, 190, ans.ResumptionToken.CompleteListSize)
+		for _, h := range ans.Data {
+			seen[h.Identifier] = true
+		}
+		numPages++
+		if ans.ResumptionToken.Token == "" {
+			break
+		}
+		req = oaipmh.OAIPMHRequest{ResumptionToken: ans.ResumptionToken.Token}
+	}
+	assert.Equal(t, 2, numPages)
+	assert.Len(t, seen, 190)
+	for i := 190; i < 250; i++ {
+		assert.False(t, seen[fmt.Sprint(db.records[i].ID)], "future record %d listed", db.records[i].ID)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+	"golang.org/x/text/language"
+)
+
+// selfTestFixture provides a sample corpus record with values
+// requiring escaping and most of the optional fields filled in
+func selfTestFixture() *cncdb.DBData {
+	locale := language.Czech
+	return &cncdb.DBData{
+		ID:         1,
+		Date:       time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Type:       string(CorpusMetadataType),
+		Name:       "self_test",
+		TitleEN:    "Self-test corpus <A & B>",
+		TitleCS:    "Testovací korpus \"Č & Ř\"",
+		DescEN:     sql.NullString{String: "Description with <markup> & entities", Valid: true},
+		DescCS:     sql.NullString{String: "Popis s diakritikou", Valid: true},
+		DateIssued: "2024",
+		PID:        sql.NullString{String: "11234/1-0000", Valid: true},
+		Link:       sql.NullString{String: "https://wiki.korpus.cz/doku.php/cnk:self_test", Valid: true},
+		License:    "https://creativecommons.org/licenses/by/4.0/",
+		Authors:    "Novák, Jan; Svoboda, Petr",
+		ContactPerson: cncdb.ContactPersonData{
+			Firstname: "Jan",
+			Lastname:  "Novák",
+			Email:     "jan.novak@example.com",
+		},
+		CorpusData: cncdb.CorpusData{
+			Size:        sql.NullInt64{Int64: 1000, Valid: true},
+			Locale:      &locale,
+			Keywords:    sql.NullString{String: "written, <test>", Valid: true},
+			TimePeriods: sql.NullString{String: "2020-2024", Valid: true},
+			Places:      sql.NullString{String: "Praha", Valid: true},
+		},
+	}
+}
+
+// metadataNamespaces parses a serialized GetRecord response and returns
+// namespaces of all the elements within the record metadata. An error
+// is returned in case the document is not well-formed.
+func metadataNamespaces(doc []byte) (map[string]bool, error) {
+	ans := make(map[string]bool)
+	metadataDepth := -1
+	depth := 0
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	dec.Strict = true
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed XML: %w", err)
+		}
+		switch tt := tok.(type) {
+		case xml.StartElement:
+			if metadataDepth >= 0 {
+				ans[tt.Name.Space] = true
+			} else if tt.Name.Local == "metadata" {
+				metadataDepth = depth
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == metadataDepth {
+				metadataDepth = -1
+			}
+		}
+	}
+	if len(ans) == 0 {
+		return nil, errors.New("missing record metadata")
+	}
+	return ans, nil
+}
+
+// RunSelfTest converts a fixture record into all the supported metadata
+// formats and checks the serialized GetRecord responses are well-formed
+// XML with metadata in the advertised namespace. This is meant to catch
+// serialization regressions before the server starts.
+func (c *CNCHook) RunSelfTest() error {
+	for i, prefix := range c.SupportedMetadataPrefixes() {
+		record, ok := c.recordFromData(prefix, selfTestFixture())
+		if !ok {
+			return fmt.Errorf("self-test failed for %s: unsupported metadata format", prefix)
+		}
+		resp := oaipmh.NewOAIPMHResponse(
+			&oaipmh.OAIPMHRequest{
+				URL:            c.conf.RepositoryInfo.BaseURL,
+				Verb:           oaipmh.VerbGetRecord,
+				MetadataPrefix: prefix,
+				Identifier:     record.Header.Identifier,
+			},
+		)
+		resp.GetRecord = &record
+		doc, err := xml.Marshal(resp)
+		if err != nil {
+			return fmt.Errorf("self-test failed for %s: %w", prefix, err)
+		}
+		namespaces, err := metadataNamespaces(doc)
+		if err != nil {
+			return fmt.Errorf("self-test failed for %s: %w", prefix, err)
+		}
+		if ns := c.metadataFormats[i].MetadataNamespace; !namespaces[ns] {
+			return fmt.Errorf("self-test failed for %s: no metadata in namespace %s", prefix, ns)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSelfTest(t *testing.T) {
+	assert.NoError(t, newTestHook().RunSelfTest())
+}
+
+func TestRunSelfTestCollapsedWhitespace(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.Conversion.CollapseWhitespace = true
+	assert.NoError(t, hook.RunSelfTest())
+}
+
+func TestMetadataNamespaces(t *testing.T) {
+	doc := `<OAI-PMH><GetRecord><record><header><identifier>1</identifier></header>` +
+		`<metadata><a:dc xmlns:a="urn:a"><b:title xmlns:b="urn:b">x</b:title></a:dc></metadata>` +
+		`</record></GetRecord></OAI-PMH>`
+	namespaces, err := metadataNamespaces([]byte(doc))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"urn:a": true, "urn:b": true}, namespaces)
+}
+
+func TestMetadataNamespacesMalformed(t *testing.T) {
+	_, err := metadataNamespaces([]byte(`<record><metadata><dc>x</metadata></record>`))
+	assert.ErrorContains(t, err, "malformed XML")
+}
+
+func TestMetadataNamespacesMissingMetadata(t *testing.T) {
+	_, err := metadataNamespaces([]byte(`<record><header status="deleted"/></record>`))
+	assert.ErrorContains(t, err, "missing record metadata")
+}
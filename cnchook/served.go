@@ -0,0 +1,98 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"sync"
+	"time"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+)
+
+// dfltServedLogSize is a number of the most recent list responses
+// kept for harvest reconciliation
+const dfltServedLogSize = 1000
+
+// ServedRange describes records served by a single ListRecords response
+type ServedRange struct {
+	Time           time.Time `json:"time"`
+	MetadataPrefix string    `json:"metadataPrefix"`
+	NumRecords     int       `json:"numRecords"`
+	MinRecordID    int       `json:"minRecordId"`
+	MaxRecordID    int       `json:"maxRecordId"`
+}
+
+// newServedRange returns the range of record IDs in the data.
+// For empty data, false is returned.
+func newServedRange(metadataPrefix string, data []cncdb.DBData, t time.Time) (ServedRange, bool) {
+	if len(data) == 0 {
+		return ServedRange{}, false
+	}
+	ans := ServedRange{
+		Time:           t,
+		MetadataPrefix: metadataPrefix,
+		NumRecords:     len(data),
+		MinRecordID:    data[0].ID,
+		MaxRecordID:    data[0].ID,
+	}
+	for _, d := range data[1:] {
+		ans.MinRecordID = min(ans.MinRecordID, d.ID)
+		ans.MaxRecordID = max(ans.MaxRecordID, d.ID)
+	}
+	return ans, true
+}
+
+// servedLog keeps a limited number of the most recent served
+// ranges. It is safe for concurrent use.
+type servedLog struct {
+	mu      sync.Mutex
+	size    int
+	entries []ServedRange
+}
+
+func (sl *servedLog) add(r ServedRange) {
+	if sl == nil {
+		return
+	}
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if len(sl.entries) >= sl.size {
+		sl.entries = append(sl.entries[:0], sl.entries[len(sl.entries)-sl.size+1:]...)
+	}
+	sl.entries = append(sl.entries, r)
+}
+
+// lastWithin returns the most recent range served within the
+// [from, until] interval
+func (sl *servedLog) lastWithin(from, until time.Time) (ServedRange, bool) {
+	if sl == nil {
+		return ServedRange{}, false
+	}
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	for i := len(sl.entries) - 1; i >= 0; i-- {
+		t := sl.entries[i].Time
+		if !t.Before(from) && !t.After(until) {
+			return sl.entries[i], true
+		}
+	}
+	return ServedRange{}, false
+}
+
+func newServedLog(size int) *servedLog {
+	return &servedLog{size: size, entries: make([]ServedRange, 0, size)}
+}
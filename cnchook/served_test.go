@@ -0,0 +1,90 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewServedRange(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	served, ok := newServedRange("oai_dc", []cncdb.DBData{{ID: 12}, {ID: 3}, {ID: 40}, {ID: 7}}, now)
+	assert.True(t, ok)
+	assert.Equal(
+		t,
+		ServedRange{Time: now, MetadataPrefix: "oai_dc", NumRecords: 4, MinRecordID: 3, MaxRecordID: 40},
+		served,
+	)
+}
+
+func TestNewServedRangeEmpty(t *testing.T) {
+	_, ok := newServedRange("oai_dc", []cncdb.DBData{}, time.Now())
+	assert.False(t, ok)
+}
+
+func TestServedLogLastWithin(t *testing.T) {
+	sl := newServedLog(2)
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		sl.add(ServedRange{Time: base.Add(time.Duration(i) * time.Hour), MaxRecordID: i + 1})
+	}
+	assert.Len(t, sl.entries, 2)
+
+	served, ok := sl.lastWithin(base, base.Add(24*time.Hour))
+	assert.True(t, ok)
+	assert.Equal(t, 3, served.MaxRecordID)
+	served, ok = sl.lastWithin(base, base.Add(90*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, 2, served.MaxRecordID)
+	// the oldest entry has been dropped
+	_, ok = sl.lastWithin(base, base.Add(30*time.Minute))
+	assert.False(t, ok)
+}
+
+func TestListRecordsCapturesServedRange(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hook := newStoreHook(&fakeRecordStore{
+		records: []cncdb.DBData{
+			{ID: 5, Date: date, Type: "corpus", Name: "syn2015"},
+			{ID: 2, Date: date, Type: "corpus", Name: "syn2010"},
+			{ID: 9, Date: date, Type: "corpus", Name: "syn2020"},
+		},
+	})
+	start := time.Now()
+	ans := hook.ListRecords(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	assert.True(t, ans.NoError())
+	served, ok := hook.GetLastServed(start, time.Now())
+	assert.True(t, ok)
+	assert.Equal(t, "oai_dc", served.MetadataPrefix)
+	assert.Equal(t, 3, served.NumRecords)
+	assert.Equal(t, 2, served.MinRecordID)
+	assert.Equal(t, 9, served.MaxRecordID)
+}
+
+func TestListRecordsNothingServed(t *testing.T) {
+	hook := newStoreHook(&fakeRecordStore{})
+	ans := hook.ListRecords(context.Background(), oaipmh.OAIPMHRequest{MetadataPrefix: "oai_dc"})
+	assert.False(t, ans.NoError())
+	_, ok := hook.GetLastServed(time.Time{}, time.Now())
+	assert.False(t, ok)
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	SitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+	// SitemapMaxURLs is the max. number of URLs in a single sitemap
+	// as specified by the sitemap protocol
+	SitemapMaxURLs = 50000
+)
+
+type SitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Sitemap lists landing pages of records
+type Sitemap struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []SitemapURL `xml:"url"`
+}
+
+// SitemapIndex lists sitemap pages in case there are too many
+// records for a single sitemap
+type SitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []SitemapURL `xml:"sitemap"`
+}
+
+func sitemapLastMod(t time.Time) string {
+	return t.In(time.UTC).Format(time.RFC3339)
+}
+
+// getSitemapPageURL returns URL of a sitemap page (numbered from 1)
+func (c *CNCHook) getSitemapPageURL(page int) (string, error) {
+	sitemapURL, err := url.JoinPath(c.conf.RepositoryInfo.BaseURL, "sitemap.xml")
+	if err != nil {
+		return "", err
+	}
+	return sitemapURL + "?page=" + strconv.Itoa(page), nil
+}
+
+// buildSitemap creates either a sitemap of all the records (page 0
+// with no more than `pageSize` records), an index of sitemap pages
+// (page 0 with more records) or a sitemap of the specified page.
+// In case the page does not exist, false is returned. The data are
+// expected to be ordered by record IDs so pages are stable as long
+// as no records are added or removed.
+func (c *CNCHook) buildSitemap(data []cncdb.RecordStamp, page, pageSize int) (any, bool, error) {
+	numPages := (len(data) + pageSize - 1) / pageSize
+	if page == 0 && numPages > 1 {
+		index := SitemapIndex{XMLNS: SitemapNamespace}
+		for i := 0; i < numPages; i++ {
+			pageURL, err := c.getSitemapPageURL(i + 1)
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to create sitemap index: %w", err)
+			}
+			var lastMod time.Time
+			for _, d := range data[i*pageSize : min((i+1)*pageSize, len(data))] {
+				if d.Datestamp.After(lastMod) {
+					lastMod = d.Datestamp
+				}
+			}
+			index.Sitemaps = append(index.Sitemaps, SitemapURL{Loc: pageURL, LastMod: sitemapLastMod(lastMod)})
+		}
+		return index, true, nil
+	}
+	if page == 0 {
+		page = 1
+
+	} else if page > numPages {
+		return nil, false, nil
+	}
+	sitemap := Sitemap{XMLNS: SitemapNamespace, URLs: []SitemapURL{}}
+	for _, d := range data[(page-1)*pageSize : min(page*pageSize, len(data))] {
+		sitemap.URLs = append(
+			sitemap.URLs,
+			SitemapURL{Loc: c.getRecordURL(strconv.Itoa(d.ID)), LastMod: sitemapLastMod(d.Datestamp)},
+		)
+	}
+	return sitemap, true, nil
+}
+
+// GetSitemap returns a sitemap of landing pages of all the visible
+// records. In case there are more than SitemapMaxURLs records,
+// the `page` 0 returns a sitemap index and the individual pages
+// (numbered from 1) contain the records. For a non-existing page,
+// false is returned. The list of records is cached (see
+// SitemapCacheTTLSecs).
+func (c *CNCHook) GetSitemap(ctx context.Context, page int) (any, bool, error) {
+	data, err := c.recordStamps.Get(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get sitemap: %w", err)
+	}
+	return c.buildSitemap(data, page, SitemapMaxURLs)
+}
+
+// HandleSitemap serves the sitemap (or its page specified
+// by the `page` argument)
+func (c *CNCHook) HandleSitemap(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "0"))
+	if err != nil || page < 0 {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	sitemap, ok, err := c.GetSitemap(ctx.Request.Context(), page)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create sitemap")
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	doc, err := xml.Marshal(sitemap)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to encode sitemap")
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctx.Data(http.StatusOK, "application/xml", append([]byte(xml.Header), doc...))
+}
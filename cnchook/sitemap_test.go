@@ -0,0 +1,149 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/czcorpus/cnc-vlo/cnf"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type parsedSitemap struct {
+	XMLName xml.Name
+	URLs    []SitemapURL `xml:"url"`
+	Pages   []SitemapURL `xml:"sitemap"`
+}
+
+func newSitemapHook(numRecords int) (*CNCHook, *fakeRecordStore) {
+	store := &fakeRecordStore{}
+	for i := numRecords; i > 0; i-- {
+		store.records = append(
+			store.records,
+			cncdb.DBData{ID: i, Date: time.Date(2024, 1, i, 12, 0, 0, 0, time.UTC), Type: "corpus"},
+		)
+	}
+	hook := NewCNCHook(
+		&cnf.Conf{
+			RepositoryInfo:      cnf.RepositoryInfo{BaseURL: "https://vlo.korpus.cz"},
+			SitemapCacheTTLSecs: 60,
+		},
+		store,
+	)
+	return hook, store
+}
+
+func marshalSitemap(t *testing.T, sitemap any) parsedSitemap {
+	doc, err := xml.Marshal(sitemap)
+	assert.NoError(t, err)
+	var ans parsedSitemap
+	assert.NoError(t, xml.Unmarshal(doc, &ans))
+	return ans
+}
+
+func TestSitemap(t *testing.T) {
+	hook, _ := newSitemapHook(2)
+	sitemap, ok, err := hook.GetSitemap(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	parsed := marshalSitemap(t, sitemap)
+	assert.Equal(t, xml.Name{Space: SitemapNamespace, Local: "urlset"}, parsed.XMLName)
+	assert.Equal(
+		t,
+		[]SitemapURL{
+			{Loc: "https://vlo.korpus.cz/record/1", LastMod: "2024-01-01T12:00:00Z"},
+			{Loc: "https://vlo.korpus.cz/record/2", LastMod: "2024-01-02T12:00:00Z"},
+		},
+		parsed.URLs,
+	)
+}
+
+func TestSitemapIndex(t *testing.T) {
+	hook, _ := newSitemapHook(5)
+	data, err := hook.db.ListRecordStamps(context.Background())
+	assert.NoError(t, err)
+
+	index, ok, err := hook.buildSitemap(data, 0, 2)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	parsed := marshalSitemap(t, index)
+	assert.Equal(t, xml.Name{Space: SitemapNamespace, Local: "sitemapindex"}, parsed.XMLName)
+	assert.Equal(
+		t,
+		[]SitemapURL{
+			{Loc: "https://vlo.korpus.cz/sitemap.xml?page=1", LastMod: "2024-01-02T12:00:00Z"},
+			{Loc: "https://vlo.korpus.cz/sitemap.xml?page=2", LastMod: "2024-01-04T12:00:00Z"},
+			{Loc: "https://vlo.korpus.cz/sitemap.xml?page=3", LastMod: "2024-01-05T12:00:00Z"},
+		},
+		parsed.Pages,
+	)
+
+	page, ok, err := hook.buildSitemap(data, 3, 2)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(
+		t,
+		[]SitemapURL{{Loc: "https://vlo.korpus.cz/record/5", LastMod: "2024-01-05T12:00:00Z"}},
+		marshalSitemap(t, page).URLs,
+	)
+
+	_, ok, err = hook.buildSitemap(data, 4, 2)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestSitemapCached(t *testing.T) {
+	hook, store := newSitemapHook(2)
+	for i := 0; i < 3; i++ {
+		_, ok, err := hook.GetSitemap(context.Background(), 0)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+	assert.Equal(t, 1, store.numStampLists)
+	hook.ClearCache()
+	_, _, err := hook.GetSitemap(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, store.numStampLists)
+}
+
+func doSitemapRequest(hook *CNCHook, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/sitemap.xml", hook.HandleSitemap)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleSitemap(t *testing.T) {
+	hook, _ := newSitemapHook(2)
+	w := doSitemapRequest(hook, "/sitemap.xml")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/xml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<loc>https://vlo.korpus.cz/record/2</loc>")
+
+	assert.Equal(t, http.StatusNotFound, doSitemapRequest(hook, "/sitemap.xml?page=2").Code)
+	assert.Equal(t, http.StatusBadRequest, doSitemapRequest(hook, "/sitemap.xml?page=x").Code)
+}
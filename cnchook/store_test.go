@@ -0,0 +1,197 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+)
+
+// fakeRecordStore is an in-memory record store emulating keyset
+// pagination of the records table. If `err` is set, record queries
+// fail with the error.
+type fakeRecordStore struct {
+	RecordStore
+	records   []cncdb.DBData
+	corplists []cncdb.Corplist
+	keywords  []cncdb.Keyword
+	relations map[int][]cncdb.Relation
+	funding   map[int][]cncdb.Funding
+	registry  map[string]cncdb.RegistryAttrs
+	err       error
+
+	// numExistenceChecks counts IdentifierExists calls
+	numExistenceChecks int
+
+	// numStampLists counts ListRecordStamps calls
+	numStampLists int
+}
+
+func (db *fakeRecordStore) inSet(r cncdb.DBData, set cncdb.SetFilter) bool {
+	if set.Keyword != "" && !slices.Contains(r.KeywordIDs, set.Keyword) {
+		return false
+	}
+	if set.AnyKeyword && len(r.KeywordIDs) == 0 {
+		return false
+	}
+	if set.CorplistID > 0 {
+		return slices.ContainsFunc(r.Corplists, func(c cncdb.Corplist) bool { return c.ID == set.CorplistID })
+	}
+	return true
+}
+
+func (db *fakeRecordStore) matching(from, until *time.Time, set cncdb.SetFilter, includeDeleted bool) []cncdb.DBData {
+	ans := []cncdb.DBData{}
+	for _, r := range db.records {
+		if (from == nil || !r.Date.Before(*from)) && (until == nil || !r.Date.After(*until)) &&
+			db.inSet(r, set) && (includeDeleted || !r.Deleted) {
+			ans = append(ans, r)
+		}
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		if ans[i].Date.Equal(ans[j].Date) {
+			return ans[i].ID < ans[j].ID
+		}
+		return ans[i].Date.Before(ans[j].Date)
+	})
+	return ans
+}
+
+func (db *fakeRecordStore) ListRecordInfo(
+	ctx context.Context, from *time.Time, until *time.Time, set cncdb.SetFilter, includeDeleted bool,
+	after *cncdb.RecordCursor, limit int,
+) ([]cncdb.DBData, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	ans := []cncdb.DBData{}
+	for _, r := range db.matching(from, until, set, includeDeleted) {
+		if after != nil && (r.Date.Before(after.Datestamp) ||
+			r.Date.Equal(after.Datestamp) && r.ID <= after.ID) {
+			continue
+		}
+		ans = append(ans, r)
+		if limit > 0 && len(ans) == limit {
+			break
+		}
+	}
+	return ans, nil
+}
+
+func (db *fakeRecordStore) ListRecordStamps(ctx context.Context) ([]cncdb.RecordStamp, error) {
+	db.numStampLists++
+	if db.err != nil {
+		return nil, db.err
+	}
+	ans := []cncdb.RecordStamp{}
+	for _, r := range db.matching(nil, nil, cncdb.SetFilter{}, false) {
+		ans = append(ans, cncdb.RecordStamp{ID: r.ID, Datestamp: r.Date})
+	}
+	sort.Slice(ans, func(i, j int) bool { return ans[i].ID < ans[j].ID })
+	return ans, nil
+}
+
+func (db *fakeRecordStore) CountRecords(ctx context.Context, from *time.Time, until *time.Time, set cncdb.SetFilter, includeDeleted bool) (int, error) {
+	if db.err != nil {
+		return 0, db.err
+	}
+	return len(db.matching(from, until, set, includeDeleted)), nil
+}
+
+func (db *fakeRecordStore) GetRecordInfo(ctx context.Context, identifier string, includeDeleted bool) (*cncdb.DBData, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	for _, r := range db.records {
+		if fmt.Sprint(r.ID) == identifier && (includeDeleted || !r.Deleted) {
+			return &r, nil
+		}
+	}
+	return nil, nil
+}
+
+func (db *fakeRecordStore) IdentifierExists(ctx context.Context, identifier string, includeDeleted bool) (bool, error) {
+	db.numExistenceChecks++
+	data, err := db.GetRecordInfo(ctx, identifier, includeDeleted)
+	return data != nil, err
+}
+
+func (db *fakeRecordStore) ListCorplists(ctx context.Context) ([]cncdb.Corplist, error) {
+	return db.corplists, nil
+}
+
+func (db *fakeRecordStore) GetKeywords(ctx context.Context, corpusNames []string) (map[string][]string, error) {
+	ans := make(map[string][]string)
+	for _, r := range db.records {
+		if slices.Contains(corpusNames, r.Name) && len(r.KeywordIDs) > 0 {
+			ans[r.Name] = r.KeywordIDs
+		}
+	}
+	return ans, nil
+}
+
+func (db *fakeRecordStore) ListKeywords(ctx context.Context) ([]cncdb.Keyword, error) {
+	return db.keywords, nil
+}
+
+func (db *fakeRecordStore) GetCorplists(ctx context.Context, corpusNames []string) (map[string][]cncdb.Corplist, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ans := make(map[string][]cncdb.Corplist)
+	for _, r := range db.records {
+		if slices.Contains(corpusNames, r.Name) && len(r.Corplists) > 0 {
+			ans[r.Name] = r.Corplists
+		}
+	}
+	return ans, nil
+}
+
+func (db *fakeRecordStore) GetRelations(ctx context.Context, recordIDs []int) (map[int][]cncdb.Relation, error) {
+	ans := make(map[int][]cncdb.Relation)
+	for _, id := range recordIDs {
+		if rels, ok := db.relations[id]; ok {
+			ans[id] = rels
+		}
+	}
+	return ans, nil
+}
+
+func (db *fakeRecordStore) GetFunding(ctx context.Context, recordIDs []int) (map[int][]cncdb.Funding, error) {
+	ans := make(map[int][]cncdb.Funding)
+	for _, id := range recordIDs {
+		if funding, ok := db.funding[id]; ok {
+			ans[id] = funding
+		}
+	}
+	return ans, nil
+}
+
+func (db *fakeRecordStore) GetRegistryAttrs(ctx context.Context, corpusNames []string) (map[string]cncdb.RegistryAttrs, error) {
+	ans := make(map[string]cncdb.RegistryAttrs)
+	for _, name := range corpusNames {
+		if attrs, ok := db.registry[name]; ok {
+			ans[name] = attrs
+		}
+	}
+	return ans, nil
+}
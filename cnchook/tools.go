@@ -17,26 +17,797 @@
 package cnchook
 
 import (
+	"context"
 	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/czcorpus/cnc-vlo/cncdb"
 	"github.com/czcorpus/cnc-vlo/cnchook/profiles/components"
+	"github.com/czcorpus/cnc-vlo/cnf"
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+	"github.com/czcorpus/cnc-vlo/oaipmh/formats"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
 )
 
-func getAuthorList(data *cncdb.DBData) []components.AuthorComponent {
+const (
+	IdentifierTypeDOI    = "DOI"
+	IdentifierTypeHandle = "Handle"
+	IdentifierTypeLocal  = "local"
+	IdentifierTypePID    = "PID"
+
+	// IdentifierTypeInternal is used for the OAI record identifier
+	IdentifierTypeInternal = "internal"
+	IdentifierTypeURL      = "URL"
+)
+
+var (
+	doiRegexp    = regexp.MustCompile(`^(?i:(https?://(dx\.)?doi\.org/|doi:))?10\.\d{4,9}/\S+$`)
+	handleRegexp = regexp.MustCompile(`^(?i:(https?://hdl\.handle\.net/|hdl:))?\d+(\.\d+)*/\S+$`)
+)
+
+// getIdentifierType detects a type of the provided identifier
+// (DOI, Handle). Identifiers not matching any known PID pattern
+// are considered local.
+func getIdentifierType(identifier string) string {
+	identifier = strings.TrimSpace(identifier)
+	if doiRegexp.MatchString(identifier) {
+		return IdentifierTypeDOI
+	}
+	if handleRegexp.MatchString(identifier) {
+		return IdentifierTypeHandle
+	}
+	return IdentifierTypeLocal
+}
+
+// hasLetter tests whether a name token contains at least one letter
+// so that pure punctuation and numbers are not considered names
+func hasLetter(token string) bool {
+	return strings.IndexFunc(token, unicode.IsLetter) >= 0
+}
+
+// nameSuffixes are generational suffixes which are kept with
+// the last name (incl. the Czech `ml.` and `st.`)
+var nameSuffixes = map[string]bool{
+	"jr": true, "jr.": true, "sr": true, "sr.": true,
+	"ii": true, "iii": true, "iv": true,
+	"ml.": true, "st.": true,
+}
+
+func isNameSuffix(token string) bool {
+	return nameSuffixes[strings.ToLower(strings.TrimSpace(token))]
+}
+
+// isNameParticle tests whether a token is a lowercase particle
+// starting a multi-word last name (e.g. `van`, `de`)
+func isNameParticle(token string) bool {
+	r, _ := utf8.DecodeRuneInString(token)
+	return unicode.IsLower(r)
+}
+
+// nameTokens returns whitespace-separated tokens of a name
+// containing at least one letter
+func nameTokens(name string) []string {
+	ans := make([]string, 0, 3)
+	for _, token := range strings.Fields(name) {
+		if hasLetter(token) {
+			ans = append(ans, token)
+		}
+	}
+	return ans
+}
+
+// splitNameSuffix separates trailing name suffixes (e.g. `Jr.`)
+// from name tokens
+func splitNameSuffix(tokens []string) ([]string, []string) {
+	i := len(tokens)
+	for i > 1 && isNameSuffix(tokens[i-1]) {
+		i--
+	}
+	return tokens[:i], tokens[i:]
+}
+
+// withSuffix appends name suffixes to the last name
+func withSuffix(lastName string, suffix []string) string {
+	return strings.Join(append([]string{lastName}, suffix...), " ")
+}
+
+// parseAuthor parses a single author name in either
+// the `First [Middle] Last` or the `Last, First [Middle]` form.
+// Last names may consist of multiple words if they start with
+// a lowercase particle (`Ludwig van Beethoven`) and generational
+// suffixes (`Jr.`, `ml.`) are kept with the last name. Tokens
+// without any letters are skipped.
+func parseAuthor(author string) (components.AuthorComponent, bool) {
+	if last, first, found := strings.Cut(author, ","); found {
+		first, suffix, _ := strings.Cut(first, ",")
+		firstTokens, firstSuffix := splitNameSuffix(nameTokens(first))
+		suffixTokens := append(firstSuffix, nameTokens(suffix)...)
+		if len(firstTokens) == 1 && isNameSuffix(firstTokens[0]) {
+			// `First Last, Jr.`
+			parsed, ok := parseAuthor(last)
+			parsed.LastName = withSuffix(parsed.LastName, firstTokens)
+			return parsed, ok
+		}
+		lastTokens := nameTokens(last)
+		if len(lastTokens) == 0 {
+			return components.AuthorComponent{}, false
+		}
+		return components.AuthorComponent{
+			FirstName: strings.Join(firstTokens, " "),
+			LastName:  withSuffix(strings.Join(lastTokens, " "), suffixTokens),
+		}, true
+	}
+	tokens, suffix := splitNameSuffix(nameTokens(author))
+	switch len(tokens) {
+	case 0:
+		return components.AuthorComponent{}, false
+	case 1:
+		return components.AuthorComponent{LastName: withSuffix(tokens[0], suffix)}, true
+	}
+	lastStart := len(tokens) - 1
+	for i := 1; i < len(tokens)-1; i++ {
+		if isNameParticle(tokens[i]) {
+			lastStart = i
+			break
+		}
+	}
+	return components.AuthorComponent{
+		FirstName: strings.Join(tokens[:lastStart], " "),
+		LastName:  withSuffix(strings.Join(tokens[lastStart:], " "), suffix),
+	}, true
+}
+
+// isSingleLastName tests whether a name consists of a single word
+// (optionally with lowercase particles and a suffix, e.g. `van Gogh`)
+func isSingleLastName(name string) bool {
+	tokens, _ := splitNameSuffix(strings.Fields(name))
+	for len(tokens) > 1 && isNameParticle(tokens[0]) {
+		tokens = tokens[1:]
+	}
+	return len(tokens) == 1
+}
+
+// splitAuthorLine splits a single line of the authors field into
+// individual names. Semicolon is considered as the primary separator.
+// Without semicolons, comma is considered a separator unless the line
+// looks like a list of `Last, First [Middle]` pairs with single-word
+// last names.
+// Name suffixes separated by a comma (`Jan Novák, Jr.`) are kept
+// with the preceding name.
+func splitAuthorLine(line string) []string {
+	if strings.Contains(line, ";") {
+		return strings.Split(line, ";")
+	}
+	if !strings.Contains(line, ",") {
+		return []string{line}
+	}
+	parts := make([]string, 0, 4)
+	for _, part := range strings.Split(line, ",") {
+		if len(parts) > 0 && isNameSuffix(part) {
+			parts[len(parts)-1] += "," + part
+			continue
+		}
+		parts = append(parts, part)
+	}
+	isPairList := len(parts)%2 == 0
+	for i := 0; i < len(parts) && isPairList; i += 2 {
+		isPairList = isSingleLastName(parts[i]) && len(strings.Fields(parts[i+1])) > 0
+	}
+	if !isPairList {
+		return parts
+	}
+	ans := make([]string, 0, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		ans = append(ans, parts[i]+","+parts[i+1])
+	}
+	return ans
+}
+
+// getAuthorList parses the authors field of a record. If `maxAuthors`
+// is positive, parsing stops once the limit is reached and the list
+// is truncated (a warning is logged).
+func getAuthorList(data *cncdb.DBData, maxAuthors int) []components.AuthorComponent {
 	authors := []components.AuthorComponent{}
-	for _, author := range strings.Split(strings.ReplaceAll(data.Authors, "\r\n", "\n"), "\n") {
-		sAuthor := strings.Split(strings.Trim(author, " "), " ")
-		if len(sAuthor) == 1 {
-			authors = append(authors, components.AuthorComponent{LastName: sAuthor[0]})
-		} else if len(sAuthor) > 1 {
-			authors = append(authors, components.AuthorComponent{FirstName: sAuthor[0], LastName: sAuthor[1]})
+	for _, line := range strings.Split(strings.ReplaceAll(data.Authors, "\r\n", "\n"), "\n") {
+		for _, author := range splitAuthorLine(line) {
+			parsed, ok := parseAuthor(author)
+			if !ok {
+				continue
+			}
+			if maxAuthors > 0 && len(authors) >= maxAuthors {
+				log.Warn().
+					Int("recordId", data.ID).
+					Int("maxAuthors", maxAuthors).
+					Msg("too many authors, truncating the author list")
+				return authors
+			}
+			authors = append(authors, parsed)
 		}
 	}
 	return authors
 }
 
-func getKontextPath(corpusID string) string {
-	return fmt.Sprintf("https://www.korpus.cz/kontext/query?corpname=%s", corpusID)
+// getLanguages returns unique base languages of a corpus. For parallel
+// corpora, languages of all the aligned corpora are included.
+func getLanguages(data *cncdb.DBData) []language.Base {
+	ans := []language.Base{}
+	add := func(tag language.Tag) {
+		base, _ := tag.Base()
+		for _, b := range ans {
+			if b == base {
+				return
+			}
+		}
+		ans = append(ans, base)
+	}
+	if data.CorpusData.Locale != nil {
+		add(*data.CorpusData.Locale)
+	}
+	for _, tag := range data.CorpusData.ParallelLocales {
+		add(tag)
+	}
+	return ans
+}
+
+// splitKeywords splits keywords as produced by the GROUP_CONCAT
+// in the DB query. Empty input produces an empty slice.
+func splitKeywords(keywords string) []string {
+	ans := []string{}
+	for _, kw := range strings.Split(keywords, ",") {
+		if kw = strings.TrimSpace(kw); kw != "" {
+			ans = append(ans, kw)
+		}
+	}
+	return ans
+}
+
+// getSetSpec creates a valid OAI-PMH setSpec from a corplist name
+// (diacritics is removed and unsupported characters are replaced)
+func getSetSpec(corplist string) string {
+	var ans strings.Builder
+	for _, r := range norm.NFD.String(corplist) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) ||
+			strings.ContainsRune("-_.!~*'()", r)):
+			ans.WriteRune(r)
+		default:
+			ans.WriteRune('_')
+		}
+	}
+	return ans.String()
+}
+
+// corplistSetSpecs creates unique setSpecs (mapped by corplist IDs)
+// for all the provided corplists. In case names of more corplists
+// map to the same setSpec (e.g. "Čeština" and "Cestina"), all
+// the colliding setSpecs are disambiguated by corplist IDs. The same
+// applies to a corplist colliding with the parent keyword set.
+func corplistSetSpecs(corplists []cncdb.Corplist) map[int]string {
+	counts := map[string]int{KeywordSet: 1}
+	for _, corplist := range corplists {
+		counts[getSetSpec(corplist.Name)]++
+	}
+	ans := make(map[int]string, len(corplists))
+	for _, corplist := range corplists {
+		setSpec := getSetSpec(corplist.Name)
+		if counts[setSpec] > 1 {
+			setSpec = fmt.Sprintf("%s_%d", setSpec, corplist.ID)
+		}
+		ans[corplist.ID] = setSpec
+	}
+	return ans
+}
+
+func getSetSpecs(data *cncdb.DBData) []string {
+	if len(data.CorplistSetSpecs) == 0 && len(data.KeywordIDs) == 0 {
+		return nil
+	}
+	ans := make([]string, 0, len(data.CorplistSetSpecs)+len(data.KeywordIDs))
+	ans = append(ans, data.CorplistSetSpecs...)
+	for _, keyword := range data.KeywordIDs {
+		ans = append(ans, KeywordSetPrefix+getSetSpec(keyword))
+	}
+	return ans
+}
+
+// resolveSet converts a requested set to a DB filter. An empty set
+// means no filtering. For unknown sets, false is returned.
+func (c *CNCHook) resolveSet(ctx context.Context, set string) (cncdb.SetFilter, bool, error) {
+	if set == "" {
+		return cncdb.SetFilter{}, true, nil
+	}
+	if c.conf.Conversion.KeywordSets {
+		if set == KeywordSet {
+			return cncdb.SetFilter{AnyKeyword: true}, true, nil
+		}
+		if setSpec, ok := strings.CutPrefix(set, KeywordSetPrefix); ok {
+			keywords, err := c.db.ListKeywords(ctx)
+			if err != nil {
+				return cncdb.SetFilter{}, false, err
+			}
+			for _, keyword := range keywords {
+				if getSetSpec(keyword.ID) == setSpec {
+					return cncdb.SetFilter{Keyword: keyword.ID}, true, nil
+				}
+			}
+			return cncdb.SetFilter{}, false, nil
+		}
+	}
+	if c.conf.Conversion.IncludeSetSpecs {
+		corplists, err := c.db.ListCorplists(ctx)
+		if err != nil {
+			return cncdb.SetFilter{}, false, err
+		}
+		for id, setSpec := range corplistSetSpecs(corplists) {
+			if setSpec == set {
+				return cncdb.SetFilter{CorplistID: id}, true, nil
+			}
+		}
+	}
+	return cncdb.SetFilter{}, false, nil
+}
+
+func sliceToPointers[T any](data []T) []*T {
+	ans := make([]*T, len(data))
+	for i := range data {
+		ans[i] = &data[i]
+	}
+	return ans
+}
+
+// oaiIdentifier creates an OAI identifier of a record
+// (`oai:<namespace>:<ID>` in case a namespace is configured)
+func (c *CNCHook) oaiIdentifier(recordID string) string {
+	if c.conf.RepositoryInfo.IdentifierNamespace == "" {
+		return recordID
+	}
+	return "oai:" + c.conf.RepositoryInfo.IdentifierNamespace + ":" + recordID
+}
+
+// parseIdentifier returns a record ID from an OAI identifier.
+// Both the prefixed and the bare form are accepted. In case the
+// identifier has a prefix of another repository, false is returned.
+func (c *CNCHook) parseIdentifier(identifier string) (string, bool) {
+	if !strings.HasPrefix(identifier, "oai:") {
+		return identifier, true
+	}
+	if c.conf.RepositoryInfo.IdentifierNamespace == "" {
+		return "", false
+	}
+	return strings.CutPrefix(identifier, "oai:"+c.conf.RepositoryInfo.IdentifierNamespace+":")
+}
+
+// requestRecordID returns a record ID from an identifier of a request.
+// With StrictIdentifiers configured, OAI-PMH requests must use
+// the prefixed form. Self-links (`/record/<ID>`) are not OAI-PMH
+// requests (there is no verb) and always accept bare IDs.
+func (c *CNCHook) requestRecordID(req oaipmh.OAIPMHRequest) (string, bool) {
+	info := c.conf.RepositoryInfo
+	if req.Verb != "" && info.StrictIdentifiers && info.IdentifierNamespace != "" &&
+		!strings.HasPrefix(req.Identifier, "oai:") {
+		return "", false
+	}
+	return c.parseIdentifier(req.Identifier)
+}
+
+// getSourceRef creates a reference to a record the resource
+// is derived from
+func (c *CNCHook) getSourceRef(data *cncdb.DBData) string {
+	return fmt.Sprintf("%s%d", c.conf.MetadataValues.SourceEntityBase, data.SourceID.Int64)
+}
+
+// getRelations lists typed relations of the resource to other
+// resources (incl. the resource it is derived from)
+func (c *CNCHook) getRelations(data *cncdb.DBData) []formats.TypedElement {
+	var ans []formats.TypedElement
+	if data.SourceID.Valid {
+		ans = append(ans, formats.TypedElement{Type: RelationTypeIsDerivedFrom, Value: c.getSourceRef(data)})
+	}
+	for _, rel := range data.Relations {
+		ans = append(ans, formats.TypedElement{Type: rel.Type, Value: rel.Target})
+	}
+	return ans
+}
+
+// getKontextPath returns URL of KonText query page for the corpus
+// based on the template with the `{corpus}` placeholder. The corpus
+// name is escaped as it may contain reserved characters.
+func getKontextPath(template, corpusID string) string {
+	return strings.ReplaceAll(template, "{corpus}", url.QueryEscape(corpusID))
+}
+
+// getAccessPageURL returns URL of an access request page
+// of a restricted corpus
+func (c *CNCHook) getAccessPageURL(data *cncdb.DBData) string {
+	return strings.NewReplacer(
+		"{corpus}", url.QueryEscape(data.Name),
+		"{id}", fmt.Sprint(data.ID),
+	).Replace(c.conf.Conversion.RestrictedAccess.AccessPageURL)
+}
+
+// setRegistryAttrs exposes corpus tagsets and alignments
+// as annotation info and format entries. With `annotationTagsets`,
+// the tagset names are listed in the annotation info as well.
+func setRegistryAttrs(dataInfo *components.DataInfoComponent, attrs cncdb.RegistryAttrs, annotationTagsets bool) {
+	var annotations []string
+	var tagsetFormats []components.FormatComponent
+	for _, tagset := range attrs.Tagsets {
+		tagsetFormats = append(tagsetFormats, components.FormatComponent{Type: FormatTypeTagset, Name: tagset})
+	}
+	if len(tagsetFormats) > 0 {
+		annotations = append(annotations, AnnotationTypeTags)
+		dataInfo.Formats = &tagsetFormats
+	}
+	if len(attrs.AlignedCorpora) > 0 {
+		annotations = append(annotations, AnnotationTypeAlignment)
+	}
+	if len(annotations) > 0 {
+		dataInfo.AnnotationInfo = &components.AnnotationInfoComponent{AnnotationTypes: annotations}
+		if annotationTagsets {
+			dataInfo.AnnotationInfo.Tagsets = attrs.Tagsets
+		}
+	}
+}
+
+// getContactEmail returns the contact person email unless the record
+// is flagged by the redaction policy in which case the repository
+// admin email is used instead
+func (c *CNCHook) getContactEmail(data *cncdb.DBData) string {
+	if c.conf.Conversion.ContactRedaction.Applies(data.ID, data.License) {
+		if len(c.conf.RepositoryInfo.AdminEmail) > 0 {
+			return c.conf.RepositoryInfo.AdminEmail[0]
+		}
+		return ""
+	}
+	return data.ContactPerson.Email
+}
+
+// localizeContact selects a version of the contact person
+// affiliation matching the display language. The Czech version
+// is used in case there is no English one.
+func localizeContact(data *cncdb.DBData, lang string) {
+	if lang != "cs" && data.ContactPerson.AffiliationEN.String != "" {
+		data.ContactPerson.Affiliation = data.ContactPerson.AffiliationEN
+	}
+}
+
+// getContactPerson creates contact person component with both names
+// filled in if possible. A single name is used as the last name.
+// In case there is no name at all, the configured default contact
+// is used.
+func (c *CNCHook) getContactPerson(data *cncdb.DBData) components.ContactPersonComponent {
+	firstName := strings.TrimSpace(data.ContactPerson.Firstname)
+	lastName := strings.TrimSpace(data.ContactPerson.Lastname)
+	if firstName == "" && lastName == "" {
+		dflt := c.conf.MetadataValues.DefaultContact
+		return components.ContactPersonComponent{
+			LastName:    dflt.LastName,
+			FirstName:   dflt.FirstName,
+			Email:       dflt.Email,
+			Affiliation: dflt.Affiliation,
+		}
+	}
+	if lastName == "" {
+		lastName, firstName = firstName, ""
+	}
+	return components.ContactPersonComponent{
+		LastName:    lastName,
+		FirstName:   firstName,
+		Email:       c.getContactEmail(data),
+		Affiliation: data.ContactPerson.Affiliation.String,
+	}
+}
+
+// sortResourceProxies orders resource proxies by their types based
+// on the `order` list (CLARIN treats the first proxy as the primary
+// resource). Proxies of types not mentioned in the list are placed
+// last, the original order is kept otherwise.
+func sortResourceProxies(proxies []formats.CMDIResourceProxy, order []string) {
+	if len(order) == 0 {
+		return
+	}
+	priority := func(rt formats.ResourceType) int {
+		if i := slices.Index(order, string(rt)); i >= 0 {
+			return i
+		}
+		return len(order)
+	}
+	sort.SliceStable(proxies, func(i, j int) bool {
+		return priority(proxies[i].ResourceType.Value) < priority(proxies[j].ResourceType.Value)
+	})
+}
+
+// getPIDURL returns a resolvable URL of record's PID
+// or an empty string if there is no PID
+func (c *CNCHook) getPIDURL(data *cncdb.DBData) string {
+	pid := strings.TrimSpace(data.PID.String)
+	if pid == "" {
+		return ""
+	}
+	if strings.HasPrefix(pid, "http://") || strings.HasPrefix(pid, "https://") {
+		return pid
+	}
+	pid = strings.TrimPrefix(pid, "hdl:")
+	return strings.TrimRight(c.conf.MetadataValues.PIDResolverURL, "/") + "/" + pid
+}
+
+// getDataCiteIdentifier returns a DOI of a record. As the DataCite
+// identifier is mandatory, records without a DOI fall back to
+// a Handle or to the record URL.
+func (c *CNCHook) getDataCiteIdentifier(data *cncdb.DBData) formats.DataCiteIdentifier {
+	pid := strings.TrimSpace(data.PID.String)
+	switch getIdentifierType(pid) {
+	case IdentifierTypeDOI:
+		return formats.DataCiteIdentifier{Type: IdentifierTypeDOI, Value: pid[strings.Index(pid, "10."):]}
+	case IdentifierTypeHandle:
+		return formats.DataCiteIdentifier{Type: IdentifierTypeHandle, Value: c.getPIDURL(data)}
+	}
+	return formats.DataCiteIdentifier{Type: IdentifierTypeURL, Value: c.getRecordURL(fmt.Sprint(data.ID))}
+}
+
+// getDescriptions returns all the non-empty descriptions of a record.
+// In case there is none, the configured default description is used
+// (if any).
+func (c *CNCHook) getDescriptions(data *cncdb.DBData) formats.MultilangArray {
+	var ans formats.MultilangArray
+	ans.Add(data.DescEN.String, "en")
+	ans.Add(data.DescCS.String, "cs")
+	if len(ans) == 0 {
+		ans.Add(c.conf.MetadataValues.DefaultDescription, c.conf.DefaultLanguage())
+	}
+	return ans
+}
+
+// getCitation creates a recommended citation in the configured
+// format for records with a DOI. Other records are not cited.
+func (c *CNCHook) getCitation(data *cncdb.DBData) string {
+	if c.conf.Conversion.CitationFormat == "" || !data.PID.Valid ||
+		getIdentifierType(data.PID.String) != IdentifierTypeDOI {
+		return ""
+	}
+	pid := strings.TrimSpace(data.PID.String)
+	doiURL := "https://doi.org/" + pid[strings.Index(pid, "10."):]
+	title := data.TitleEN
+	if title == "" {
+		title = data.TitleCS
+	}
+	year := getIssuedYear(data.DateIssued)
+	authors := getAuthorList(data, c.conf.Conversion.MaxAuthors)
+	publisher := c.conf.MetadataValues.Publisher
+
+	var ans strings.Builder
+	switch c.conf.Conversion.CitationFormat {
+	case cnf.CitationFormatAPA:
+		names := make([]string, len(authors))
+		for i, author := range authors {
+			names[i] = author.LastName
+			if initials := getInitials(author.FirstName); initials != "" {
+				names[i] += ", " + initials
+			}
+		}
+		if year == "" {
+			year = "n.d."
+		}
+		if len(names) > 0 {
+			ans.WriteString(joinNames(names, ", ", ", & ") + " ")
+		}
+		ans.WriteString(fmt.Sprintf("(%s). %s. ", year, title))
+		if publisher != "" {
+			ans.WriteString(publisher + ". ")
+		}
+	case cnf.CitationFormatPlain:
+		names := make([]string, len(authors))
+		for i, author := range authors {
+			names[i] = strings.TrimSpace(author.FirstName + " " + author.LastName)
+		}
+		if len(names) > 0 {
+			ans.WriteString(joinNames(names, ", ", ", ") + ": ")
+		}
+		ans.WriteString(title + ". ")
+		if issued := joinNonEmpty(", ", publisher, year); issued != "" {
+			ans.WriteString(issued + ". ")
+		}
+	}
+	ans.WriteString(doiURL)
+	return ans.String()
+}
+
+func joinNonEmpty(sep string, values ...string) string {
+	ans := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			ans = append(ans, v)
+		}
+	}
+	return strings.Join(ans, sep)
+}
+
+// getIssuedYear extracts a year from the date of issue
+// (e.g. `2020` or `2020-05-01`)
+func getIssuedYear(dateIssued string) string {
+	dateIssued = strings.TrimSpace(dateIssued)
+	if len(dateIssued) < 4 {
+		return ""
+	}
+	for _, r := range dateIssued[:4] {
+		if !unicode.IsDigit(r) {
+			return ""
+		}
+	}
+	return dateIssued[:4]
+}
+
+// getInitials converts first names to initials (e.g. `Jan Karel` to `J. K.`)
+func getInitials(firstName string) string {
+	parts := strings.Fields(firstName)
+	for i, part := range parts {
+		parts[i] = string([]rune(part)[0]) + "."
+	}
+	return strings.Join(parts, " ")
+}
+
+// joinNames joins names using `lastSep` for the last one
+func joinNames(names []string, sep, lastSep string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], sep) + lastSep + names[len(names)-1]
+}
+
+// getTitles returns record titles in all the available languages
+func getTitles(data *cncdb.DBData) formats.MultilangArray {
+	var ans formats.MultilangArray
+	ans.Add(data.TitleEN, "en")
+	ans.Add(data.TitleCS, "cs")
+	return ans
+}
+
+// primaryLanguage returns a language both title and description
+// are available in (English is preferred) so harvesters can display
+// them as a matching pair. Without such a language, the language
+// of the first non-empty title is used.
+func primaryLanguage(data *cncdb.DBData) string {
+	titles := map[string]string{"en": data.TitleEN, "cs": data.TitleCS}
+	descs := map[string]string{"en": data.DescEN.String, "cs": data.DescCS.String}
+	for _, lang := range []string{"en", "cs"} {
+		if strings.TrimSpace(titles[lang]) != "" && strings.TrimSpace(descs[lang]) != "" {
+			return lang
+		}
+	}
+	if strings.TrimSpace(data.TitleEN) == "" && strings.TrimSpace(data.TitleCS) != "" {
+		return "cs"
+	}
+	return "en"
+}
+
+// orderByLanguage moves values in the specified language
+// to the front while keeping the order of the others
+func orderByLanguage(values formats.MultilangArray, lang string) formats.MultilangArray {
+	ans := make(formats.MultilangArray, 0, len(values))
+	for _, v := range values {
+		if v.Lang == lang {
+			ans = append(ans, v)
+		}
+	}
+	for _, v := range values {
+		if v.Lang != lang {
+			ans = append(ans, v)
+		}
+	}
+	return ans
+}
+
+// normalizeText removes byte order marks, replaces non-breaking
+// spaces and trims the text. Optionally, internal whitespace
+// is collapsed into single spaces.
+func normalizeText(text string, collapseWhitespace bool) string {
+	text = strings.ReplaceAll(text, "\uFEFF", "")
+	text = strings.ReplaceAll(text, "\u00A0", " ")
+	if collapseWhitespace {
+		return strings.Join(strings.Fields(text), " ")
+	}
+	return strings.TrimSpace(text)
+}
+
+// normalizeLines normalizes each line of the text separately
+// so line breaks are kept (e.g. as separators of authors)
+func normalizeLines(text string, collapseWhitespace bool) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = normalizeText(line, collapseWhitespace)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// normalizeTextFields returns a copy of data with normalized
+// free text fields
+func (c *CNCHook) normalizeTextFields(data *cncdb.DBData) *cncdb.DBData {
+	collapse := c.conf.Conversion.CollapseWhitespace
+	ans := *data
+	ans.TitleEN = normalizeText(ans.TitleEN, collapse)
+	ans.TitleCS = normalizeText(ans.TitleCS, collapse)
+	ans.DescEN.String = normalizeText(ans.DescEN.String, collapse)
+	ans.DescCS.String = normalizeText(ans.DescCS.String, collapse)
+	ans.Authors = normalizeLines(ans.Authors, collapse)
+	ans.CorpusData.Keywords.String = normalizeText(ans.CorpusData.Keywords.String, collapse)
+	return &ans
+}
+
+// applyDefaultLocale sets the configured default language for corpora
+// with no locale (unless languages of aligned corpora are known)
+func (c *CNCHook) applyDefaultLocale(data *cncdb.DBData) {
+	if c.conf.Conversion.DefaultCorpusLanguage == "" ||
+		MetadataType(data.Type) != CorpusMetadataType ||
+		data.CorpusData.Locale != nil || len(data.CorpusData.ParallelLocales) > 0 {
+		return
+	}
+	tag := language.Make(c.conf.Conversion.DefaultCorpusLanguage)
+	log.Debug().
+		Int("recordId", data.ID).
+		Str("language", tag.String()).
+		Msg("corpus has no locale, using the default language")
+	data.CorpusData.Locale = &tag
+}
+
+// getFCSEndpointURL returns URL of the configured CLARIN-FCS endpoint
+// with the SRU version matching the supported FCS version (or an empty
+// string if no endpoint is configured)
+func (c *CNCHook) getFCSEndpointURL() string {
+	endpoint := c.conf.Conversion.FCSEndpoint
+	if endpoint.URL == "" {
+		return ""
+	}
+	fcsURL, err := url.Parse(endpoint.URL)
+	if err != nil {
+		return endpoint.URL
+	}
+	query := fcsURL.Query()
+	query.Set("version", endpoint.SRUVersion())
+	fcsURL.RawQuery = query.Encode()
+	return fcsURL.String()
+}
+
+// getCMDIEnvelope returns the configured CMDI envelope version
+func getCMDIEnvelope(conf *cnf.Conf) formats.CMDIEnvelope {
+	return formats.CMDIEnvelope{
+		Version:   conf.Conversion.CMDIVersion,
+		SchemaURL: conf.Conversion.CMDIEnvelopeSchemaURL,
+	}
+}
+
+// getRecordURL returns URL of the record's landing page
+func (c *CNCHook) getRecordURL(recordID string) string {
+	return fmt.Sprintf("%s/record/%s", c.conf.RepositoryInfo.BaseURL, url.PathEscape(recordID))
+}
+
+// getProvenance describes origin of a record ingested from
+// an upstream repository
+func (c *CNCHook) getProvenance(metadataPrefix string, data *cncdb.DBData) oaipmh.OAIPMHProvenance {
+	var namespace string
+	for _, f := range c.metadataFormats {
+		if f.MetadataPrefix == metadataPrefix {
+			namespace = f.MetadataNamespace
+			break
+		}
+	}
+	return oaipmh.NewOAIPMHProvenance(
+		oaipmh.OAIPMHOriginDescription{
+			HarvestDate:       oaipmh.UTCTime(data.Origin.Synced.Time),
+			Altered:           true,
+			BaseURL:           data.Origin.BaseURL.String,
+			Identifier:        data.Origin.Identifier.String,
+			Datestamp:         oaipmh.UTCTime(data.Origin.Datestamp.Time),
+			MetadataNamespace: namespace,
+		},
+	)
 }
@@ -0,0 +1,316 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnchook
+
+import (
+	"database/sql"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/czcorpus/cnc-vlo/cnchook/profiles/components"
+	"github.com/czcorpus/cnc-vlo/cnf"
+	"github.com/czcorpus/cnc-vlo/oaipmh/formats"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestGetIdentifierTypeDOI(t *testing.T) {
+	assert.Equal(t, IdentifierTypeDOI, getIdentifierType("10.1234/abc.def"))
+	assert.Equal(t, IdentifierTypeDOI, getIdentifierType("doi:10.1234/abc"))
+	assert.Equal(t, IdentifierTypeDOI, getIdentifierType("https://doi.org/10.1234/abc"))
+}
+
+func TestGetIdentifierTypeHandle(t *testing.T) {
+	assert.Equal(t, IdentifierTypeHandle, getIdentifierType("11234/1-1234"))
+	assert.Equal(t, IdentifierTypeHandle, getIdentifierType("hdl:11234/1-1234"))
+	assert.Equal(t, IdentifierTypeHandle, getIdentifierType("http://hdl.handle.net/11234/1-1234"))
+}
+
+func TestGetIdentifierTypeLocal(t *testing.T) {
+	assert.Equal(t, IdentifierTypeLocal, getIdentifierType("syn2020"))
+	assert.Equal(t, IdentifierTypeLocal, getIdentifierType("intercorp_v16_en"))
+	assert.Equal(t, IdentifierTypeLocal, getIdentifierType(""))
+}
+
+func TestGetLanguagesParallelCorpus(t *testing.T) {
+	cs := language.MustParse("cs-CZ")
+	data := &cncdb.DBData{
+		CorpusData: cncdb.CorpusData{
+			Locale:          &cs,
+			ParallelLocales: []language.Tag{language.MustParse("cs-CZ"), language.MustParse("en-US")},
+		},
+	}
+	langs := getLanguages(data)
+	assert.Len(t, langs, 2)
+	assert.Equal(t, "cs", langs[0].String())
+	assert.Equal(t, "en", langs[1].String())
+}
+
+func TestGetLanguagesNoLocale(t *testing.T) {
+	assert.Empty(t, getLanguages(&cncdb.DBData{}))
+}
+
+func TestSplitKeywords(t *testing.T) {
+	assert.Equal(t, []string{}, splitKeywords(""))
+	assert.Equal(t, []string{"spoken"}, splitKeywords("spoken"))
+	assert.Equal(t, []string{"spoken", "written"}, splitKeywords("spoken, written,"))
+}
+
+func TestGetSetSpec(t *testing.T) {
+	assert.Equal(t, "public", getSetSpec("public"))
+	assert.Equal(t, "Korpusy_CNK", getSetSpec("Korpusy ČNK"))
+	assert.Equal(t, "a_b.c-d", getSetSpec("a/b.c-d"))
+}
+
+func TestGetAuthorListNewlines(t *testing.T) {
+	authors := getAuthorList(&cncdb.DBData{Authors: "Jan Novák\r\nPetr Svoboda\n"}, 0)
+	assert.Equal(
+		t,
+		[]components.AuthorComponent{
+			{FirstName: "Jan", LastName: "Novák"},
+			{FirstName: "Petr", LastName: "Svoboda"},
+		},
+		authors,
+	)
+}
+
+func TestGetAuthorListSemicolons(t *testing.T) {
+	authors := getAuthorList(&cncdb.DBData{Authors: "Novák, Jan; Svoboda, Petr"}, 0)
+	assert.Equal(
+		t,
+		[]components.AuthorComponent{
+			{FirstName: "Jan", LastName: "Novák"},
+			{FirstName: "Petr", LastName: "Svoboda"},
+		},
+		authors,
+	)
+}
+
+func TestGetAuthorListCommas(t *testing.T) {
+	assert.Equal(
+		t,
+		[]components.AuthorComponent{{FirstName: "Jan", LastName: "Novák"}},
+		getAuthorList(&cncdb.DBData{Authors: "Novák, Jan"}, 0),
+	)
+	assert.Equal(
+		t,
+		[]components.AuthorComponent{
+			{FirstName: "Jan", LastName: "Novák"},
+			{FirstName: "Petr", LastName: "Svoboda"},
+		},
+		getAuthorList(&cncdb.DBData{Authors: "Jan Novák, Petr Svoboda"}, 0),
+	)
+}
+
+func TestGetAuthorListMixed(t *testing.T) {
+	authors := getAuthorList(&cncdb.DBData{Authors: "Novák, Jan; Svoboda, Petr\nEva Dvořáková\nČNK"}, 0)
+	assert.Equal(
+		t,
+		[]components.AuthorComponent{
+			{FirstName: "Jan", LastName: "Novák"},
+			{FirstName: "Petr", LastName: "Svoboda"},
+			{FirstName: "Eva", LastName: "Dvořáková"},
+			{LastName: "ČNK"},
+		},
+		authors,
+	)
+}
+
+func TestGetAuthorListTruncated(t *testing.T) {
+	authors := strings.Repeat("Novák, Jan; ", 100000)
+	parsed := getAuthorList(&cncdb.DBData{Authors: authors}, 500)
+	assert.Len(t, parsed, 500)
+	assert.Equal(t, components.AuthorComponent{FirstName: "Jan", LastName: "Novák"}, parsed[499])
+}
+
+func TestGetAuthorListJunkTokens(t *testing.T) {
+	authors := getAuthorList(
+		&cncdb.DBData{Authors: "Novák, Jan; --- ; 12345; Svoboda, 42\n...\nJan 2024 Dvořák"},
+		0,
+	)
+	assert.Equal(
+		t,
+		[]components.AuthorComponent{
+			{FirstName: "Jan", LastName: "Novák"},
+			{LastName: "Svoboda"},
+			{FirstName: "Jan", LastName: "Dvořák"},
+		},
+		authors,
+	)
+}
+
+func TestGetAuthorListNameForms(t *testing.T) {
+	for _, tc := range []struct {
+		authors  string
+		expected []components.AuthorComponent
+	}{
+		{"Jan Novák", []components.AuthorComponent{{FirstName: "Jan", LastName: "Novák"}}},
+		{"  Jiří Šťastný \t\n", []components.AuthorComponent{{FirstName: "Jiří", LastName: "Šťastný"}}},
+		{"Novák, Jan", []components.AuthorComponent{{FirstName: "Jan", LastName: "Novák"}}},
+		{"Dvořáková, Eva Marie", []components.AuthorComponent{{FirstName: "Eva Marie", LastName: "Dvořáková"}}},
+		{"Jan Karel Novák", []components.AuthorComponent{{FirstName: "Jan Karel", LastName: "Novák"}}},
+		{"Ludwig van Beethoven", []components.AuthorComponent{{FirstName: "Ludwig", LastName: "van Beethoven"}}},
+		{"Jan Novák Jr.", []components.AuthorComponent{{FirstName: "Jan", LastName: "Novák Jr."}}},
+		{"Jan Novák, Jr.", []components.AuthorComponent{{FirstName: "Jan", LastName: "Novák Jr."}}},
+		{"Novák, Jan, ml.", []components.AuthorComponent{{FirstName: "Jan", LastName: "Novák ml."}}},
+		{"Novák, Jan st.", []components.AuthorComponent{{FirstName: "Jan", LastName: "Novák st."}}},
+		{"Novák Jr., Jan", []components.AuthorComponent{{FirstName: "Jan", LastName: "Novák Jr."}}},
+		{"van Beethoven, Ludwig", []components.AuthorComponent{{FirstName: "Ludwig", LastName: "van Beethoven"}}},
+		{"Čermák", []components.AuthorComponent{{LastName: "Čermák"}}},
+		{"Čermák ", []components.AuthorComponent{{LastName: "Čermák"}}},
+		{
+			"Jan Karel Novák\nNovák, Jan, Jr.; Dvořáková, Eva",
+			[]components.AuthorComponent{
+				{FirstName: "Jan Karel", LastName: "Novák"},
+				{FirstName: "Jan", LastName: "Novák Jr."},
+				{FirstName: "Eva", LastName: "Dvořáková"},
+			},
+		},
+	} {
+		assert.Equal(t, tc.expected, getAuthorList(&cncdb.DBData{Authors: tc.authors}, 0), tc.authors)
+	}
+}
+
+func TestContactPersonBothNames(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.ContactPerson = cncdb.ContactPersonData{Firstname: "Jan", Lastname: "Novák", Email: "jan@example.com"}
+	contact := hook.getContactPerson(data)
+	assert.Equal(t, "Jan", contact.FirstName)
+	assert.Equal(t, "Novák", contact.LastName)
+	assert.Equal(t, "jan@example.com", contact.Email)
+}
+
+func TestContactPersonSingleName(t *testing.T) {
+	hook := newTestHook()
+	data := newTestData()
+	data.ContactPerson = cncdb.ContactPersonData{Firstname: "Novák", Email: "jan@example.com"}
+	contact := hook.getContactPerson(data)
+	assert.Equal(t, "", contact.FirstName)
+	assert.Equal(t, "Novák", contact.LastName)
+	assert.Equal(t, "jan@example.com", contact.Email)
+}
+
+func TestContactPersonNoName(t *testing.T) {
+	hook := newTestHook()
+	hook.conf.MetadataValues.DefaultContact = cnf.ContactPerson{
+		LastName: "Support", Email: "support@korpus.cz", Affiliation: "UCNK",
+	}
+	data := newTestData()
+	data.ContactPerson = cncdb.ContactPersonData{Firstname: " ", Email: "jan@example.com"}
+	contact := hook.getContactPerson(data)
+	assert.Equal(t, "Support", contact.LastName)
+	assert.Equal(t, "support@korpus.cz", contact.Email)
+	assert.Equal(t, "UCNK", contact.Affiliation)
+}
+
+func TestNormalizeTextBOMAndPadding(t *testing.T) {
+	assert.Equal(t, "SYN2020", normalizeText("\uFEFF  SYN2020 \n", false))
+	assert.Equal(t, "Jan  Novák", normalizeText(" Jan  Novák ", false))
+}
+
+func TestNormalizeTextCollapse(t *testing.T) {
+	assert.Equal(t, "Jan Novák", normalizeText("\uFEFFJan \t Novák ", true))
+}
+
+const testKontextQueryTemplate = "https://www.korpus.cz/kontext/query?corpname={corpus}"
+
+func TestGetKontextPath(t *testing.T) {
+	assert.Equal(
+		t,
+		"https://www.korpus.cz/kontext/query?corpname=syn2020",
+		getKontextPath(testKontextQueryTemplate, "syn2020"),
+	)
+	assert.Equal(
+		t,
+		"https://www.korpus.cz/kontext/query?corpname=omezeni%2Fsyn+2020%26x%3D1",
+		getKontextPath(testKontextQueryTemplate, "omezeni/syn 2020&x=1"),
+	)
+}
+
+func TestGetKontextPathIsValidURL(t *testing.T) {
+	for _, name := range []string{"my corpus", "a/b", "c#d", "e?f=g", "čeština"} {
+		u, err := url.Parse(getKontextPath(testKontextQueryTemplate, name))
+		assert.NoError(t, err)
+		assert.Equal(t, name, u.Query().Get("corpname"))
+	}
+}
+
+func TestGetRecordURLEscaping(t *testing.T) {
+	hook := &CNCHook{conf: &cnf.Conf{RepositoryInfo: cnf.RepositoryInfo{BaseURL: "http://localhost"}}}
+	assert.Equal(t, "http://localhost/record/12", hook.getRecordURL("12"))
+	assert.Equal(t, "http://localhost/record/a%2Fb%20c", hook.getRecordURL("a/b c"))
+}
+
+func TestPrimaryLanguage(t *testing.T) {
+	data := &cncdb.DBData{TitleEN: "Corpus", TitleCS: "Korpus"}
+	assert.Equal(t, "en", primaryLanguage(data))
+	data.DescCS = sql.NullString{String: "Popis", Valid: true}
+	assert.Equal(t, "cs", primaryLanguage(data))
+	data.DescEN = sql.NullString{String: "Description", Valid: true}
+	assert.Equal(t, "en", primaryLanguage(data))
+	data = &cncdb.DBData{TitleCS: "Korpus"}
+	assert.Equal(t, "cs", primaryLanguage(data))
+	data.DescEN = sql.NullString{String: "Description", Valid: true}
+	assert.Equal(t, "cs", primaryLanguage(data))
+}
+
+func TestOrderByLanguage(t *testing.T) {
+	values := formats.MultilangArray{{Lang: "en", Value: "a"}, {Lang: "cs", Value: "b"}, {Lang: "de", Value: "c"}}
+	assert.Equal(
+		t,
+		formats.MultilangArray{{Lang: "cs", Value: "b"}, {Lang: "en", Value: "a"}, {Lang: "de", Value: "c"}},
+		orderByLanguage(values, "cs"),
+	)
+	assert.Equal(t, values, orderByLanguage(values, "en"))
+}
+
+func TestOAIIdentifierRoundTrip(t *testing.T) {
+	hook := &CNCHook{conf: &cnf.Conf{RepositoryInfo: cnf.RepositoryInfo{IdentifierNamespace: "korpus.cz"}}}
+	identifier := hook.oaiIdentifier("42")
+	assert.Equal(t, "oai:korpus.cz:42", identifier)
+	recordID, ok := hook.parseIdentifier(identifier)
+	assert.True(t, ok)
+	assert.Equal(t, "42", recordID)
+}
+
+func TestParseIdentifierBare(t *testing.T) {
+	hook := &CNCHook{conf: &cnf.Conf{RepositoryInfo: cnf.RepositoryInfo{IdentifierNamespace: "korpus.cz"}}}
+	recordID, ok := hook.parseIdentifier("42")
+	assert.True(t, ok)
+	assert.Equal(t, "42", recordID)
+}
+
+func TestParseIdentifierForeignPrefix(t *testing.T) {
+	hook := &CNCHook{conf: &cnf.Conf{RepositoryInfo: cnf.RepositoryInfo{IdentifierNamespace: "korpus.cz"}}}
+	for _, identifier := range []string{"oai:lindat.cz:42", "oai:korpus.cz.example:42", "oai:korpus.cz"} {
+		_, ok := hook.parseIdentifier(identifier)
+		assert.False(t, ok, identifier)
+	}
+}
+
+func TestOAIIdentifierNoNamespace(t *testing.T) {
+	hook := &CNCHook{conf: &cnf.Conf{}}
+	assert.Equal(t, "42", hook.oaiIdentifier("42"))
+	recordID, ok := hook.parseIdentifier("42")
+	assert.True(t, ok)
+	assert.Equal(t, "42", recordID)
+	_, ok = hook.parseIdentifier("oai:korpus.cz:42")
+	assert.False(t, ok)
+}
@@ -22,3 +22,26 @@ const (
 	CorpusMetadataType  MetadataType = "corpus"
 	ServiceMetadataType MetadataType = "service"
 )
+
+const (
+	DateTypeAvailable = "available"
+)
+
+const (
+	RelationTypeIsDerivedFrom = "isDerivedFrom"
+)
+
+const (
+	AnnotationTypeTags      = "tags"
+	AnnotationTypeAlignment = "alignment"
+	FormatTypeTagset        = "tagset"
+)
+
+const (
+	// KeywordSet is a setSpec of the parent set of all
+	// the keyword based sets
+	KeywordSet = "keyword"
+
+	// KeywordSetPrefix prefixes setSpecs of keyword based sets
+	KeywordSetPrefix = KeywordSet + ":"
+)
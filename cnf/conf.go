@@ -18,35 +18,133 @@ package cnf
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/czcorpus/cnc-gokit/logging"
 	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+	"github.com/czcorpus/cnc-vlo/oaipmh/formats"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
 )
 
 const (
-	dfltServerWriteTimeoutSecs = 30
-	dfltLanguage               = "en"
-	dfltTimeZone               = "Europe/Prague"
+	dfltServerWriteTimeoutSecs      = 30
+	dfltServerReadHeaderTimeoutSecs = 10
+	dfltServerIdleTimeoutSecs       = 60
+	dfltServerMaxHeaderBytes        = 1 << 20
+	dfltLanguage                    = "en"
+	dfltTimeZone                    = "Europe/Prague"
+	dfltIdentifyCacheTTLSecs        = 60
+	dfltSitemapCacheTTLSecs         = 3600
+	dfltPIDResolverURL              = "https://hdl.handle.net/"
+	dfltKontextQueryTemplate        = "https://www.korpus.cz/kontext/query?corpname={corpus}"
+	dfltPageSize                    = 100
+	dfltResumptionTokenTTLSecs      = 3600
+	dfltMaxAuthors                  = 500
+	dfltDBMaxOpenConns              = 20
+	dfltDBMaxIdleConns              = 20
+	dfltDBConnMaxLifetimeSecs       = 180
+	dfltCMDIVersion                 = "1.2"
+	maxPageSize                     = 1000
+	DeletedRecordNo                 = "no"
+	DeletedRecordTransient          = "transient"
+	DeletedRecordPersistent         = "persistent"
+	dfltDeletedRecord               = DeletedRecordNo
+	FutureDatestampsClamp           = "clamp"
+	FutureDatestampsExclude         = "exclude"
+	CitationFormatAPA               = "apa"
+	CitationFormatPlain             = "plain"
+	FCSVersion1                     = "1.0"
+	FCSVersion2                     = "2.0"
+	SelfTestOff                     = "off"
+	SelfTestWarn                    = "warn"
+	SelfTestFatal                   = "fatal"
+	dfltRobotsTxt                   = "User-agent: *\nDisallow: /oai\nDisallow: /record/\n"
 )
 
+var cmdiVersionRegexp = regexp.MustCompile(`^1\.\d+$`)
+
+// identifierNamespaceRegexp matches repository identifiers
+// as defined by the oai-identifier specification
+var identifierNamespaceRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9\-]*(\.[a-zA-Z][a-zA-Z0-9\-]*)+$`)
+
 // Conf is a global configuration of the app
 type Conf struct {
-	ListenAddress          string              `json:"listenAddress"`
-	ListenPort             int                 `json:"listenPort"`
-	ServerReadTimeoutSecs  int                 `json:"serverReadTimeoutSecs"`
-	ServerWriteTimeoutSecs int                 `json:"serverWriteTimeoutSecs"`
-	Logging                logging.LoggingConf `json:"logging"`
-	TimeZone               string              `json:"timeZone"`
-	CNCDB                  cncdb.DatabaseSetup `json:"cncDb"`
-	RepositoryInfo         RepositoryInfo      `json:"repositoryInfo"`
+	ListenAddress               string              `json:"listenAddress"`
+	ListenPort                  int                 `json:"listenPort"`
+	ServerReadTimeoutSecs       int                 `json:"serverReadTimeoutSecs"`
+	ServerWriteTimeoutSecs      int                 `json:"serverWriteTimeoutSecs"`
+	ServerReadHeaderTimeoutSecs int                 `json:"serverReadHeaderTimeoutSecs"`
+	ServerIdleTimeoutSecs       int                 `json:"serverIdleTimeoutSecs"`
+	ServerMaxHeaderBytes        int                 `json:"serverMaxHeaderBytes"`
+	Logging                     logging.LoggingConf `json:"logging"`
+	TimeZone                    string              `json:"timeZone"`
+	CNCDB                       cncdb.DatabaseSetup `json:"cncDb"`
+	RepositoryInfo              RepositoryInfo      `json:"repositoryInfo"`
+	OAIPMH                      oaipmh.HandlerSetup `json:"oaipmh"`
+
+	// PageSize is a max. number of items in a single response
+	// of list verbs (ListRecords, ListIdentifiers)
+	PageSize int `json:"pageSize"`
+
+	// ResumptionTokenTTLSecs specifies how long a resumption token
+	// issued for an incomplete list remains valid
+	ResumptionTokenTTLSecs int `json:"resumptionTokenTtlSecs"`
+
+	// IdentifyCacheTTLSecs specifies how long the DB-derived values
+	// of the Identify response (and the freshness info) are cached
+	IdentifyCacheTTLSecs int `json:"identifyCacheTtlSecs"`
+
+	// SitemapCacheTTLSecs specifies how long the list of records
+	// served as /sitemap.xml is cached (default 3600)
+	SitemapCacheTTLSecs int `json:"sitemapCacheTtlSecs"`
+
+	// EarliestDatestampRefreshSecs enables refreshing of the earliest
+	// datestamp in background so Identify never queries the DB.
+	// If zero, the value is loaded on demand (see IdentifyCacheTTLSecs).
+	EarliestDatestampRefreshSecs int `json:"earliestDatestampRefreshSecs"`
+
+	// ClampFromDate enables replacing a `from` argument preceding
+	// the earliest datestamp with the earliest datestamp so ancient
+	// dates (e.g. 1970-01-01) do not make list queries scan everything
+	ClampFromDate bool `json:"clampFromDate"`
+
+	// StartupSelfTest enables serializing sample records in all
+	// the supported formats before the server starts. A failure
+	// is either logged (`warn`) or prevents the start (`fatal`).
+	// By default (`off`), no self-test is performed.
+	StartupSelfTest string `json:"startupSelfTest"`
+
+	// ExposeMetrics enables Prometheus metrics of harvest
+	// traffic served at /metrics
+	ExposeMetrics bool `json:"exposeMetrics"`
+
+	// AdminToken enables admin endpoints (/admin/...) requiring
+	// the `Authorization: Bearer <token>` header. If empty,
+	// the admin endpoints are disabled.
+	AdminToken string `json:"adminToken"`
+
+	// RobotsTxt is served as /robots.txt. By default, crawling
+	// of both OAI and record endpoints is disallowed.
+	RobotsTxt string `json:"robotsTxt"`
 
 	// values common to all metadata records
 	MetadataValues MetadataValues `json:"metadataValues"`
 
+	// options affecting conversion of DB data into metadata records
+	Conversion ConversionOptions `json:"conversion"`
+
 	srcPath string
 }
 
@@ -54,10 +152,267 @@ type RepositoryInfo struct {
 	Name       string   `json:"name"`
 	BaseURL    string   `json:"baseUrl"`
 	AdminEmail []string `json:"adminEmail"`
+
+	// DeletedRecord specifies level of support for deleted records
+	// as advertised by Identify (`no`, `transient` or `persistent`).
+	// Unless `no` (default) is set, records deleted after being
+	// published are reported with the `deleted` status. This requires
+	// the `deleted_date` column (see scripts/schema_update.sql).
+	DeletedRecord string `json:"deletedRecord"`
+
+	// LocalizedNames maps language codes (e.g. `cs`) to alternative
+	// repository names selected by client's Accept-Language header.
+	// The `Name` itself is considered to be in the default language.
+	LocalizedNames map[string]string `json:"localizedNames"`
+
+	// Friends contains base URLs of related OAI-PMH repositories
+	// advertised in the Identify response
+	Friends []string `json:"friends"`
+
+	// IdentifierNamespace is a repository identifier (a domain name,
+	// e.g. `korpus.cz`) used to create `oai:<namespace>:<ID>` record
+	// identifiers. If empty, bare record IDs are used.
+	IdentifierNamespace string `json:"identifierNamespace"`
+
+	// StrictIdentifiers makes OAI-PMH requests accept only the prefixed
+	// `oai:<namespace>:<ID>` identifiers. By default, legacy bare record
+	// IDs are accepted as well to allow harvesters a smooth transition.
+	// The option has no effect without IdentifierNamespace.
+	StrictIdentifiers bool `json:"strictIdentifiers"`
+}
+
+func isAbsoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.IsAbs() && u.Host != ""
+}
+
+// Validate checks the repository info required by harvesters
+// (repository name, absolute base URL and admin emails). All the
+// problems found are reported in the returned error.
+func (info RepositoryInfo) Validate() error {
+	var problems []string
+	if strings.TrimSpace(info.Name) == "" {
+		problems = append(problems, "name is missing")
+	}
+	if info.BaseURL == "" {
+		problems = append(problems, "baseUrl is missing")
+
+	} else if !isAbsoluteURL(info.BaseURL) {
+		problems = append(problems, fmt.Sprintf("baseUrl `%s` is not an absolute URL", info.BaseURL))
+	}
+	if len(info.AdminEmail) == 0 {
+		problems = append(problems, "adminEmail is missing")
+	}
+	for _, email := range info.AdminEmail {
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			problems = append(problems, fmt.Sprintf("adminEmail `%s` is not a valid email", email))
+		}
+	}
+	for _, friend := range info.Friends {
+		if !isAbsoluteURL(friend) {
+			problems = append(problems, fmt.Sprintf("friend `%s` is not an absolute URL", friend))
+		}
+	}
+	if info.IdentifierNamespace != "" && !identifierNamespaceRegexp.MatchString(info.IdentifierNamespace) {
+		problems = append(
+			problems,
+			fmt.Sprintf("identifierNamespace `%s` is not a valid domain name", info.IdentifierNamespace),
+		)
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, ", "))
+	}
+	return nil
 }
 
 type MetadataValues struct {
 	Publisher string `json:"publisher"`
+
+	// SourceEntityBase is a prefix used to create references to source
+	// records (dc:source) by appending source record IDs. By default,
+	// record URLs of this repository are used.
+	SourceEntityBase string `json:"sourceEntityBase"`
+
+	// PIDResolverURL is a prefix used to create resolvable URLs
+	// from records' PIDs (Handles). By default, the global Handle
+	// proxy is used.
+	PIDResolverURL string `json:"pidResolverUrl"`
+
+	// KontextQueryTemplate is a template of a KonText query page URL
+	// used as a corpus search page with the `{corpus}` placeholder
+	// for the corpus name. By default, the public KonText is used.
+	KontextQueryTemplate string `json:"kontextQueryTemplate"`
+
+	// DefaultDescription is used for records with no description
+	// (in the default language)
+	DefaultDescription string `json:"defaultDescription"`
+
+	// DefaultContact is used for records with no contact person name
+	DefaultContact ContactPerson `json:"defaultContact"`
+}
+
+type ContactPerson struct {
+	FirstName   string `json:"firstName"`
+	LastName    string `json:"lastName"`
+	Email       string `json:"email"`
+	Affiliation string `json:"affiliation"`
+}
+
+type ConversionOptions struct {
+	// IncludeParallelLanguages enables listing of languages of all the
+	// aligned corpora for parallel corpora (requires an extra DB query)
+	IncludeParallelLanguages bool `json:"includeParallelLanguages"`
+
+	// IncludeSetSpecs enables corplists as OAI-PMH sets, i.e. listing
+	// of corplists a record belongs to as `setSpec` values in record
+	// headers, ListSets and selective harvesting by corplists
+	IncludeSetSpecs bool `json:"includeSetSpecs"`
+
+	// IncludeRegistryAttrs enables listing of corpus tagsets (stored
+	// in the `vlo_corpus_tagset` table) and alignments as CMDI
+	// annotation and format info (requires extra DB queries)
+	IncludeRegistryAttrs bool `json:"includeRegistryAttrs"`
+
+	// AnnotationTagsets enables listing of corpus tagset names
+	// (e.g. `PDT`) in the CMDI annotation info so harvesters can
+	// facet on annotation schemes (requires IncludeRegistryAttrs)
+	AnnotationTagsets bool `json:"annotationTagsets"`
+
+	// IncludeDistributions enables listing of downloadable
+	// distributions (e.g. vertical files) as resource proxies
+	// and DC relations (requires an extra DB query)
+	IncludeDistributions bool `json:"includeDistributions"`
+
+	// IncludeRelations enables listing of typed relations to other
+	// resources (stored in the `vlo_metadata_relation` table) as
+	// CMDI relations info (requires an extra DB query)
+	IncludeRelations bool `json:"includeRelations"`
+
+	// IncludeFunding enables listing of funding organizations and
+	// grants (stored in the `vlo_metadata_funding` table) as CMDI
+	// funding info and DC contributors (requires an extra DB query)
+	IncludeFunding bool `json:"includeFunding"`
+
+	// KeywordSets enables selective harvesting of corpora tagged
+	// with a keyword using `keyword:<id>` OAI-PMH sets (the parent
+	// `keyword` set contains all the corpora tagged with any keyword)
+	KeywordSets bool `json:"keywordSets"`
+
+	// FutureDatestamps specifies how to handle records with datestamps
+	// in the future (`clamp` to the current time or `exclude` them
+	// from record lists)
+	FutureDatestamps string `json:"futureDatestamps"`
+
+	// ContactRedaction specifies records whose contact email must
+	// not be exposed publicly
+	ContactRedaction RedactionPolicy `json:"contactRedaction"`
+
+	// RestrictedAccess specifies corpora which cannot be queried
+	// without an access request. Such corpora link to an access page
+	// instead of the query interface.
+	RestrictedAccess AccessRestriction `json:"restrictedAccess"`
+
+	// ResourceProxyOrder lists CMDI resource proxy types (e.g.
+	// `LandingPage`, `Resource`, `SearchPage`) in the order they
+	// should be emitted. CLARIN considers the first proxy to be
+	// the primary resource.
+	ResourceProxyOrder []string `json:"resourceProxyOrder"`
+
+	// DCSchemaURL overrides location of the Dublin Core schema
+	// (e.g. for environments with locally mirrored schemas)
+	DCSchemaURL string `json:"dcSchemaUrl"`
+
+	// CMDIVersion is the CMDI envelope version (`CMDVersion`), only
+	// 1.x versions are supported (default `1.2`)
+	CMDIVersion string `json:"cmdiVersion"`
+
+	// CMDIEnvelopeSchemaURL overrides location of the CMDI envelope
+	// schema (by default, the public schema of the CMDIVersion is used)
+	CMDIEnvelopeSchemaURL string `json:"cmdiEnvelopeSchemaUrl"`
+
+	// CollapseWhitespace enables replacing internal whitespace
+	// sequences in free text fields (titles, descriptions, authors,
+	// keywords) with single spaces. Line breaks separating authors
+	// are kept. Leading and trailing whitespace is always removed.
+	CollapseWhitespace bool `json:"collapseWhitespace"`
+
+	// IncludeProvenance enables the `about` provenance container
+	// for records ingested from an upstream repository (requires
+	// the `origin_*` columns, see scripts/schema_update.sql)
+	IncludeProvenance bool `json:"includeProvenance"`
+
+	// CitationFormat enables a recommended citation (`apa` or `plain`)
+	// for records with a DOI. If empty, no citation is generated.
+	CitationFormat string `json:"citationFormat"`
+
+	// MaxAuthors limits the number of parsed authors of a record,
+	// longer author lists are truncated (default 500)
+	MaxAuthors int `json:"maxAuthors"`
+
+	// DefaultCorpusLanguage is a language code (e.g. `cs`) used for
+	// corpora with no locale in the DB. If empty, no language is
+	// reported for such corpora.
+	DefaultCorpusLanguage string `json:"defaultCorpusLanguage"`
+
+	// FCSEndpoint enables a CLARIN-FCS search service resource
+	// proxy for corpora
+	FCSEndpoint FCSEndpoint `json:"fcsEndpoint"`
+}
+
+// RedactionPolicy flags records either by their IDs or by their
+// licenses. For flagged records, the contact email is replaced
+// with the repository admin email.
+type RedactionPolicy struct {
+	RecordIDs []int    `json:"recordIds"`
+	Licenses  []string `json:"licenses"`
+}
+
+func (p RedactionPolicy) Applies(recordID int, license string) bool {
+	return slices.Contains(p.RecordIDs, recordID) || slices.Contains(p.Licenses, license)
+}
+
+// AccessRestriction flags restricted corpora either by their record IDs
+// or by their licenses. The `AccessPageURL` is a template of an access
+// request page URL with `{corpus}` (corpus name) and `{id}` (record ID)
+// placeholders.
+type AccessRestriction struct {
+	RecordIDs     []int    `json:"recordIds"`
+	Licenses      []string `json:"licenses"`
+	AccessPageURL string   `json:"accessPageUrl"`
+}
+
+func (r AccessRestriction) Applies(recordID int, license string) bool {
+	return slices.Contains(r.RecordIDs, recordID) || slices.Contains(r.Licenses, license)
+}
+
+// FCSEndpoint describes a CLARIN-FCS (SRU based) endpoint providing
+// search in the corpora. Empty `URL` disables the endpoint.
+type FCSEndpoint struct {
+	URL string `json:"url"`
+
+	// Version is the supported FCS version (`1.0` or `2.0`, default `2.0`)
+	Version string `json:"version"`
+}
+
+// SRUVersion returns version of the SRU protocol the FCS version
+// is based on
+func (e FCSEndpoint) SRUVersion() string {
+	if e.Version == FCSVersion1 {
+		return "1.2"
+	}
+	return "2.0"
+}
+
+// TracksDeletedRecords tells whether deleted records should be
+// reported in record lists
+func (conf *Conf) TracksDeletedRecords() bool {
+	return conf.RepositoryInfo.DeletedRecord != DeletedRecordNo
+}
+
+// DefaultLanguage returns the language in which the primary
+// (non-localized) values are configured
+func (conf *Conf) DefaultLanguage() string {
+	return dfltLanguage
 }
 
 func (conf *Conf) TimezoneLocation() *time.Location {
@@ -82,6 +437,54 @@ func (conf *Conf) GetSourcePath() string {
 	return filepath.Join(cwd, conf.srcPath)
 }
 
+// Environment variables overriding respective config values
+// (applied after the config file is loaded, i.e. they take
+// precedence over values from the file)
+const (
+	EnvDBHost        = "CNC_VLO_DB_HOST"
+	EnvDBUser        = "CNC_VLO_DB_USER"
+	EnvDBPasswd      = "CNC_VLO_DB_PASSWD"
+	EnvDBName        = "CNC_VLO_DB_NAME"
+	EnvListenAddress = "CNC_VLO_LISTEN_ADDRESS"
+	EnvListenPort    = "CNC_VLO_LISTEN_PORT"
+	EnvBaseURL       = "CNC_VLO_BASE_URL"
+	EnvExposeMetrics = "CNC_VLO_EXPOSE_METRICS"
+	EnvAdminToken    = "CNC_VLO_ADMIN_TOKEN"
+)
+
+// applyEnvOverrides replaces config values with values of the set
+// environment variables (see the `Env*` constants)
+func applyEnvOverrides(conf *Conf) error {
+	for env, target := range map[string]*string{
+		EnvDBHost:        &conf.CNCDB.Host,
+		EnvDBUser:        &conf.CNCDB.User,
+		EnvDBPasswd:      &conf.CNCDB.Passwd,
+		EnvDBName:        &conf.CNCDB.Name,
+		EnvListenAddress: &conf.ListenAddress,
+		EnvBaseURL:       &conf.RepositoryInfo.BaseURL,
+		EnvAdminToken:    &conf.AdminToken,
+	} {
+		if value, ok := os.LookupEnv(env); ok {
+			*target = value
+		}
+	}
+	if value, ok := os.LookupEnv(EnvListenPort); ok {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value `%s`: %w", EnvListenPort, value, err)
+		}
+		conf.ListenPort = port
+	}
+	if value, ok := os.LookupEnv(EnvExposeMetrics); ok {
+		expose, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value `%s`: %w", EnvExposeMetrics, value, err)
+		}
+		conf.ExposeMetrics = expose
+	}
+	return nil
+}
+
 func LoadConfig(path string) *Conf {
 	if path == "" {
 		log.Fatal().Msg("Cannot load config - path not specified")
@@ -96,10 +499,21 @@ func LoadConfig(path string) *Conf {
 	if err != nil {
 		log.Fatal().Err(err).Msg("Cannot load config")
 	}
+	if err := applyEnvOverrides(&conf); err != nil {
+		log.Fatal().Err(err).Msg("Cannot load config")
+	}
 	return &conf
 }
 
 func ValidateAndDefaults(conf *Conf) {
+	if err := conf.RepositoryInfo.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("invalid repositoryInfo")
+	}
+	if conf.ServerReadTimeoutSecs < 0 || conf.ServerWriteTimeoutSecs < 0 ||
+		conf.ServerReadHeaderTimeoutSecs < 0 || conf.ServerIdleTimeoutSecs < 0 ||
+		conf.ServerMaxHeaderBytes < 0 {
+		log.Fatal().Msg("server timeouts and max. header bytes must not be negative")
+	}
 	if conf.ServerWriteTimeoutSecs == 0 {
 		conf.ServerWriteTimeoutSecs = dfltServerWriteTimeoutSecs
 		log.Warn().Msgf(
@@ -107,6 +521,196 @@ func ValidateAndDefaults(conf *Conf) {
 			dfltServerWriteTimeoutSecs,
 		)
 	}
+	if conf.ServerReadHeaderTimeoutSecs == 0 {
+		conf.ServerReadHeaderTimeoutSecs = dfltServerReadHeaderTimeoutSecs
+		log.Warn().Msgf(
+			"serverReadHeaderTimeoutSecs not specified, using default: %d",
+			dfltServerReadHeaderTimeoutSecs,
+		)
+	}
+	if conf.ServerIdleTimeoutSecs == 0 {
+		conf.ServerIdleTimeoutSecs = dfltServerIdleTimeoutSecs
+		log.Warn().Msgf(
+			"serverIdleTimeoutSecs not specified, using default: %d",
+			dfltServerIdleTimeoutSecs,
+		)
+	}
+	if conf.ServerMaxHeaderBytes == 0 {
+		conf.ServerMaxHeaderBytes = dfltServerMaxHeaderBytes
+		log.Warn().Msgf(
+			"serverMaxHeaderBytes not specified, using default: %d",
+			dfltServerMaxHeaderBytes,
+		)
+	}
+
+	if conf.PageSize <= 0 {
+		conf.PageSize = dfltPageSize
+		log.Warn().Msgf("pageSize not specified, using default: %d", dfltPageSize)
+
+	} else if conf.PageSize > maxPageSize {
+		log.Warn().
+			Int("pageSize", conf.PageSize).
+			Msgf("pageSize exceeds the max. allowed value, clamping to %d", maxPageSize)
+		conf.PageSize = maxPageSize
+	}
+
+	if conf.CNCDB.MaxOpenConns <= 0 {
+		conf.CNCDB.MaxOpenConns = dfltDBMaxOpenConns
+	}
+	if conf.CNCDB.MaxIdleConns <= 0 {
+		conf.CNCDB.MaxIdleConns = min(dfltDBMaxIdleConns, conf.CNCDB.MaxOpenConns)
+
+	} else if conf.CNCDB.MaxIdleConns > conf.CNCDB.MaxOpenConns {
+		log.Warn().
+			Int("maxIdleConns", conf.CNCDB.MaxIdleConns).
+			Msgf("cncDb.maxIdleConns exceeds cncDb.maxOpenConns, clamping to %d", conf.CNCDB.MaxOpenConns)
+		conf.CNCDB.MaxIdleConns = conf.CNCDB.MaxOpenConns
+	}
+	if conf.CNCDB.ConnMaxLifetimeSecs <= 0 {
+		conf.CNCDB.ConnMaxLifetimeSecs = dfltDBConnMaxLifetimeSecs
+	}
+	switch conf.CNCDB.HostedRecords {
+	case "":
+		conf.CNCDB.HostedRecords = cncdb.HostedRecordsAll
+	case cncdb.HostedRecordsAll, cncdb.HostedRecordsHosted, cncdb.HostedRecordsExternal:
+	default:
+		log.Fatal().
+			Str("hostedRecords", conf.CNCDB.HostedRecords).
+			Msg("invalid cncDb.hostedRecords value, supported values are `all`, `hosted` and `external`")
+	}
+
+	if conf.OAIPMH.RecentMetadataPrefix == "" {
+		conf.OAIPMH.RecentMetadataPrefix = formats.DublinCoreMetadataPrefix
+	}
+
+	if conf.ResumptionTokenTTLSecs <= 0 {
+		conf.ResumptionTokenTTLSecs = dfltResumptionTokenTTLSecs
+	}
+
+	if conf.Conversion.MaxAuthors <= 0 {
+		conf.Conversion.MaxAuthors = dfltMaxAuthors
+	}
+
+	if conf.MetadataValues.SourceEntityBase == "" {
+		conf.MetadataValues.SourceEntityBase = strings.TrimRight(conf.RepositoryInfo.BaseURL, "/") + "/record/"
+	}
+
+	if conf.MetadataValues.PIDResolverURL == "" {
+		conf.MetadataValues.PIDResolverURL = dfltPIDResolverURL
+	}
+
+	if conf.MetadataValues.KontextQueryTemplate == "" {
+		conf.MetadataValues.KontextQueryTemplate = dfltKontextQueryTemplate
+
+	} else if !strings.Contains(conf.MetadataValues.KontextQueryTemplate, "{corpus}") {
+		log.Fatal().
+			Str("kontextQueryTemplate", conf.MetadataValues.KontextQueryTemplate).
+			Msg("metadataValues.kontextQueryTemplate must contain the `{corpus}` placeholder")
+	}
+
+	if conf.IdentifyCacheTTLSecs <= 0 {
+		conf.IdentifyCacheTTLSecs = dfltIdentifyCacheTTLSecs
+	}
+
+	if conf.SitemapCacheTTLSecs <= 0 {
+		conf.SitemapCacheTTLSecs = dfltSitemapCacheTTLSecs
+	}
+
+	if conf.RobotsTxt == "" {
+		conf.RobotsTxt = dfltRobotsTxt
+	}
+
+	switch conf.RepositoryInfo.DeletedRecord {
+	case "":
+		conf.RepositoryInfo.DeletedRecord = dfltDeletedRecord
+	case DeletedRecordNo, DeletedRecordTransient, DeletedRecordPersistent:
+	default:
+		log.Fatal().
+			Str("deletedRecord", conf.RepositoryInfo.DeletedRecord).
+			Msg("invalid deletedRecord value, supported values are `no`, `transient` and `persistent`")
+	}
+
+	switch conf.Conversion.FutureDatestamps {
+	case "":
+		conf.Conversion.FutureDatestamps = FutureDatestampsClamp
+	case FutureDatestampsClamp, FutureDatestampsExclude:
+	default:
+		log.Fatal().
+			Str("futureDatestamps", conf.Conversion.FutureDatestamps).
+			Msg("invalid futureDatestamps value, supported values are `clamp` and `exclude`")
+	}
+
+	switch conf.StartupSelfTest {
+	case "":
+		conf.StartupSelfTest = SelfTestOff
+	case SelfTestOff, SelfTestWarn, SelfTestFatal:
+	default:
+		log.Fatal().
+			Str("startupSelfTest", conf.StartupSelfTest).
+			Msg("invalid startupSelfTest value, supported values are `off`, `warn` and `fatal`")
+	}
+
+	for _, encoding := range conf.OAIPMH.Compression {
+		if !oaipmh.IsSupportedEncoding(strings.ToLower(strings.TrimSpace(encoding))) {
+			log.Fatal().
+				Str("encoding", encoding).
+				Msg("invalid oaipmh.compression value, supported values are `gzip` and `deflate`")
+		}
+	}
+
+	switch conf.Conversion.CitationFormat {
+	case "", CitationFormatAPA, CitationFormatPlain:
+	default:
+		log.Fatal().
+			Str("citationFormat", conf.Conversion.CitationFormat).
+			Msg("invalid citationFormat value, supported values are `apa` and `plain`")
+	}
+
+	if conf.Conversion.FCSEndpoint.URL != "" {
+		switch conf.Conversion.FCSEndpoint.Version {
+		case "":
+			conf.Conversion.FCSEndpoint.Version = FCSVersion2
+		case FCSVersion1, FCSVersion2:
+		default:
+			log.Fatal().
+				Str("version", conf.Conversion.FCSEndpoint.Version).
+				Msg("invalid fcsEndpoint.version value, supported values are `1.0` and `2.0`")
+		}
+		if _, err := url.Parse(conf.Conversion.FCSEndpoint.URL); err != nil {
+			log.Fatal().Err(err).Msg("invalid fcsEndpoint.url value")
+		}
+	}
+
+	if conf.Conversion.AnnotationTagsets && !conf.Conversion.IncludeRegistryAttrs {
+		log.Warn().Msg("annotationTagsets has no effect without includeRegistryAttrs")
+	}
+
+	restricted := conf.Conversion.RestrictedAccess
+	if (len(restricted.RecordIDs) > 0 || len(restricted.Licenses) > 0) && restricted.AccessPageURL == "" {
+		log.Fatal().Msg("restrictedAccess.accessPageUrl must be specified for restricted corpora")
+	}
+
+	if conf.Conversion.CMDIVersion == "" {
+		conf.Conversion.CMDIVersion = dfltCMDIVersion
+
+	} else if !cmdiVersionRegexp.MatchString(conf.Conversion.CMDIVersion) {
+		log.Fatal().
+			Str("cmdiVersion", conf.Conversion.CMDIVersion).
+			Msg("invalid cmdiVersion value, only 1.x versions are supported")
+	}
+
+	if conf.Conversion.DefaultCorpusLanguage != "" {
+		if _, err := language.Parse(conf.Conversion.DefaultCorpusLanguage); err != nil {
+			log.Fatal().
+				Err(err).
+				Str("defaultCorpusLanguage", conf.Conversion.DefaultCorpusLanguage).
+				Msg("invalid defaultCorpusLanguage value")
+		}
+	}
+
+	if _, err := conf.CNCDB.Location(); err != nil {
+		log.Fatal().Err(err).Str("timeZone", conf.CNCDB.TimeZone).Msg("invalid cncDb.timeZone")
+	}
 
 	if conf.TimeZone == "" {
 		log.Warn().
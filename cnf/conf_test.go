@@ -0,0 +1,222 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/czcorpus/cnc-vlo/cncdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRepositoryInfo() RepositoryInfo {
+	return RepositoryInfo{
+		Name:       "CNC metadata repository",
+		BaseURL:    "https://vlo.korpus.cz/oai",
+		AdminEmail: []string{"admin@korpus.cz"},
+	}
+}
+
+func TestPageSizeDefault(t *testing.T) {
+	conf := &Conf{TimeZone: "UTC", RepositoryInfo: testRepositoryInfo()}
+	ValidateAndDefaults(conf)
+	assert.Equal(t, dfltPageSize, conf.PageSize)
+}
+
+func TestPageSizeWithinLimit(t *testing.T) {
+	conf := &Conf{TimeZone: "UTC", RepositoryInfo: testRepositoryInfo(), PageSize: 500}
+	ValidateAndDefaults(conf)
+	assert.Equal(t, 500, conf.PageSize)
+}
+
+func TestPageSizeAboveCap(t *testing.T) {
+	conf := &Conf{TimeZone: "UTC", RepositoryInfo: testRepositoryInfo(), PageSize: 1000000}
+	ValidateAndDefaults(conf)
+	assert.Equal(t, maxPageSize, conf.PageSize)
+}
+
+func TestKontextQueryTemplateDefault(t *testing.T) {
+	conf := &Conf{TimeZone: "UTC", RepositoryInfo: testRepositoryInfo()}
+	ValidateAndDefaults(conf)
+	assert.Equal(t, "https://www.korpus.cz/kontext/query?corpname={corpus}", conf.MetadataValues.KontextQueryTemplate)
+}
+
+func TestHostedRecordsDefault(t *testing.T) {
+	conf := &Conf{TimeZone: "UTC", RepositoryInfo: testRepositoryInfo()}
+	ValidateAndDefaults(conf)
+	assert.Equal(t, cncdb.HostedRecordsAll, conf.CNCDB.HostedRecords)
+}
+
+func TestDeletedRecordDefault(t *testing.T) {
+	conf := &Conf{TimeZone: "UTC", RepositoryInfo: testRepositoryInfo()}
+	ValidateAndDefaults(conf)
+	assert.Equal(t, DeletedRecordNo, conf.RepositoryInfo.DeletedRecord)
+	assert.False(t, conf.TracksDeletedRecords())
+}
+
+func TestDeletedRecordPersistent(t *testing.T) {
+	conf := &Conf{TimeZone: "UTC", RepositoryInfo: testRepositoryInfo()}
+	conf.RepositoryInfo.DeletedRecord = DeletedRecordPersistent
+	ValidateAndDefaults(conf)
+	assert.True(t, conf.TracksDeletedRecords())
+}
+
+func TestFCSEndpointVersionDefault(t *testing.T) {
+	conf := &Conf{TimeZone: "UTC", RepositoryInfo: testRepositoryInfo()}
+	conf.Conversion.FCSEndpoint.URL = "https://www.korpus.cz/fcs/sru"
+	ValidateAndDefaults(conf)
+	assert.Equal(t, FCSVersion2, conf.Conversion.FCSEndpoint.Version)
+	assert.Equal(t, "2.0", conf.Conversion.FCSEndpoint.SRUVersion())
+}
+
+func TestCMDIVersionDefault(t *testing.T) {
+	conf := &Conf{TimeZone: "UTC", RepositoryInfo: testRepositoryInfo()}
+	ValidateAndDefaults(conf)
+	assert.Equal(t, "1.2", conf.Conversion.CMDIVersion)
+}
+
+func TestStartupSelfTestDefault(t *testing.T) {
+	conf := &Conf{TimeZone: "UTC", RepositoryInfo: testRepositoryInfo()}
+	ValidateAndDefaults(conf)
+	assert.Equal(t, SelfTestOff, conf.StartupSelfTest)
+}
+
+func TestDBPoolDefaults(t *testing.T) {
+	conf := &Conf{TimeZone: "UTC", RepositoryInfo: testRepositoryInfo()}
+	ValidateAndDefaults(conf)
+	assert.Equal(t, dfltDBMaxOpenConns, conf.CNCDB.MaxOpenConns)
+	assert.Equal(t, dfltDBMaxIdleConns, conf.CNCDB.MaxIdleConns)
+	assert.Equal(t, dfltDBConnMaxLifetimeSecs, conf.CNCDB.ConnMaxLifetimeSecs)
+}
+
+func TestDBPoolIdleAboveOpen(t *testing.T) {
+	conf := &Conf{TimeZone: "UTC", RepositoryInfo: testRepositoryInfo()}
+	conf.CNCDB.MaxOpenConns = 5
+	conf.CNCDB.MaxIdleConns = 10
+	ValidateAndDefaults(conf)
+	assert.Equal(t, 5, conf.CNCDB.MaxIdleConns)
+}
+
+func TestRepositoryInfoValid(t *testing.T) {
+	assert.NoError(t, testRepositoryInfo().Validate())
+}
+
+func TestRepositoryInfoMissingName(t *testing.T) {
+	info := testRepositoryInfo()
+	info.Name = " "
+	assert.EqualError(t, info.Validate(), "name is missing")
+}
+
+func TestRepositoryInfoMissingBaseURL(t *testing.T) {
+	info := testRepositoryInfo()
+	info.BaseURL = ""
+	assert.EqualError(t, info.Validate(), "baseUrl is missing")
+}
+
+func TestRepositoryInfoRelativeBaseURL(t *testing.T) {
+	info := testRepositoryInfo()
+	info.BaseURL = "/oai"
+	assert.EqualError(t, info.Validate(), "baseUrl `/oai` is not an absolute URL")
+}
+
+func TestRepositoryInfoMissingAdminEmail(t *testing.T) {
+	info := testRepositoryInfo()
+	info.AdminEmail = nil
+	assert.EqualError(t, info.Validate(), "adminEmail is missing")
+}
+
+func TestRepositoryInfoInvalidAdminEmail(t *testing.T) {
+	info := testRepositoryInfo()
+	info.AdminEmail = []string{"admin@korpus.cz", "Admin <admin@korpus.cz>", "admin"}
+	assert.EqualError(
+		t, info.Validate(),
+		"adminEmail `Admin <admin@korpus.cz>` is not a valid email, adminEmail `admin` is not a valid email",
+	)
+}
+
+func TestRepositoryInfoInvalidFriend(t *testing.T) {
+	info := testRepositoryInfo()
+	info.Friends = []string{"https://lindat.mff.cuni.cz/repository/oai/request", "clarin.ids-mannheim.de/oai"}
+	assert.EqualError(t, info.Validate(), "friend `clarin.ids-mannheim.de/oai` is not an absolute URL")
+}
+
+func TestRepositoryInfoAllMissing(t *testing.T) {
+	assert.EqualError(t, RepositoryInfo{}.Validate(), "name is missing, baseUrl is missing, adminEmail is missing")
+}
+
+func writeTestConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "conf.json")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestEnvOverridesPrecedence(t *testing.T) {
+	path := writeTestConfig(t, `{
+		"listenPort": 8080,
+		"repositoryInfo": {"baseUrl": "http://localhost:8080"},
+		"cncDb": {"host": "localhost", "user": "vlo", "passwd": "file-secret"}
+	}`)
+	t.Setenv(EnvDBPasswd, "env-secret")
+	t.Setenv(EnvDBHost, "db.example.com:3306")
+	t.Setenv(EnvListenPort, "9090")
+	t.Setenv(EnvBaseURL, "https://vlo.korpus.cz/oai")
+	t.Setenv(EnvExposeMetrics, "true")
+	t.Setenv(EnvAdminToken, "admin-secret")
+	conf := LoadConfig(path)
+	assert.Equal(t, "env-secret", conf.CNCDB.Passwd)
+	assert.Equal(t, "db.example.com:3306", conf.CNCDB.Host)
+	assert.Equal(t, 9090, conf.ListenPort)
+	assert.Equal(t, "https://vlo.korpus.cz/oai", conf.RepositoryInfo.BaseURL)
+	assert.True(t, conf.ExposeMetrics)
+	assert.Equal(t, "admin-secret", conf.AdminToken)
+	// values without overrides are kept
+	assert.Equal(t, "vlo", conf.CNCDB.User)
+}
+
+func TestEnvOverridesUnset(t *testing.T) {
+	path := writeTestConfig(t, `{"listenPort": 8080, "cncDb": {"passwd": "file-secret"}}`)
+	conf := LoadConfig(path)
+	assert.Equal(t, "file-secret", conf.CNCDB.Passwd)
+	assert.Equal(t, 8080, conf.ListenPort)
+}
+
+func TestEnvOverridesEmptyValue(t *testing.T) {
+	conf := &Conf{CNCDB: cncdb.DatabaseSetup{Passwd: "file-secret"}}
+	t.Setenv(EnvDBPasswd, "")
+	assert.NoError(t, applyEnvOverrides(conf))
+	assert.Equal(t, "", conf.CNCDB.Passwd)
+}
+
+func TestEnvOverridesInvalidPort(t *testing.T) {
+	t.Setenv(EnvListenPort, "http")
+	assert.ErrorContains(t, applyEnvOverrides(&Conf{}), EnvListenPort)
+}
+
+func TestEnvOverridesInvalidBool(t *testing.T) {
+	t.Setenv(EnvExposeMetrics, "sure")
+	assert.ErrorContains(t, applyEnvOverrides(&Conf{}), EnvExposeMetrics)
+}
+
+func TestRepositoryInfoIdentifierNamespace(t *testing.T) {
+	info := testRepositoryInfo()
+	info.IdentifierNamespace = "korpus.cz"
+	assert.NoError(t, info.Validate())
+	info.IdentifierNamespace = "korpus"
+	assert.EqualError(t, info.Validate(), "identifierNamespace `korpus` is not a valid domain name")
+}
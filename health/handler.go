@@ -0,0 +1,130 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/czcorpus/cnc-gokit/uniresp"
+	"github.com/czcorpus/cnc-vlo/general"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+
+	// dfltReadinessTimeout limits the DB check so probes
+	// do not hang with an unresponsive database
+	dfltReadinessTimeout = 2 * time.Second
+)
+
+// Pinger is a dependency the service readiness depends on
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// LastUpdateSource provides time of the most recent update of
+// the repository content (a zero time if there is no content)
+type LastUpdateSource interface {
+	GetLastUpdate(ctx context.Context) (time.Time, error)
+}
+
+// Status is a response of the health endpoints
+type Status struct {
+	Status  string              `json:"status"`
+	DB      string              `json:"db,omitempty"`
+	Error   string              `json:"error,omitempty"`
+	Version general.VersionInfo `json:"version"`
+}
+
+// Freshness describes how recently the repository content changed
+type Freshness struct {
+	LastRecordUpdate    time.Time `json:"lastRecordUpdate"`
+	SecsSinceLastUpdate int       `json:"secsSinceLastUpdate"`
+}
+
+type Handler struct {
+	db      Pinger
+	content LastUpdateSource
+	version general.VersionInfo
+	timeout time.Duration
+}
+
+// HandleLiveness reports the process is running
+func (h *Handler) HandleLiveness(ctx *gin.Context) {
+	uniresp.WriteJSONResponse(ctx.Writer, Status{Status: StatusOK, Version: h.version})
+}
+
+// HandleReadiness reports whether the service is able to serve
+// requests, i.e. whether the database is reachable
+func (h *Handler) HandleReadiness(ctx *gin.Context) {
+	pingCtx, cancel := context.WithTimeout(ctx.Request.Context(), h.timeout)
+	defer cancel()
+	if err := h.db.Ping(pingCtx); err != nil {
+		log.Error().Err(err).Msg("readiness check failed")
+		uniresp.WriteJSONResponseWithStatus(
+			ctx.Writer,
+			http.StatusServiceUnavailable,
+			Status{
+				Status:  StatusUnavailable,
+				DB:      StatusUnavailable,
+				Error:   err.Error(),
+				Version: h.version,
+			},
+		)
+		return
+	}
+	uniresp.WriteJSONResponse(ctx.Writer, Status{Status: StatusOK, DB: StatusOK, Version: h.version})
+}
+
+// HandleFreshness reports time of the most recent content update
+// and the time elapsed since then. With no content available,
+// there is nothing to report and 404 is returned.
+func (h *Handler) HandleFreshness(ctx *gin.Context) {
+	lastUpdate, err := h.content.GetLastUpdate(ctx.Request.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get freshness info")
+		uniresp.WriteJSONErrorResponse(
+			ctx.Writer, uniresp.NewActionErrorFrom(err), http.StatusInternalServerError)
+		return
+	}
+	if lastUpdate.IsZero() {
+		uniresp.WriteJSONErrorResponse(
+			ctx.Writer, uniresp.NewActionError("no records available"), http.StatusNotFound)
+		return
+	}
+	uniresp.WriteJSONResponse(
+		ctx.Writer,
+		Freshness{
+			LastRecordUpdate:    lastUpdate.In(time.UTC),
+			SecsSinceLastUpdate: int(time.Since(lastUpdate).Seconds()),
+		},
+	)
+}
+
+func NewHandler(db Pinger, content LastUpdateSource, version general.VersionInfo) *Handler {
+	return &Handler{
+		db:      db,
+		content: content,
+		version: version,
+		timeout: dfltReadinessTimeout,
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/czcorpus/cnc-vlo/general"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubDB struct {
+	err        error
+	lastUpdate time.Time
+}
+
+func (db *stubDB) Ping(ctx context.Context) error {
+	return db.err
+}
+
+func (db *stubDB) GetLastUpdate(ctx context.Context) (time.Time, error) {
+	return db.lastUpdate, db.err
+}
+
+// hangingDB simulates a database which does not respond at all
+type hangingDB struct{}
+
+func (db *hangingDB) Ping(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func doHealthRequest(t *testing.T, handler *Handler, path string) (int, Status) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/healthz", handler.HandleLiveness)
+	engine.GET("/readyz", handler.HandleReadiness)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	engine.ServeHTTP(w, req)
+	var ans Status
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &ans))
+	return w.Code, ans
+}
+
+func TestReadinessDBReachable(t *testing.T) {
+	version := general.VersionInfo{Version: "1.2.3"}
+	code, status := doHealthRequest(t, NewHandler(&stubDB{}, &stubDB{}, version), "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, status.Status)
+	assert.Equal(t, StatusOK, status.DB)
+	assert.Equal(t, "1.2.3", status.Version.Version)
+}
+
+func TestReadinessDBUnreachable(t *testing.T) {
+	version := general.VersionInfo{Version: "1.2.3"}
+	db := &stubDB{err: errors.New("connection refused")}
+	handler := NewHandler(db, db, version)
+	code, status := doHealthRequest(t, handler, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusUnavailable, status.Status)
+	assert.Equal(t, StatusUnavailable, status.DB)
+	assert.Contains(t, status.Error, "connection refused")
+	assert.Equal(t, "1.2.3", status.Version.Version)
+
+	code, status = doHealthRequest(t, handler, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, status.Status)
+}
+
+func TestReadinessTimeout(t *testing.T) {
+	handler := NewHandler(&hangingDB{}, &stubDB{}, general.VersionInfo{})
+	handler.timeout = 50 * time.Millisecond
+	start := time.Now()
+	code, status := doHealthRequest(t, handler, "/readyz")
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusUnavailable, status.DB)
+}
+
+func doFreshnessRequest(handler *Handler) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/health/freshness", handler.HandleFreshness)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/health/freshness", nil)
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestFreshness(t *testing.T) {
+	lastUpdate := time.Now().Add(-time.Hour)
+	w := doFreshnessRequest(NewHandler(&stubDB{}, &stubDB{lastUpdate: lastUpdate}, general.VersionInfo{}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var ans Freshness
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &ans))
+	assert.True(t, lastUpdate.Equal(ans.LastRecordUpdate))
+	assert.InDelta(t, 3600, ans.SecsSinceLastUpdate, 5)
+}
+
+func TestFreshnessNoRecords(t *testing.T) {
+	w := doFreshnessRequest(NewHandler(&stubDB{}, &stubDB{}, general.VersionInfo{}))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NotContains(t, w.Body.String(), "secsSinceLastUpdate")
+}
+
+func TestFreshnessDBError(t *testing.T) {
+	w := doFreshnessRequest(NewHandler(&stubDB{}, &stubDB{err: errors.New("connection refused")}, general.VersionInfo{}))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -19,6 +19,7 @@ package oaipmh
 import (
 	"fmt"
 	"net/url"
+	"strings"
 )
 
 const (
@@ -40,8 +41,25 @@ const (
 
 // ----
 
+var allVerbs = []Verb{
+	VerbIdentify, VerbGetRecord, VerbListIdentifiers,
+	VerbListMetadataFormats, VerbListRecords, VerbListSets,
+}
+
 type Verb string
 
+// Normalize returns a properly cased verb in case it matches
+// one of the known verbs case-insensitively. Otherwise, the
+// original value is returned.
+func (v Verb) Normalize() Verb {
+	for _, verb := range allVerbs {
+		if strings.EqualFold(string(v), string(verb)) {
+			return verb
+		}
+	}
+	return v
+}
+
 func (v Verb) Validate() error {
 	if v == VerbGetRecord || v == VerbIdentify ||
 		v == VerbListIdentifiers || v == VerbListMetadataFormats ||
@@ -68,8 +86,27 @@ func (v Verb) ValidateArg(arg string) bool {
 	}
 }
 
+// ValidateExclusiveArgs returns an argument supplied along with
+// the `resumptionToken` (which must be the only argument besides
+// the verb). If there is no such argument, an empty string is returned.
+func ValidateExclusiveArgs(args url.Values) string {
+	if !args.Has(ArgResumptionToken) {
+		return ""
+	}
+	for _, arg := range []string{ArgMetadataPrefix, ArgFrom, ArgUntil, ArgSet, ArgIdentifier} {
+		if args.Has(arg) {
+			return arg
+		}
+	}
+	return ""
+}
+
 func (v Verb) ValidateRequiredArgs(args url.Values) string {
 	reqArgs := []string{ArgVerb}
+	if args.Has(ArgResumptionToken) {
+		// resumption token contains all the original arguments
+		return ""
+	}
 	switch v {
 	case VerbGetRecord:
 		reqArgs = append(reqArgs, ArgIdentifier, ArgMetadataPrefix)
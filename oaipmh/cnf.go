@@ -0,0 +1,53 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+type HandlerSetup struct {
+	// StylesheetURL, if set, is referenced via the `xml-stylesheet`
+	// processing instruction so browsers can render responses
+	StylesheetURL string `json:"stylesheetUrl"`
+
+	// LenientRequests enables case-insensitive verb matching and
+	// accepting the OAI endpoint path with a trailing slash. Verbs
+	// are case-sensitive according to the specification so the default
+	// behavior is strict.
+	LenientRequests bool `json:"lenientRequests"`
+
+	// Priority configures user-agent based prioritization of harvesters
+	Priority PrioritySetup `json:"priority"`
+
+	// Compression lists enabled response encodings (`gzip`, `deflate`)
+	// in the order of preference. The same list is advertised
+	// in the Identify response.
+	Compression []string `json:"compression"`
+
+	// RecentMetadataPrefix is a metadata format used by the convenience
+	// endpoint listing recently modified records (unless specified
+	// by the `metadataPrefix` argument)
+	RecentMetadataPrefix string `json:"recentMetadataPrefix"`
+}
+
+// PrioritySetup configures concurrency pools for requests. Requests
+// from known harvesters (user agent containing any of `UserAgents`,
+// case-insensitive) are served by a dedicated pool of `PrioritySlots`
+// while other requests share `SharedSlots` and are rejected once
+// the pool is exhausted. Zero `SharedSlots` disables the feature.
+type PrioritySetup struct {
+	UserAgents    []string `json:"userAgents"`
+	PrioritySlots int      `json:"prioritySlots"`
+	SharedSlots   int      `json:"sharedSlots"`
+}
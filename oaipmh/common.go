@@ -17,9 +17,13 @@
 package oaipmh
 
 import (
+	"bytes"
 	"encoding/xml"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
@@ -28,18 +32,92 @@ func getTypedArg[T ~string](args url.Values, name string) T {
 	return T(args.Get(name))
 }
 
-func writeXMLResponse(w http.ResponseWriter, code int, value any) {
+// Supported datestamp granularities. The finest one is advertised
+// by Identify, finer datestamps (e.g. with fractions of seconds)
+// are rejected.
+const (
+	GranularityDay     = "YYYY-MM-DD"
+	GranularitySeconds = "YYYY-MM-DDThh:mm:ssZ"
+
+	secondsLayout = "2006-01-02T15:04:05Z"
+)
+
+// parseDatestamp parses OAI-PMH datestamp in both supported granularities
+// (YYYY-MM-DD and YYYY-MM-DDThh:mm:ssZ) and returns the detected one.
+// Both `from` and `until` are inclusive so in case of a day granularity
+// `until` is moved to the last second of the day (records are stored
+// with a second precision).
+func parseDatestamp(value string, isUntil bool) (time.Time, string, error) {
+	if strings.Contains(value, "T") {
+		// time.Parse accepts fractional seconds even if not in the layout
+		parsed, err := time.Parse(secondsLayout, value)
+		if err != nil || len(value) != len(secondsLayout) {
+			return time.Time{}, "", fmt.Errorf(
+				"datestamp `%s` does not match granularity %s", value, GranularitySeconds)
+		}
+		return parsed, GranularitySeconds, nil
+	}
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf(
+			"datestamp `%s` does not match granularity %s", value, GranularityDay)
+	}
+	if isUntil {
+		parsed = parsed.Add(24*time.Hour - time.Second)
+	}
+	return parsed, GranularityDay, nil
+}
+
+// parseDateRange parses the `from` and `until` arguments. Both
+// arguments must have the same granularity and `from` must not be
+// later than `until`. Returned errors are meant to be reported
+// to clients (as `badArgument`).
+func parseDateRange(args url.Values) (*time.Time, *time.Time, error) {
+	var from, until *time.Time
+	var fromGranularity string
+	if value := getTypedArg[string](args, ArgFrom); value != "" {
+		parsed, granularity, err := parseDatestamp(value, false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid `%s`: %w", ArgFrom, err)
+		}
+		from, fromGranularity = &parsed, granularity
+	}
+	if value := getTypedArg[string](args, ArgUntil); value != "" {
+		parsed, granularity, err := parseDatestamp(value, true)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid `%s`: %w", ArgUntil, err)
+		}
+		if from != nil && granularity != fromGranularity {
+			return nil, nil, fmt.Errorf(
+				"`%s` and `%s` must have the same granularity", ArgFrom, ArgUntil)
+		}
+		until = &parsed
+	}
+	if from != nil && until != nil && from.After(*until) {
+		return nil, nil, fmt.Errorf("`%s` must not be later than `%s`", ArgFrom, ArgUntil)
+	}
+	return from, until, nil
+}
+
+func writeXMLResponse(w http.ResponseWriter, code int, value any, stylesheetURL string) {
 	xmlAns, err := xml.Marshal(value)
 	if err != nil {
 		log.Err(err).Msg("failed to encode a result to XML")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	var buff bytes.Buffer
+	buff.WriteString(xml.Header)
+	if stylesheetURL != "" {
+		buff.WriteString(`<?xml-stylesheet type="text/xsl" href="`)
+		xml.EscapeText(&buff, []byte(stylesheetURL))
+		buff.WriteString("\"?>\n")
+	}
+	buff.Write(xmlAns)
+	w.Header().Set("Content-Type", "text/xml")
 	w.WriteHeader(code)
-	_, err = w.Write([]byte(xml.Header + string(xmlAns)))
+	_, err = w.Write(buff.Bytes())
 	if err != nil {
 		log.Err(err).Msg("failed to write XML to response")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
-	w.Header().Set("Content-Type", "text/xml")
 }
@@ -0,0 +1,137 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDatestampUntilDayIncludesLastSecond(t *testing.T) {
+	until, granularity, err := parseDatestamp("2024-03-10", true)
+	assert.NoError(t, err)
+	assert.Equal(t, GranularityDay, granularity)
+	boundary := time.Date(2024, 3, 10, 23, 59, 59, 0, time.UTC)
+	nextDay := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	// ListRecordInfo filters with `<= until`
+	assert.False(t, boundary.After(until))
+	assert.True(t, nextDay.After(until))
+}
+
+func TestParseDatestampFromDay(t *testing.T) {
+	from, _, err := parseDatestamp("2024-03-10", false)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), from)
+}
+
+func TestParseDatestampUntilSeconds(t *testing.T) {
+	until, granularity, err := parseDatestamp("2024-03-10T12:30:00Z", true)
+	assert.NoError(t, err)
+	assert.Equal(t, GranularitySeconds, granularity)
+	assert.Equal(t, time.Date(2024, 3, 10, 12, 30, 0, 0, time.UTC), until)
+}
+
+func TestParseDatestampInvalid(t *testing.T) {
+	for _, value := range []string{
+		"10.3.2024",
+		"2024-03-10T12:30:00.5Z",
+		"2024-03-10T12:30:00+02:00",
+		"2024-03-10T12:30Z",
+	} {
+		_, _, err := parseDatestamp(value, false)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	from, until, err := parseDateRange(url.Values{"from": {"2024-03-10"}, "until": {"2024-03-10"}})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), *from)
+	assert.Equal(t, time.Date(2024, 3, 10, 23, 59, 59, 0, time.UTC), *until)
+
+	from, until, err = parseDateRange(url.Values{"until": {"2024-03-10T12:30:00Z"}})
+	assert.NoError(t, err)
+	assert.Nil(t, from)
+	assert.Equal(t, time.Date(2024, 3, 10, 12, 30, 0, 0, time.UTC), *until)
+}
+
+func TestParseDateRangeRejected(t *testing.T) {
+	testCases := []struct {
+		name  string
+		args  url.Values
+		error string
+	}{
+		{
+			name:  "mixed granularity",
+			args:  url.Values{"from": {"2024-03-10T12:30:00Z"}, "until": {"2024-03-11"}},
+			error: "`from` and `until` must have the same granularity",
+		},
+		{
+			name:  "mixed granularity reversed",
+			args:  url.Values{"from": {"2024-03-10"}, "until": {"2024-03-11T12:30:00Z"}},
+			error: "`from` and `until` must have the same granularity",
+		},
+		{
+			name:  "from later than until",
+			args:  url.Values{"from": {"2024-03-11"}, "until": {"2024-03-10"}},
+			error: "`from` must not be later than `until`",
+		},
+		{
+			name:  "from later than until seconds",
+			args:  url.Values{"from": {"2024-03-10T12:30:01Z"}, "until": {"2024-03-10T12:30:00Z"}},
+			error: "`from` must not be later than `until`",
+		},
+		{
+			name:  "finer granularity",
+			args:  url.Values{"from": {"2024-03-10T12:30:00.123Z"}},
+			error: "invalid `from`: datestamp `2024-03-10T12:30:00.123Z` does not match granularity YYYY-MM-DDThh:mm:ssZ",
+		},
+		{
+			name:  "invalid until",
+			args:  url.Values{"until": {"2024-13-01"}},
+			error: "invalid `until`: datestamp `2024-13-01` does not match granularity YYYY-MM-DD",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := parseDateRange(tc.args)
+			assert.EqualError(t, err, tc.error)
+		})
+	}
+}
+
+func TestWriteXMLResponseStylesheet(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeXMLResponse(w, http.StatusOK, OAIPMHSet{SetSpec: "test"}, "https://example.com/oai2.xsl")
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, `<?xml version="1.0" encoding="UTF-8"?>`))
+	assert.Contains(t, body, `<?xml-stylesheet type="text/xsl" href="https://example.com/oai2.xsl"?>`)
+	assert.Less(t, strings.Index(body, "<?xml-stylesheet"), strings.Index(body, "<OAIPMHSet>"))
+}
+
+func TestWriteXMLResponseNoStylesheet(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeXMLResponse(w, http.StatusOK, OAIPMHSet{SetSpec: "test"}, "")
+	assert.NotContains(t, w.Body.String(), "xml-stylesheet")
+	assert.Equal(t, "text/xml", w.Header().Get("Content-Type"))
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/czcorpus/cnc-gokit/collections"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// IsSupportedEncoding tells whether responses can be compressed
+// using the encoding
+func IsSupportedEncoding(encoding string) bool {
+	return encoding == EncodingGzip || encoding == EncodingDeflate
+}
+
+// EnabledEncodings returns configured response encodings in the order
+// of preference. It is the only source of encodings for both the
+// compression middleware and the Identify response so the advertised
+// compression always matches the actual server behavior.
+func (setup HandlerSetup) EnabledEncodings() []string {
+	ans := []string{}
+	for _, encoding := range setup.Compression {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if IsSupportedEncoding(encoding) && !collections.SliceContains(ans, encoding) {
+			ans = append(ans, encoding)
+		}
+	}
+	return ans
+}
+
+// acceptsEncoding tests whether the `Accept-Encoding` header allows
+// the encoding (encodings with `q=0` are refused)
+func acceptsEncoding(header, encoding string) bool {
+	var wildcard bool
+	for _, item := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(item, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		accepted := true
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				accepted = false
+			}
+		}
+		switch name {
+		case encoding:
+			return accepted
+		case "*":
+			wildcard = accepted
+		}
+	}
+	return wildcard
+}
+
+type compressedWriter struct {
+	gin.ResponseWriter
+	writer io.WriteCloser
+}
+
+func (w *compressedWriter) Write(data []byte) (int, error) {
+	return w.writer.Write(data)
+}
+
+func (w *compressedWriter) WriteString(s string) (int, error) {
+	return w.writer.Write([]byte(s))
+}
+
+// CompressionMiddleware compresses responses using the first enabled
+// encoding accepted by the client. With any encoding enabled, responses
+// vary by `Accept-Encoding` even if they are not compressed so shared
+// caches do not mix up compressed and uncompressed variants.
+func CompressionMiddleware(setup HandlerSetup) gin.HandlerFunc {
+	encodings := setup.EnabledEncodings()
+	return func(ctx *gin.Context) {
+		if len(encodings) > 0 {
+			ctx.Header("Vary", "Accept-Encoding")
+		}
+		if ctx.Request.Method == http.MethodHead {
+			ctx.Next()
+			return
+		}
+		header := ctx.GetHeader("Accept-Encoding")
+		for _, encoding := range encodings {
+			if !acceptsEncoding(header, encoding) {
+				continue
+			}
+			var writer io.WriteCloser
+			switch encoding {
+			case EncodingGzip:
+				writer = gzip.NewWriter(ctx.Writer)
+			case EncodingDeflate:
+				// `deflate` is the zlib format (RFC 9110), not raw DEFLATE
+				writer = zlib.NewWriter(ctx.Writer)
+			}
+			ctx.Header("Content-Encoding", encoding)
+			ctx.Writer = &compressedWriter{ResponseWriter: ctx.Writer, writer: writer}
+			defer func() {
+				if err := writer.Close(); err != nil {
+					log.Error().Err(err).Msg("failed to finish compressed response")
+				}
+			}()
+			break
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnabledEncodings(t *testing.T) {
+	setup := HandlerSetup{Compression: []string{"GZIP", " deflate", "gzip", "br"}}
+	assert.Equal(t, []string{"gzip", "deflate"}, setup.EnabledEncodings())
+	assert.Empty(t, HandlerSetup{}.EnabledEncodings())
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	assert.True(t, acceptsEncoding("gzip, deflate, br", "deflate"))
+	assert.True(t, acceptsEncoding("br;q=1.0, gzip;q=0.8", "gzip"))
+	assert.False(t, acceptsEncoding("gzip;q=0, deflate", "gzip"))
+	assert.True(t, acceptsEncoding("*", "gzip"))
+	assert.False(t, acceptsEncoding("*, gzip;q=0", "gzip"))
+	assert.False(t, acceptsEncoding("", "gzip"))
+}
+
+func doCompressedRequest(setup HandlerSetup, acceptEncoding string) *httptest.ResponseRecorder {
+	return doCompressedQuery(&emptyHook{}, setup, acceptEncoding, "verb=Identify")
+}
+
+func doCompressedQuery(hook VLOHook, setup HandlerSetup, acceptEncoding, query string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler := NewVLOHandler("http://localhost", setup, hook)
+	engine.GET("/oai", CompressionMiddleware(setup), handler.HandleOAIGet)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/oai?"+query, nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompressionGzip(t *testing.T) {
+	w := doCompressedRequest(HandlerSetup{Compression: []string{"gzip", "deflate"}}, "deflate, gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "<Identify>")
+}
+
+func TestCompressionDeflate(t *testing.T) {
+	w := doCompressedRequest(HandlerSetup{Compression: []string{"gzip", "deflate"}}, "deflate")
+	assert.Equal(t, "deflate", w.Header().Get("Content-Encoding"))
+	reader, err := zlib.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "<Identify>")
+}
+
+func TestCompressionListRecordsRoundTrip(t *testing.T) {
+	setup := HandlerSetup{Compression: []string{"gzip"}}
+	query := "verb=ListRecords&metadataPrefix=oai_dc"
+	plain := doCompressedQuery(newMemoryHook(), setup, "", query)
+	compressed := doCompressedQuery(newMemoryHook(), setup, "gzip", query)
+	assert.Equal(t, "gzip", compressed.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(compressed.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	// responses differ only in responseDate
+	dateRegexp := regexp.MustCompile("<responseDate>[^<]+</responseDate>")
+	assert.Equal(
+		t,
+		dateRegexp.ReplaceAllString(plain.Body.String(), ""),
+		dateRegexp.ReplaceAllString(string(body), ""),
+	)
+	assert.Contains(t, string(body), "<fixture><title>Record 2</title></fixture>")
+}
+
+func TestCompressionNotAccepted(t *testing.T) {
+	w := doCompressedRequest(HandlerSetup{Compression: []string{"gzip"}}, "deflate")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Contains(t, w.Body.String(), "<Identify>")
+}
+
+func TestCompressionDisabled(t *testing.T) {
+	w := doCompressedRequest(HandlerSetup{}, "gzip, deflate")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Header().Get("Vary"))
+	assert.Contains(t, w.Body.String(), "<Identify>")
+}
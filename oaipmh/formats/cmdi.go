@@ -18,6 +18,7 @@ package formats
 
 import (
 	"encoding/xml"
+	"fmt"
 	"strings"
 	"time"
 
@@ -28,6 +29,9 @@ const (
 	CMDIMetadataPrefix = "cmdi"
 	CMDINamespace      = "http://www.clarin.eu/cmd/1"
 	CMDIEnvelopeSchema = "http://www.clarin.eu/cmd/1/xsd/cmd-envelop.xsd"
+	CMDIDefaultVersion = "1.2"
+
+	cmdiVersionedEnvelopeSchema = "https://infra.clarin.eu/CMDI/%s/xsd/cmd-envelop.xsd"
 )
 
 // note - omitempties are optional
@@ -42,7 +46,7 @@ type CMDIFormat struct {
 
 	Header     CMDIHeader    `xml:"cmd:Header"`
 	Resources  CMDIResources `xml:"cmd:Resources"`
-	IsPartOf   *[]string     `xml:"cmd:IsPartOfList>IsPartOf,omitempty"`
+	IsPartOf   *[]string     `xml:"cmd:IsPartOfList>cmd:IsPartOf,omitempty"`
 	Components any           `xml:"cmd:Components"`
 }
 
@@ -90,6 +94,9 @@ const (
 	RTSearchPage ResourceType = "SearchPage"
 )
 
+// MimeTypeSRU identifies SRU based (e.g. CLARIN-FCS) search services
+const MimeTypeSRU = "application/sru+xml"
+
 type CMDIResourceType struct {
 	MimeType string       `xml:"mimetype,attr,omitempty"`
 	Value    ResourceType `xml:",chardata"`
@@ -113,32 +120,63 @@ type CMDIResource struct {
 // -------------------------------------------------------
 
 type CMDIProfile interface {
+	// GetProfileID returns profile ID in the `clarin.eu:cr1:p_...` form
+	// as required by the `MdProfile` header element
+	GetProfileID() string
 	GetSchemaURL() string
 	GetSchemaLocation() []string
 }
 
-func NewCMDI(profile CMDIProfile) CMDIFormat {
+// CMDIEnvelope specifies version of the CMDI envelope (1.x versions
+// share the namespace). The `SchemaURL` may override the envelope
+// schema location; if empty, the public schema of the version is used.
+// Zero value stands for the default version.
+type CMDIEnvelope struct {
+	Version   string
+	SchemaURL string
+}
+
+// GetVersion returns the CMDVersion attribute value
+func (e CMDIEnvelope) GetVersion() string {
+	if e.Version == "" {
+		return CMDIDefaultVersion
+	}
+	return e.Version
+}
+
+// GetSchemaURL returns location of the envelope schema matching the version
+func (e CMDIEnvelope) GetSchemaURL() string {
+	if e.SchemaURL != "" {
+		return e.SchemaURL
+	}
+	if version := e.GetVersion(); version != CMDIDefaultVersion {
+		return fmt.Sprintf(cmdiVersionedEnvelopeSchema, version)
+	}
+	return CMDIEnvelopeSchema
+}
+
+func NewCMDI(profile CMDIProfile, envelope CMDIEnvelope) CMDIFormat {
 	return CMDIFormat{
 		XMLNSXSI:  "http://www.w3.org/2001/XMLSchema-instance",
 		XMLNSCMD:  CMDINamespace,
 		XMLNSCMDP: profile.GetSchemaURL(),
 		XSISchemaLocation: strings.Join(
 			append(
-				[]string{CMDINamespace, CMDIEnvelopeSchema},
+				[]string{CMDINamespace, envelope.GetSchemaURL()},
 				profile.GetSchemaLocation()...,
 			),
 			" ",
 		),
-		Version:    "1.2",
-		Header:     CMDIHeader{MdProfile: profile.GetSchemaURL()},
+		Version:    envelope.GetVersion(),
+		Header:     CMDIHeader{MdProfile: profile.GetProfileID()},
 		Components: profile,
 	}
 }
 
-func GetCMDIFormat() oaipmh.OAIPMHMetadataFormat {
+func GetCMDIFormat(envelope CMDIEnvelope) oaipmh.OAIPMHMetadataFormat {
 	return oaipmh.OAIPMHMetadataFormat{
 		MetadataPrefix:    CMDIMetadataPrefix,
-		Schema:            CMDIEnvelopeSchema,
+		Schema:            envelope.GetSchemaURL(),
 		MetadataNamespace: CMDINamespace,
 	}
 }
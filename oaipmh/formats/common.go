@@ -16,6 +16,8 @@
 
 package formats
 
+import "strings"
+
 // note - omitempties are optional
 
 type MultilangElement struct {
@@ -25,7 +27,13 @@ type MultilangElement struct {
 
 type MultilangArray []MultilangElement
 
+// Add appends a value in the language (if known). Empty values
+// (incl. whitespace-only ones) are skipped so no empty elements
+// are produced.
 func (d *MultilangArray) Add(value string, lang string) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
 	*d = append(*d, MultilangElement{Value: value, Lang: lang})
 }
 
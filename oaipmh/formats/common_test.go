@@ -0,0 +1,50 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultilangArrayAddSkipsEmpty(t *testing.T) {
+	var values MultilangArray
+	values.Add("", "en")
+	values.Add(" \n", "cs")
+	assert.Empty(t, values)
+}
+
+func TestMultilangArrayMarshal(t *testing.T) {
+	doc := struct {
+		XMLName xml.Name       `xml:"doc"`
+		Title   MultilangArray `xml:"title"`
+		Subject MultilangArray `xml:"subject"`
+	}{}
+	doc.Title.Add("Corpus", "en")
+	doc.Title.Add("Korpus", "cs")
+	doc.Title.Add("SYN2020", "")
+	doc.Subject.Add("", "en")
+	out, err := xml.Marshal(doc)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`<doc><title xml:lang="en">Corpus</title><title xml:lang="cs">Korpus</title><title>SYN2020</title></doc>`,
+		string(out),
+	)
+}
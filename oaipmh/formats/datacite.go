@@ -0,0 +1,121 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+)
+
+const (
+	DataCiteMetadataPrefix = "oai_datacite"
+	DataCiteOAINamespace   = "http://schema.datacite.org/oai/oai-1.1/"
+	DataCiteOAISchema      = "http://schema.datacite.org/oai/oai-1.1/oai.xsd"
+	DataCiteNamespace      = "http://datacite.org/schema/kernel-4"
+	DataCiteSchema         = "http://schema.datacite.org/meta/kernel-4/metadata.xsd"
+	DataCiteSchemaVersion  = "4"
+
+	DataCiteNameTypePersonal       = "Personal"
+	DataCiteNameTypeOrganizational = "Organizational"
+	DataCiteDescriptionAbstract    = "Abstract"
+)
+
+// note - omitempties are optional
+
+// DataCite is the `oai_datacite` envelope of a DataCite
+// (kernel 4) resource description
+type DataCite struct {
+	XMLName           xml.Name `xml:"oai_datacite"`
+	XMLNS             string   `xml:"xmlns,attr"`
+	XMLNSXSI          string   `xml:"xmlns:xsi,attr"`
+	XSISchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+
+	SchemaVersion string           `xml:"schemaVersion"`
+	Payload       DataCiteResource `xml:"payload>resource"`
+}
+
+type DataCiteResource struct {
+	XMLNS             string `xml:"xmlns,attr"`
+	XSISchemaLocation string `xml:"xsi:schemaLocation,attr"`
+
+	Identifier      DataCiteIdentifier     `xml:"identifier"`
+	Creators        []DataCiteCreator      `xml:"creators>creator"`
+	Titles          MultilangArray         `xml:"titles>title"`
+	Publisher       string                 `xml:"publisher"`
+	PublicationYear string                 `xml:"publicationYear"`
+	ResourceType    DataCiteResourceType   `xml:"resourceType"`
+	Language        string                 `xml:"language,omitempty"`
+	RightsList      *[]DataCiteRights      `xml:"rightsList>rights,omitempty"`
+	Descriptions    *[]DataCiteDescription `xml:"descriptions>description,omitempty"`
+}
+
+// DataCiteIdentifier is a primary identifier of a resource. Please
+// note that the DataCite schema expects a DOI here.
+type DataCiteIdentifier struct {
+	Type  string `xml:"identifierType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type DataCiteCreator struct {
+	Name       DataCiteCreatorName `xml:"creatorName"`
+	GivenName  string              `xml:"givenName,omitempty"`
+	FamilyName string              `xml:"familyName,omitempty"`
+}
+
+type DataCiteCreatorName struct {
+	Type  string `xml:"nameType,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type DataCiteResourceType struct {
+	General string `xml:"resourceTypeGeneral,attr"` // controlled vocabulary (e.g. `Dataset`)
+	Value   string `xml:",chardata"`
+}
+
+type DataCiteRights struct {
+	URI   string `xml:"rightsURI,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type DataCiteDescription struct {
+	Lang  string `xml:"xml:lang,attr,omitempty"`
+	Type  string `xml:"descriptionType,attr"`
+	Value string `xml:",chardata"`
+}
+
+func NewDataCite() DataCite {
+	return DataCite{
+		XMLNS:             DataCiteOAINamespace,
+		XMLNSXSI:          "http://www.w3.org/2001/XMLSchema-instance",
+		XSISchemaLocation: strings.Join([]string{DataCiteOAINamespace, DataCiteOAISchema}, " "),
+		SchemaVersion:     DataCiteSchemaVersion,
+		Payload: DataCiteResource{
+			XMLNS:             DataCiteNamespace,
+			XSISchemaLocation: strings.Join([]string{DataCiteNamespace, DataCiteSchema}, " "),
+		},
+	}
+}
+
+func GetDataCiteFormat() oaipmh.OAIPMHMetadataFormat {
+	return oaipmh.OAIPMHMetadataFormat{
+		MetadataPrefix:    DataCiteMetadataPrefix,
+		Schema:            DataCiteOAISchema,
+		MetadataNamespace: DataCiteOAINamespace,
+	}
+}
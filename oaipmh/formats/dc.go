@@ -23,7 +23,11 @@ import (
 	"github.com/czcorpus/cnc-vlo/oaipmh"
 )
 
-const DublinCoreMetadataPrefix = "oai_dc"
+const (
+	DublinCoreMetadataPrefix = "oai_dc"
+	DublinCoreNamespace      = "http://www.openarchives.org/OAI/2.0/oai_dc/"
+	DublinCoreSchema         = "http://www.openarchives.org/OAI/2.0/oai_dc.xsd"
+)
 
 // note - omitempties are optional
 
@@ -51,22 +55,28 @@ type DublinCore struct {
 	Rights      MultilangArray `xml:"dc:rights"`
 }
 
-func NewDublinCore() DublinCore {
+// NewDublinCore creates a DC record. The `schemaURL` may override
+// the public schema location (e.g. with a local mirror); if empty,
+// the public one is used.
+func NewDublinCore(schemaURL string) DublinCore {
+	if schemaURL == "" {
+		schemaURL = DublinCoreSchema
+	}
 	return DublinCore{
-		XMLNSOAIDC: "http://www.openarchives.org/OAI/2.0/oai_dc/",
-		XMLNSDC:    "http://purl.org/dc/elements/1.1/",
-		XMLNSXSI:   "http://www.w3.org/2001/XMLSchema-instance",
-		XSISchemaLocation: strings.Join([]string{
-			"http://www.openarchives.org/OAI/2.0/oai_dc/",
-			"http://www.openarchives.org/OAI/2.0/oai_dc.xsd",
-		}, " "),
+		XMLNSOAIDC:        DublinCoreNamespace,
+		XMLNSDC:           "http://purl.org/dc/elements/1.1/",
+		XMLNSXSI:          "http://www.w3.org/2001/XMLSchema-instance",
+		XSISchemaLocation: strings.Join([]string{DublinCoreNamespace, schemaURL}, " "),
 	}
 }
 
-func GetDublinCoreFormat() oaipmh.OAIPMHMetadataFormat {
+func GetDublinCoreFormat(schemaURL string) oaipmh.OAIPMHMetadataFormat {
+	if schemaURL == "" {
+		schemaURL = DublinCoreSchema
+	}
 	return oaipmh.OAIPMHMetadataFormat{
 		MetadataPrefix:    DublinCoreMetadataPrefix,
-		Schema:            "http://www.openarchives.org/OAI/2.0/oai_dc.xsd",
-		MetadataNamespace: "http://www.openarchives.org/OAI/2.0/oai_dc/",
+		Schema:            schemaURL,
+		MetadataNamespace: DublinCoreNamespace,
 	}
 }
@@ -0,0 +1,129 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/czcorpus/cnc-vlo/oaipmh"
+)
+
+const (
+	OLACMetadataPrefix = "olac"
+	OLACNamespace      = "http://www.language-archives.org/OLAC/1.1/"
+	OLACSchema         = "http://www.language-archives.org/OLAC/1.1/olac.xsd"
+
+	// xsi:type values of the OLAC extensions and DC terms encoding schemes
+	OLACTypeLanguage       = "olac:language"
+	OLACTypeLinguisticType = "olac:linguistic-type"
+	OLACTypeRole           = "olac:role"
+	OLACTypeDCMIType       = "dcterms:DCMIType"
+	OLACTypeURI            = "dcterms:URI"
+	OLACTypeW3CDTF         = "dcterms:W3CDTF"
+
+	// olac:linguistic-type vocabulary (subset)
+	OLACLinguisticTypePrimaryText = "primary_text"
+
+	// olac:role vocabulary (subset)
+	OLACRoleAuthor  = "author"
+	OLACRoleSponsor = "sponsor"
+)
+
+// note - omitempties are optional
+
+// OLACElement is a DC element with optional OLAC extension
+// attributes. The `Type` (xsi:type) selects an extension or
+// an encoding scheme, the `Code` is a value from the extension's
+// controlled vocabulary.
+type OLACElement struct {
+	Type  string `xml:"xsi:type,attr,omitempty"`
+	Code  string `xml:"olac:code,attr,omitempty"`
+	Lang  string `xml:"xml:lang,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type OLACArray []OLACElement
+
+// Add appends a plain value in the language (if known).
+// Empty values are skipped.
+func (d *OLACArray) Add(value string, lang string) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	*d = append(*d, OLACElement{Value: value, Lang: lang})
+}
+
+// AddCoded adds an element with a type (extension or encoding scheme)
+// and an optional code from the extension's vocabulary
+func (d *OLACArray) AddCoded(value, xsiType, code string) {
+	*d = append(*d, OLACElement{Value: value, Type: xsiType, Code: code})
+}
+
+// OLAC is a record of the OLAC metadata format, an extension
+// of the Dublin Core with DC terms refinements and OLAC controlled
+// vocabularies
+type OLAC struct {
+	XMLName           xml.Name `xml:"olac:olac"`
+	XMLNSOLAC         string   `xml:"xmlns:olac,attr"`
+	XMLNSDC           string   `xml:"xmlns:dc,attr"`
+	XMLNSDCTerms      string   `xml:"xmlns:dcterms,attr"`
+	XMLNSXSI          string   `xml:"xmlns:xsi,attr"`
+	XSISchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+
+	Title       OLACArray `xml:"dc:title"`
+	Creator     OLACArray `xml:"dc:creator"`
+	Contributor OLACArray `xml:"dc:contributor"`
+	Subject     OLACArray `xml:"dc:subject"`
+	Description OLACArray `xml:"dc:description"`
+	Publisher   OLACArray `xml:"dc:publisher"`
+	Date        OLACArray `xml:"dc:date"`
+	Type        OLACArray `xml:"dc:type"`
+	Format      OLACArray `xml:"dc:format"`
+	Identifier  OLACArray `xml:"dc:identifier"`
+	Source      OLACArray `xml:"dc:source"`
+	Language    OLACArray `xml:"dc:language"`
+	Relation    OLACArray `xml:"dc:relation"`
+	Rights      OLACArray `xml:"dc:rights"`
+
+	// DC terms refinements
+	Available             OLACArray `xml:"dcterms:available"`
+	Issued                OLACArray `xml:"dcterms:issued"`
+	Modified              OLACArray `xml:"dcterms:modified"`
+	Spatial               OLACArray `xml:"dcterms:spatial"`
+	Temporal              OLACArray `xml:"dcterms:temporal"`
+	License               OLACArray `xml:"dcterms:license"`
+	BibliographicCitation OLACArray `xml:"dcterms:bibliographicCitation"`
+}
+
+func NewOLAC() OLAC {
+	return OLAC{
+		XMLNSOLAC:         OLACNamespace,
+		XMLNSDC:           "http://purl.org/dc/elements/1.1/",
+		XMLNSDCTerms:      "http://purl.org/dc/terms/",
+		XMLNSXSI:          "http://www.w3.org/2001/XMLSchema-instance",
+		XSISchemaLocation: strings.Join([]string{OLACNamespace, OLACSchema}, " "),
+	}
+}
+
+func GetOLACFormat() oaipmh.OAIPMHMetadataFormat {
+	return oaipmh.OAIPMHMetadataFormat{
+		MetadataPrefix:    OLACMetadataPrefix,
+		Schema:            OLACSchema,
+		MetadataNamespace: OLACNamespace,
+	}
+}
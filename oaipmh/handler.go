@@ -17,22 +17,35 @@
 package oaipmh
 
 import (
+	"context"
+	"encoding/xml"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/czcorpus/cnc-gokit/collections"
 	"github.com/czcorpus/cnc-gokit/logging"
+	"github.com/czcorpus/cnc-gokit/uniresp"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	dfltRecentDays = 7
+	maxRecentDays  = 3650
+)
+
 type ResultWrapper[T any] struct {
 	Data     T
 	Errors   OAIPMHErrors
 	HTTPCode int
+
+	// ResumptionToken is used only by list verbs
+	// in case of incomplete lists
+	ResumptionToken *OAIPMHResumptionToken
 }
 
 func (w *ResultWrapper[any]) NoError() bool {
@@ -47,12 +60,12 @@ func NewResultWrapper[T any](data T) ResultWrapper[T] {
 }
 
 type VLOHook interface {
-	Identify() ResultWrapper[OAIPMHIdentify]
-	GetRecord(req OAIPMHRequest) ResultWrapper[OAIPMHRecord]
-	ListIdentifiers(req OAIPMHRequest) ResultWrapper[[]OAIPMHRecordHeader]
-	ListMetadataFormats(req OAIPMHRequest) ResultWrapper[[]OAIPMHMetadataFormat]
-	ListRecords(req OAIPMHRequest) ResultWrapper[[]OAIPMHRecord]
-	ListSets(req OAIPMHRequest) ResultWrapper[[]OAIPMHSet]
+	Identify(ctx context.Context, req OAIPMHRequest) ResultWrapper[OAIPMHIdentify]
+	GetRecord(ctx context.Context, req OAIPMHRequest) ResultWrapper[OAIPMHRecord]
+	ListIdentifiers(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHRecordHeader]
+	ListMetadataFormats(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHMetadataFormat]
+	ListRecords(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHRecord]
+	ListSets(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHSet]
 
 	SupportsSets() bool
 	SupportedMetadataPrefixes() []string
@@ -60,7 +73,18 @@ type VLOHook interface {
 
 type VLOHandler struct {
 	basePath string
+	conf     HandlerSetup
 	hook     VLOHook
+	metrics  *Metrics
+}
+
+// SetMetrics enables collecting metrics of handled requests
+func (a *VLOHandler) SetMetrics(metrics *Metrics) {
+	a.metrics = metrics
+}
+
+func (a *VLOHandler) writeXMLResponse(ctx *gin.Context, code int, value any) {
+	writeXMLResponse(ctx.Writer, code, value, a.conf.StylesheetURL)
 }
 
 func (a *VLOHandler) getReqResp(argSource url.Values) (*OAIPMHRequest, *OAIPMHResponse, error) {
@@ -77,6 +101,9 @@ func (a *VLOHandler) getReqResp(argSource url.Values) (*OAIPMHRequest, *OAIPMHRe
 		return req, resp, nil
 	}
 	req.Verb = getTypedArg[Verb](argSource, ArgVerb)
+	if a.conf.LenientRequests {
+		req.Verb = req.Verb.Normalize()
+	}
 	if err := req.Verb.Validate(); err != nil {
 		resp.Errors.Add(ErrorCodeBadVerb, fmt.Sprintf("Invalid verb `%s`", req.Verb))
 		return req, resp, nil
@@ -94,47 +121,59 @@ func (a *VLOHandler) getReqResp(argSource url.Values) (*OAIPMHRequest, *OAIPMHRe
 			return req, resp, nil
 		}
 	}
+	if arg := ValidateExclusiveArgs(argSource); arg != "" {
+		resp.Errors.Add(
+			ErrorCodeBadArgument,
+			fmt.Sprintf("Argument `%s` cannot be combined with `%s`", arg, ArgResumptionToken),
+		)
+		return req, resp, nil
+	}
 
 	req.Identifier = getTypedArg[string](argSource, ArgIdentifier)
 	req.MetadataPrefix = getTypedArg[string](argSource, ArgMetadataPrefix)
-	if from := getTypedArg[string](argSource, ArgFrom); from != "" {
-		var parsed time.Time
-		if strings.Contains(from, "T") {
-			parsed, err = time.Parse(time.RFC3339, from)
-		} else {
-			parsed, err = time.Parse(time.DateOnly, from)
-		}
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to parse `from`: %w", err)
-		}
-		parsed = parsed.In(time.UTC)
-		req.From = &parsed
-	}
-	if until := getTypedArg[string](argSource, ArgUntil); until != "" {
-		var parsed time.Time
-		if strings.Contains(until, "T") {
-			parsed, err = time.Parse(time.RFC3339, until)
-		} else {
-			parsed, err = time.Parse(time.DateOnly, until)
-			parsed = parsed.Add(24 * time.Hour)
-		}
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to until `from`: %w", err)
-		}
-		parsed = parsed.In(time.UTC)
-		req.Until = &parsed
+	req.From, req.Until, err = parseDateRange(argSource)
+	if err != nil {
+		resp.Errors.Add(ErrorCodeBadArgument, fmt.Sprintf("Invalid date range: %s", err))
+		return req, resp, nil
 	}
 	req.Set = getTypedArg[string](argSource, ArgSet)
 	req.ResumptionToken = getTypedArg[string](argSource, ArgResumptionToken)
 	return req, resp, nil
 }
 
+func (a *VLOHandler) validateMetadataPrefix(req *OAIPMHRequest, resp *OAIPMHResponse) bool {
+	supported := a.hook.SupportedMetadataPrefixes()
+	if collections.SliceContains(supported, req.MetadataPrefix) {
+		return true
+	}
+	resp.Errors.Add(
+		ErrorCodeCannotDisseminateFormat,
+		fmt.Sprintf(
+			"Unknown metadata format `%s`, supported formats: %s",
+			req.MetadataPrefix, strings.Join(supported, ", "),
+		),
+	)
+	return false
+}
+
+// writeRequestErrors writes a response to a request rejected before
+// being passed to the hook (e.g. due to invalid arguments)
+func (a *VLOHandler) writeRequestErrors(ctx *gin.Context, req *OAIPMHRequest, resp *OAIPMHResponse, start time.Time) {
+	a.writeXMLResponse(ctx, http.StatusOK, resp)
+	a.metrics.observe(req.Verb, resp, http.StatusOK, time.Since(start))
+}
+
 func (a *VLOHandler) handleRequest(ctx *gin.Context, req *OAIPMHRequest, resp *OAIPMHResponse) {
+	defer a.metrics.requestStarted()()
+	start := time.Now()
+	defer func() {
+		a.metrics.observe(req.Verb, resp, ctx.Writer.Status(), time.Since(start))
+	}()
 	var errors OAIPMHErrors
 	httpCode := http.StatusOK
 	switch req.Verb {
 	case VerbIdentify:
-		ans := a.hook.Identify()
+		ans := a.hook.Identify(ctx.Request.Context(), *req)
 		errors, httpCode = ans.Errors, ans.HTTPCode
 		if ans.NoError() {
 			resp.Identify = &ans.Data
@@ -143,99 +182,148 @@ func (a *VLOHandler) handleRequest(ctx *gin.Context, req *OAIPMHRequest, resp *O
 		}
 
 	case VerbGetRecord:
-		if !collections.SliceContains(a.hook.SupportedMetadataPrefixes(), req.MetadataPrefix) {
-			resp.Errors.Add(ErrorCodeCannotDisseminateFormat, "Unknown metadata format")
-			writeXMLResponse(ctx.Writer, http.StatusBadRequest, resp)
+		if !a.validateMetadataPrefix(req, resp) {
+			a.writeXMLResponse(ctx, http.StatusOK, resp)
 			return
 		}
-		ans := a.hook.GetRecord(*req)
+		ans := a.hook.GetRecord(ctx.Request.Context(), *req)
 		errors, httpCode = ans.Errors, ans.HTTPCode
 		if ans.NoError() {
 			resp.GetRecord = &ans.Data
 		}
 
 	case VerbListIdentifiers:
-		if !collections.SliceContains(a.hook.SupportedMetadataPrefixes(), req.MetadataPrefix) {
-			resp.Errors.Add(ErrorCodeCannotDisseminateFormat, "Unknown metadata format")
-			writeXMLResponse(ctx.Writer, http.StatusBadRequest, resp)
+		// arguments of resumed lists are validated by the hook
+		if req.ResumptionToken == "" && !a.validateMetadataPrefix(req, resp) {
+			a.writeXMLResponse(ctx, http.StatusOK, resp)
 			return
 		}
 		if req.Set != "" && !a.hook.SupportsSets() {
 			resp.Errors.Add(ErrorCodeNoSetHierarchy, "Sets functionality not implemented")
-			writeXMLResponse(ctx.Writer, http.StatusNotImplemented, resp)
+			a.writeXMLResponse(ctx, http.StatusOK, resp)
 			return
 		}
-		ans := a.hook.ListIdentifiers(*req)
+		ans := a.hook.ListIdentifiers(ctx.Request.Context(), *req)
 		errors, httpCode = ans.Errors, ans.HTTPCode
 		if ans.NoError() {
 			resp.ListIdentifiers = &ans.Data
+			resp.ListIdentifiersRT = ans.ResumptionToken
 		}
 
 	case VerbListMetadataFormats:
-		ans := a.hook.ListMetadataFormats(*req)
+		ans := a.hook.ListMetadataFormats(ctx.Request.Context(), *req)
 		errors, httpCode = ans.Errors, ans.HTTPCode
 		if ans.NoError() {
 			resp.ListMetadataFormats = &ans.Data
 		}
 
 	case VerbListRecords:
-		if !collections.SliceContains(a.hook.SupportedMetadataPrefixes(), req.MetadataPrefix) {
-			resp.Errors.Add(ErrorCodeCannotDisseminateFormat, "Unknown metadata format")
-			writeXMLResponse(ctx.Writer, http.StatusBadRequest, resp)
+		if req.ResumptionToken == "" && !a.validateMetadataPrefix(req, resp) {
+			a.writeXMLResponse(ctx, http.StatusOK, resp)
 			return
 		}
 		if req.Set != "" && !a.hook.SupportsSets() {
 			resp.Errors.Add(ErrorCodeNoSetHierarchy, "Sets functionality not implemented")
-			writeXMLResponse(ctx.Writer, http.StatusNotImplemented, resp)
+			a.writeXMLResponse(ctx, http.StatusOK, resp)
 			return
 		}
-		ans := a.hook.ListRecords(*req)
+		ans := a.hook.ListRecords(ctx.Request.Context(), *req)
 		errors, httpCode = ans.Errors, ans.HTTPCode
 		if ans.NoError() {
 			resp.ListRecords = &ans.Data
+			resp.ListRecordsRT = ans.ResumptionToken
 		}
 
 	case VerbListSets:
 		if !a.hook.SupportsSets() {
 			resp.Errors.Add(ErrorCodeNoSetHierarchy, "Sets functionality not implemented")
-			writeXMLResponse(ctx.Writer, http.StatusNotImplemented, resp)
+			a.writeXMLResponse(ctx, http.StatusOK, resp)
 			return
 		}
-		ans := a.hook.ListSets(*req)
+		ans := a.hook.ListSets(ctx.Request.Context(), *req)
 		errors, httpCode = ans.Errors, ans.HTTPCode
 		if ans.NoError() {
 			resp.ListSets = &ans.Data
+			resp.ListSetsRT = ans.ResumptionToken
 		}
 
 	default:
-		resp.Errors.Add(ErrorCodeBadArgument, fmt.Sprintf("Verb not implemented `%s`", req.Verb))
-		httpCode = http.StatusNotImplemented
+		resp.Errors.Add(ErrorCodeBadVerb, fmt.Sprintf("Verb not implemented `%s`", req.Verb))
 	}
 
+	// protocol errors (as opposed to server failures) are reported
+	// by hooks within a regular response with HTTP 200
 	resp.Errors = append(resp.Errors, errors...)
-	if httpCode >= 400 && !resp.Errors.HasErrors() {
+	if !resp.Errors.HasErrors() && httpCode >= 400 {
 		ctx.AbortWithStatus(httpCode)
 		return
 	}
-	writeXMLResponse(ctx.Writer, httpCode, resp)
+	a.writeXMLResponse(ctx, httpCode, resp)
 }
 
 func (a *VLOHandler) HandleOAIGet(ctx *gin.Context) {
+	start := time.Now()
 	req, resp, err := a.getReqResp(ctx.Request.URL.Query())
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to handle OAIPMH Get request")
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
+	req.AcceptLanguage = ctx.GetHeader("Accept-Language")
 	logging.AddLogEvent(ctx, "operation", req.Verb)
 	if resp.Errors.HasErrors() {
-		writeXMLResponse(ctx.Writer, http.StatusBadRequest, resp)
+		a.writeRequestErrors(ctx, req, resp, start)
+		return
+	}
+	a.handleRequest(ctx, req, resp)
+}
+
+// recentFrom returns the start of a window of the last `days` days
+func recentFrom(now time.Time, days int) time.Time {
+	return now.UTC().AddDate(0, 0, -days).Truncate(time.Second)
+}
+
+// HandleRecent is a convenience (non-standard) endpoint listing records
+// modified within the last `days` days. It is translated into a regular
+// ListRecords request so the response (incl. resumption tokens to be
+// used with the OAI endpoint) is the same as for an explicit `from`.
+func (a *VLOHandler) HandleRecent(ctx *gin.Context) {
+	start := time.Now()
+	args := url.Values{ArgVerb: {string(VerbListRecords)}}
+	if prefix := ctx.DefaultQuery(ArgMetadataPrefix, a.conf.RecentMetadataPrefix); prefix != "" {
+		args.Set(ArgMetadataPrefix, prefix)
+	}
+	if set := ctx.Query(ArgSet); set != "" {
+		args.Set(ArgSet, set)
+	}
+	days, err := strconv.Atoi(ctx.DefaultQuery("days", strconv.Itoa(dfltRecentDays)))
+	validDays := err == nil && days > 0 && days <= maxRecentDays
+	if validDays {
+		args.Set(ArgFrom, recentFrom(time.Now(), days).Format(secondsLayout))
+	}
+	req, resp, err := a.getReqResp(args)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to handle recent records request")
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if !validDays {
+		resp.Errors.Add(
+			ErrorCodeBadArgument,
+			fmt.Sprintf("Invalid argument `days`, expected a number between 1 and %d", maxRecentDays),
+		)
+	}
+	req.AcceptLanguage = ctx.GetHeader("Accept-Language")
+	logging.AddLogEvent(ctx, "operation", req.Verb)
+	if resp.Errors.HasErrors() {
+		a.writeRequestErrors(ctx, req, resp, start)
 		return
 	}
 	a.handleRequest(ctx, req, resp)
 }
 
 func (a *VLOHandler) HandleOAIPost(ctx *gin.Context) {
+	start := time.Now()
 	if err := ctx.Request.ParseForm(); err != nil {
 		log.Error().Err(err).Msg("Failed to handle OAIPMH Post request")
 		ctx.AbortWithStatus(http.StatusInternalServerError)
@@ -247,14 +335,26 @@ func (a *VLOHandler) HandleOAIPost(ctx *gin.Context) {
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
+	req.AcceptLanguage = ctx.GetHeader("Accept-Language")
 	logging.AddLogEvent(ctx, "operation", req.Verb)
 	if resp.Errors.HasErrors() {
-		writeXMLResponse(ctx.Writer, http.StatusBadRequest, resp)
+		a.writeRequestErrors(ctx, req, resp, start)
 		return
 	}
 	a.handleRequest(ctx, req, resp)
 }
 
+// selfLinkErrorStatus maps OAI-PMH protocol errors of a record
+// self link request to a plain HTTP status
+func selfLinkErrorStatus(errors OAIPMHErrors) int {
+	for _, e := range errors {
+		if e.Code == ErrorCodeIDDoesNotExist {
+			return http.StatusNotFound
+		}
+	}
+	return http.StatusBadRequest
+}
+
 func (a *VLOHandler) HandleSelfLink(ctx *gin.Context) {
 	req := OAIPMHRequest{
 		URL:            ctx.Request.Host + ctx.Request.URL.Path,
@@ -262,17 +362,56 @@ func (a *VLOHandler) HandleSelfLink(ctx *gin.Context) {
 		MetadataPrefix: ctx.DefaultQuery("format", "oai_dc"),
 	}
 
-	ans := a.hook.GetRecord(req)
+	ans := a.hook.GetRecord(ctx.Request.Context(), req)
 	if ans.HTTPCode >= 400 {
 		ctx.AbortWithStatus(ans.HTTPCode)
+	} else if ans.Errors.HasErrors() {
+		ctx.AbortWithStatus(selfLinkErrorStatus(ans.Errors))
+	} else if ans.Data.Metadata == nil {
+		// deleted records consist of a header only
+		ctx.AbortWithStatus(http.StatusGone)
 	} else {
-		writeXMLResponse(ctx.Writer, ans.HTTPCode, ans.Data.Metadata.Value)
+		a.writeXMLResponse(ctx, ans.HTTPCode, ans.Data.Metadata.Value)
+	}
+}
+
+type noRouteHint struct {
+	XMLName xml.Name `xml:"notFound" json:"-"`
+	Error   string   `xml:"error" json:"error"`
+	Hint    string   `xml:"hint" json:"hint"`
+}
+
+// HandleNoRoute responds to unknown OAI-like paths (e.g. `/oai2`)
+// with a hint pointing to the correct endpoint. Other paths are
+// handled by the generic "not found" handler.
+func (a *VLOHandler) HandleNoRoute(ctx *gin.Context) {
+	if !strings.HasPrefix(strings.ToLower(ctx.Request.URL.Path), "/oai") {
+		uniresp.NotFoundHandler(ctx)
+		return
+	}
+	OAIURL, err := url.JoinPath(a.basePath, "oai")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to handle unknown route")
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	hint := noRouteHint{
+		Error: "action not found",
+		Hint:  fmt.Sprintf("the OAI-PMH endpoint is available at %s?verb=Identify", OAIURL),
+	}
+	switch ctx.NegotiateFormat(gin.MIMEJSON, gin.MIMEXML, gin.MIMEXML2) {
+	case gin.MIMEXML, gin.MIMEXML2:
+		a.writeXMLResponse(ctx, http.StatusNotFound, hint)
+	default:
+		ctx.Header("Content-Type", gin.MIMEJSON)
+		uniresp.WriteJSONResponseWithStatus(ctx.Writer, http.StatusNotFound, hint)
 	}
 }
 
-func NewVLOHandler(basePath string, hook VLOHook) *VLOHandler {
+func NewVLOHandler(basePath string, conf HandlerSetup, hook VLOHook) *VLOHandler {
 	return &VLOHandler{
 		basePath: basePath,
+		conf:     conf,
 		hook:     hook,
 	}
 }
@@ -0,0 +1,554 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type emptyHook struct{}
+
+func (h *emptyHook) Identify(ctx context.Context, req OAIPMHRequest) ResultWrapper[OAIPMHIdentify] {
+	return NewResultWrapper(OAIPMHIdentify{})
+}
+
+func (h *emptyHook) GetRecord(ctx context.Context, req OAIPMHRequest) ResultWrapper[OAIPMHRecord] {
+	return NewResultWrapper(OAIPMHRecord{})
+}
+
+func (h *emptyHook) ListIdentifiers(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHRecordHeader] {
+	return NewResultWrapper([]OAIPMHRecordHeader{})
+}
+
+func (h *emptyHook) ListMetadataFormats(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHMetadataFormat] {
+	return NewResultWrapper([]OAIPMHMetadataFormat{})
+}
+
+func (h *emptyHook) ListRecords(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHRecord] {
+	return NewResultWrapper([]OAIPMHRecord{})
+}
+
+func (h *emptyHook) ListSets(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHSet] {
+	return NewResultWrapper([]OAIPMHSet{})
+}
+
+func (h *emptyHook) SupportsSets() bool {
+	return false
+}
+
+func (h *emptyHook) SupportedMetadataPrefixes() []string {
+	return []string{"oai_dc", "cmdi"}
+}
+
+func doGetRequest(hook VLOHook, query string) *httptest.ResponseRecorder {
+	return doGetRequestWithSetup(hook, HandlerSetup{}, query)
+}
+
+func doGetRequestWithSetup(hook VLOHook, setup HandlerSetup, query string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler := NewVLOHandler("http://localhost", setup, hook)
+	engine.GET("/oai", handler.HandleOAIGet)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/oai?"+query, nil)
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestUnknownMetadataPrefixMessage(t *testing.T) {
+	for _, verb := range []string{"ListRecords", "ListIdentifiers"} {
+		w := doGetRequest(&emptyHook{}, "verb="+verb+"&metadataPrefix=marc21")
+		assert.Contains(t, w.Body.String(), `code="cannotDisseminateFormat"`)
+		assert.Contains(t, w.Body.String(), "Unknown metadata format `marc21`, supported formats: oai_dc, cmdi")
+	}
+	w := doGetRequest(&emptyHook{}, "verb=GetRecord&identifier=1&metadataPrefix=marc21")
+	assert.Contains(t, w.Body.String(), "Unknown metadata format `marc21`, supported formats: oai_dc, cmdi")
+}
+
+func TestLowercaseVerbStrict(t *testing.T) {
+	w := doGetRequest(&emptyHook{}, "verb=identify")
+	assert.Contains(t, w.Body.String(), `code="badVerb"`)
+	assert.NotContains(t, w.Body.String(), "<Identify>")
+}
+
+func TestLowercaseVerbLenient(t *testing.T) {
+	w := doGetRequestWithSetup(&emptyHook{}, HandlerSetup{LenientRequests: true}, "verb=identify")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "<error")
+	assert.Contains(t, w.Body.String(), `<request verb="Identify">`)
+	assert.Contains(t, w.Body.String(), "<Identify>")
+}
+
+func doNoRouteRequest(path, accept string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler := NewVLOHandler("http://localhost", HandlerSetup{}, &emptyHook{})
+	engine.GET("/oai", handler.HandleOAIGet)
+	engine.NoRoute(handler.HandleNoRoute)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestNoRouteOAIHintJSON(t *testing.T) {
+	w := doNoRouteRequest("/oai2?verb=Identify", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.Contains(t, w.Body.String(), "http://localhost/oai?verb=Identify")
+}
+
+func TestNoRouteOAIHintXML(t *testing.T) {
+	w := doNoRouteRequest("/oai-pmh", "application/xml")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/xml")
+	assert.Contains(t, w.Body.String(), "<hint>the OAI-PMH endpoint is available at http://localhost/oai?verb=Identify</hint>")
+}
+
+func TestNoRouteUnrelatedPath(t *testing.T) {
+	w := doNoRouteRequest("/favicon.ico", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NotContains(t, w.Body.String(), "verb=Identify")
+}
+
+func TestListSetsWithoutSetsStatus(t *testing.T) {
+	w := doGetRequest(&emptyHook{}, "verb=ListSets")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `code="noSetHierarchy"`)
+}
+
+func TestListWithSetWithoutSetsStatus(t *testing.T) {
+	for _, verb := range []string{"ListRecords", "ListIdentifiers"} {
+		w := doGetRequest(&emptyHook{}, "verb="+verb+"&metadataPrefix=oai_dc&set=public")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `code="noSetHierarchy"`)
+	}
+}
+
+func TestUnknownMetadataPrefixStatus(t *testing.T) {
+	for _, query := range []string{
+		"verb=ListRecords&metadataPrefix=marc21",
+		"verb=ListIdentifiers&metadataPrefix=marc21",
+		"verb=GetRecord&metadataPrefix=marc21&identifier=1",
+	} {
+		w := doGetRequest(&emptyHook{}, query)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `code="cannotDisseminateFormat"`)
+	}
+}
+
+type errorHook struct {
+	emptyHook
+}
+
+func (h *errorHook) GetRecord(ctx context.Context, req OAIPMHRequest) ResultWrapper[OAIPMHRecord] {
+	ans := NewResultWrapper(OAIPMHRecord{})
+	ans.Errors.Add(ErrorCodeIDDoesNotExist, "Result for ID = 1 not found")
+	return ans
+}
+
+func (h *errorHook) ListMetadataFormats(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHMetadataFormat] {
+	ans := NewResultWrapper([]OAIPMHMetadataFormat{})
+	ans.Errors.Add(ErrorCodeIDDoesNotExist, "Result for ID = 1 not found")
+	return ans
+}
+
+func (h *errorHook) ListRecords(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHRecord] {
+	ans := NewResultWrapper([]OAIPMHRecord{})
+	ans.Errors.Add(ErrorCodeNoRecordsMatch, "No records")
+	return ans
+}
+
+func (h *errorHook) ListIdentifiers(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHRecordHeader] {
+	ans := NewResultWrapper([]OAIPMHRecordHeader{})
+	ans.HTTPCode = http.StatusInternalServerError
+	return ans
+}
+
+func TestProtocolErrorsStatus(t *testing.T) {
+	for query, code := range map[string]string{
+		"":                                     "badArgument",
+		"verb=Foo":                             "badVerb",
+		"verb=GetRecord&identifier=1":          "badArgument",
+		"verb=Identify&identifier=1":           "badArgument",
+		"verb=ListRecords&metadataPrefix=marc": "cannotDisseminateFormat",
+		"verb=GetRecord&identifier=1&metadataPrefix=oai_dc": "idDoesNotExist",
+		"verb=ListRecords&metadataPrefix=oai_dc":            "noRecordsMatch",
+		"verb=ListMetadataFormats&identifier=1":             "idDoesNotExist",
+	} {
+		w := doGetRequest(&errorHook{}, query)
+		assert.Equal(t, http.StatusOK, w.Code, query)
+		assert.Contains(t, w.Body.String(), `code="`+code+`"`, query)
+	}
+}
+
+func TestServerFailureStatus(t *testing.T) {
+	w := doGetRequest(&errorHook{}, "verb=ListIdentifiers&metadataPrefix=oai_dc")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestResumptionTokenExclusive(t *testing.T) {
+	w := doGetRequest(&emptyHook{}, "verb=ListRecords&resumptionToken=abc&metadataPrefix=oai_dc")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `code="badArgument"`)
+}
+
+func TestResumptionTokenWithFrom(t *testing.T) {
+	w := doGetRequest(&emptyHook{}, "verb=ListRecords&resumptionToken=abc&from=2024-01-01")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `code="badArgument"`)
+	assert.Contains(t, w.Body.String(), "Argument `from` cannot be combined with `resumptionToken`")
+}
+
+func TestResumptionTokenWithoutMetadataPrefix(t *testing.T) {
+	for _, verb := range []string{"ListRecords", "ListIdentifiers"} {
+		w := doGetRequest(&emptyHook{}, "verb="+verb+"&resumptionToken=abc")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "<error")
+		assert.Contains(t, w.Body.String(), `resumptionToken="abc"`)
+	}
+}
+
+func TestInvalidDateRangeIsBadArgument(t *testing.T) {
+	for _, query := range []string{
+		"from=2024-03-10T12:30:00Z&until=2024-03-11",
+		"from=2024-03-11&until=2024-03-10",
+		"from=2024-03-10T12:30:00.5Z",
+		"until=yesterday",
+	} {
+		w := doGetRequest(&emptyHook{}, "verb=ListRecords&metadataPrefix=oai_dc&"+query)
+		assert.Equal(t, http.StatusOK, w.Code, query)
+		assert.Contains(t, w.Body.String(), `code="badArgument"`, query)
+		assert.Contains(t, w.Body.String(), "Invalid date range: ", query)
+	}
+}
+
+// parsedResponse captures parts of OAI-PMH responses
+// produced for memoryHook fixtures
+type parsedResponse struct {
+	Errors []struct {
+		Code string `xml:"code,attr"`
+	} `xml:"error"`
+	Request struct {
+		Verb           string `xml:"verb,attr"`
+		MetadataPrefix string `xml:"metadataPrefix,attr"`
+		URL            string `xml:",chardata"`
+	} `xml:"request"`
+	Identify struct {
+		RepositoryName  string `xml:"repositoryName"`
+		BaseURL         string `xml:"baseURL"`
+		ProtocolVersion string `xml:"protocolVersion"`
+		DeletedRecord   string `xml:"deletedRecord"`
+		Granularity     string `xml:"granularity"`
+	} `xml:"Identify"`
+	GetRecord parsedRecord `xml:"GetRecord>record"`
+	Formats   []string     `xml:"ListMetadataFormats>metadataFormat>metadataPrefix"`
+	Headers   []struct {
+		Identifier string   `xml:"identifier"`
+		SetSpec    []string `xml:"setSpec"`
+	} `xml:"ListIdentifiers>header"`
+	IdentifiersToken struct {
+		Value            string `xml:",chardata"`
+		Cursor           int    `xml:"cursor,attr"`
+		CompleteListSize int    `xml:"completeListSize,attr"`
+	} `xml:"ListIdentifiers>resumptionToken"`
+	Records      []parsedRecord `xml:"ListRecords>record"`
+	RecordsToken string         `xml:"ListRecords>resumptionToken"`
+	Sets         []string       `xml:"ListSets>set>setSpec"`
+}
+
+type parsedRecord struct {
+	Header struct {
+		Identifier string `xml:"identifier"`
+		Status     string `xml:"status,attr"`
+	} `xml:"header"`
+	Title string `xml:"metadata>fixture>title"`
+}
+
+func parseResponse(t *testing.T, w *httptest.ResponseRecorder) parsedResponse {
+	var ans parsedResponse
+	assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &ans))
+	return ans
+}
+
+func doPostRequest(hook VLOHook, form url.Values) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler := NewVLOHandler("http://localhost", HandlerSetup{}, hook)
+	engine.POST("/oai", handler.HandleOAIPost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/oai", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func doSelfLinkRequest(hook VLOHook, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler := NewVLOHandler("http://localhost", HandlerSetup{}, hook)
+	engine.GET("/record/:recordId", handler.HandleSelfLink)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestMemoryHookIdentify(t *testing.T) {
+	w := doGetRequest(newMemoryHook(), "verb=Identify")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/xml", w.Header().Get("Content-Type"))
+	ans := parseResponse(t, w)
+	assert.Empty(t, ans.Errors)
+	assert.Equal(t, "Identify", ans.Request.Verb)
+	assert.Equal(t, "http://localhost/oai", ans.Request.URL)
+	assert.Equal(t, "Fixture repository", ans.Identify.RepositoryName)
+	assert.Equal(t, "http://localhost/oai", ans.Identify.BaseURL)
+	assert.Equal(t, "2.0", ans.Identify.ProtocolVersion)
+	assert.Equal(t, "persistent", ans.Identify.DeletedRecord)
+	assert.Equal(t, GranularitySeconds, ans.Identify.Granularity)
+}
+
+func TestMemoryHookGetRecord(t *testing.T) {
+	ans := parseResponse(t, doGetRequest(newMemoryHook(), "verb=GetRecord&identifier=2&metadataPrefix=oai_dc"))
+	assert.Empty(t, ans.Errors)
+	assert.Equal(t, "2", ans.GetRecord.Header.Identifier)
+	assert.Equal(t, "Record 2", ans.GetRecord.Title)
+
+	ans = parseResponse(t, doGetRequest(newMemoryHook(), "verb=GetRecord&identifier=5&metadataPrefix=oai_dc"))
+	assert.Empty(t, ans.Errors)
+	assert.Equal(t, RecordStatusDeleted, ans.GetRecord.Header.Status)
+	assert.Empty(t, ans.GetRecord.Title)
+
+	w := doGetRequest(newMemoryHook(), "verb=GetRecord&identifier=42&metadataPrefix=oai_dc")
+	assert.Equal(t, http.StatusOK, w.Code)
+	ans = parseResponse(t, w)
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, "idDoesNotExist", ans.Errors[0].Code)
+}
+
+func TestMemoryHookListMetadataFormats(t *testing.T) {
+	w := doGetRequest(newMemoryHook(), "verb=ListMetadataFormats")
+	assert.Equal(t, http.StatusOK, w.Code)
+	ans := parseResponse(t, w)
+	assert.Empty(t, ans.Errors)
+	assert.Equal(t, []string{"oai_dc"}, ans.Formats)
+
+	w = doGetRequest(newMemoryHook(), "verb=ListMetadataFormats&identifier=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	ans = parseResponse(t, w)
+	assert.Empty(t, ans.Errors)
+	assert.Equal(t, []string{"oai_dc"}, ans.Formats)
+
+	w = doGetRequest(newMemoryHook(), "verb=ListMetadataFormats&identifier=42")
+	assert.Equal(t, http.StatusOK, w.Code)
+	ans = parseResponse(t, w)
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, "idDoesNotExist", ans.Errors[0].Code)
+}
+
+func TestMemoryHookListIdentifiersPaging(t *testing.T) {
+	hook := newMemoryHook()
+	identifiers := []string{}
+	query := "verb=ListIdentifiers&metadataPrefix=oai_dc"
+	for i := 0; i < 10; i++ {
+		ans := parseResponse(t, doGetRequest(hook, query))
+		assert.Empty(t, ans.Errors)
+		assert.Equal(t, len(identifiers), ans.IdentifiersToken.Cursor)
+		assert.Equal(t, 5, ans.IdentifiersToken.CompleteListSize)
+		for _, header := range ans.Headers {
+			identifiers = append(identifiers, header.Identifier)
+		}
+		if ans.IdentifiersToken.Value == "" {
+			break
+		}
+		query = "verb=ListIdentifiers&resumptionToken=" + url.QueryEscape(ans.IdentifiersToken.Value)
+	}
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, identifiers)
+}
+
+func TestMemoryHookListRecords(t *testing.T) {
+	ans := parseResponse(t, doGetRequest(newMemoryHook(), "verb=ListRecords&metadataPrefix=oai_dc&set=public"))
+	assert.Empty(t, ans.Errors)
+	assert.Len(t, ans.Records, 2)
+	assert.Equal(t, "Record 1", ans.Records[0].Title)
+	assert.Equal(t, "public:2", ans.RecordsToken)
+
+	ans = parseResponse(t, doGetRequest(newMemoryHook(), "verb=ListRecords&resumptionToken=public:2"))
+	assert.Empty(t, ans.Errors)
+	assert.Len(t, ans.Records, 1)
+	assert.Equal(t, "4", ans.Records[0].Header.Identifier)
+	assert.Empty(t, ans.RecordsToken)
+
+	ans = parseResponse(t, doGetRequest(newMemoryHook(), "verb=ListRecords&metadataPrefix=oai_dc&from=2024-01-05"))
+	assert.Empty(t, ans.Errors)
+	assert.Len(t, ans.Records, 1)
+	assert.Equal(t, RecordStatusDeleted, ans.Records[0].Header.Status)
+
+	for query, code := range map[string]string{
+		"verb=ListRecords&metadataPrefix=oai_dc&from=2025-01-01": "noRecordsMatch",
+		"verb=ListRecords&resumptionToken=foo":                   "badResumptionToken",
+		"verb=ListRecords&metadataPrefix=oai_dc&from=yesterday":  "badArgument",
+		"verb=ListRecords&metadataPrefix=marc21":                 "cannotDisseminateFormat",
+	} {
+		ans := parseResponse(t, doGetRequest(newMemoryHook(), query))
+		assert.Len(t, ans.Errors, 1, query)
+		assert.Equal(t, code, ans.Errors[0].Code, query)
+	}
+}
+
+func TestMemoryHookListSets(t *testing.T) {
+	ans := parseResponse(t, doGetRequest(newMemoryHook(), "verb=ListSets"))
+	assert.Empty(t, ans.Errors)
+	assert.Equal(t, []string{"public"}, ans.Sets)
+
+	ans = parseResponse(t, doGetRequest(newMemoryHook(), "verb=ListSets&resumptionToken=foo"))
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, "badResumptionToken", ans.Errors[0].Code)
+}
+
+func TestMemoryHookPost(t *testing.T) {
+	w := doPostRequest(
+		newMemoryHook(),
+		url.Values{"verb": {"GetRecord"}, "identifier": {"1"}, "metadataPrefix": {"oai_dc"}},
+	)
+	assert.Equal(t, http.StatusOK, w.Code)
+	ans := parseResponse(t, w)
+	assert.Empty(t, ans.Errors)
+	assert.Equal(t, "oai_dc", ans.Request.MetadataPrefix)
+	assert.Equal(t, "Record 1", ans.GetRecord.Title)
+
+	ans = parseResponse(t, doPostRequest(newMemoryHook(), url.Values{"verb": {"Identify"}, "set": {"public"}}))
+	assert.Len(t, ans.Errors, 1)
+	assert.Equal(t, "badArgument", ans.Errors[0].Code)
+}
+
+func TestMemoryHookSelfLink(t *testing.T) {
+	w := doSelfLinkRequest(newMemoryHook(), "/record/3")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<fixture><title>Record 3</title></fixture>")
+
+	w = doSelfLinkRequest(newMemoryHook(), "/record/42")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doSelfLinkRequest(newMemoryHook(), "/record/5")
+	assert.Equal(t, http.StatusGone, w.Code)
+}
+
+func TestSelfLinkErrorStatus(t *testing.T) {
+	var errors OAIPMHErrors
+	errors.Add(ErrorCodeCannotDisseminateFormat, "Unknown metadata format")
+	assert.Equal(t, http.StatusBadRequest, selfLinkErrorStatus(errors))
+	errors = OAIPMHErrors{}
+	errors.Add(ErrorCodeIDDoesNotExist, "Result for ID = 1 not found")
+	assert.Equal(t, http.StatusNotFound, selfLinkErrorStatus(errors))
+}
+
+// recordingHook remembers the last ListRecords request
+type recordingHook struct {
+	emptyHook
+	lastReq *OAIPMHRequest
+}
+
+func (h *recordingHook) ListRecords(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHRecord] {
+	h.lastReq = &req
+	return h.emptyHook.ListRecords(ctx, req)
+}
+
+func (h *recordingHook) SupportsSets() bool {
+	return true
+}
+
+func doRecentRequest(hook VLOHook, setup HandlerSetup, query string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler := NewVLOHandler("http://localhost", setup, hook)
+	engine.GET("/oai/", handler.HandleOAIGet)
+	engine.GET("/oai/recent", handler.HandleRecent)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/oai/recent?"+query, nil)
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestRecentFrom(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 30, 15, 500, time.FixedZone("CET", 3600))
+	tests := []struct {
+		days     int
+		expected time.Time
+	}{
+		{1, time.Date(2024, 3, 9, 11, 30, 15, 0, time.UTC)},
+		{7, time.Date(2024, 3, 3, 11, 30, 15, 0, time.UTC)},
+		// across the end of February in a leap year
+		{10, time.Date(2024, 2, 29, 11, 30, 15, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, recentFrom(now, tt.days), "days = %d", tt.days)
+	}
+}
+
+func TestHandleRecent(t *testing.T) {
+	hook := &recordingHook{}
+	before := time.Now().UTC().AddDate(0, 0, -3).Truncate(time.Second)
+	w := doRecentRequest(hook, HandlerSetup{RecentMetadataPrefix: "oai_dc"}, "days=3&set=public")
+	assert.Equal(t, http.StatusOK, w.Code)
+	ans := parseResponse(t, w)
+	assert.Equal(t, "ListRecords", ans.Request.Verb)
+	assert.Equal(t, "oai_dc", ans.Request.MetadataPrefix)
+	assert.Equal(t, "http://localhost/oai", ans.Request.URL)
+	if assert.NotNil(t, hook.lastReq) && assert.NotNil(t, hook.lastReq.From) {
+		assert.WithinDuration(t, before, *hook.lastReq.From, 2*time.Second)
+		assert.Nil(t, hook.lastReq.Until)
+		assert.Equal(t, "public", hook.lastReq.Set)
+	}
+}
+
+func TestHandleRecentDefaultDays(t *testing.T) {
+	hook := &recordingHook{}
+	w := doRecentRequest(hook, HandlerSetup{}, "metadataPrefix=oai_dc")
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.NotNil(t, hook.lastReq) && assert.NotNil(t, hook.lastReq.From) {
+		assert.WithinDuration(
+			t, time.Now().UTC().AddDate(0, 0, -dfltRecentDays), *hook.lastReq.From, 2*time.Second)
+	}
+}
+
+func TestHandleRecentInvalidDays(t *testing.T) {
+	for _, days := range []string{"0", "-1", "abc", "100000"} {
+		hook := &recordingHook{}
+		w := doRecentRequest(hook, HandlerSetup{RecentMetadataPrefix: "oai_dc"}, "days="+days)
+		assert.Equal(t, http.StatusOK, w.Code)
+		ans := parseResponse(t, w)
+		if assert.Len(t, ans.Errors, 1, "days = %s", days) {
+			assert.Equal(t, "badArgument", ans.Errors[0].Code)
+		}
+		assert.Nil(t, hook.lastReq)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fixtureMetadata is a minimal metadata format used by memoryHook
+type fixtureMetadata struct {
+	XMLName xml.Name `xml:"fixture"`
+	Title   string   `xml:"title"`
+}
+
+// memoryHook is an in-memory VLOHook serving fixture records
+// so the handler can be tested without a database
+type memoryHook struct {
+	records  []OAIPMHRecord
+	sets     []OAIPMHSet
+	pageSize int
+}
+
+func newFixtureRecord(id int, date time.Time, sets ...string) OAIPMHRecord {
+	record := NewOAIPMHRecord(fixtureMetadata{Title: fmt.Sprintf("Record %d", id)})
+	record.Header.Identifier = strconv.Itoa(id)
+	record.Header.Datestamp = date
+	record.Header.SetSpec = sets
+	return record
+}
+
+func newMemoryHook() *memoryHook {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	deleted := newFixtureRecord(5, base.Add(96*time.Hour))
+	deleted.Header.Status = RecordStatusDeleted
+	deleted.Metadata = nil
+	return &memoryHook{
+		records: []OAIPMHRecord{
+			newFixtureRecord(1, base, "public"),
+			newFixtureRecord(2, base.Add(24*time.Hour), "public"),
+			newFixtureRecord(3, base.Add(48*time.Hour)),
+			newFixtureRecord(4, base.Add(72*time.Hour), "public"),
+			deleted,
+		},
+		sets:     []OAIPMHSet{{SetSpec: "public", SetName: "Public corpora"}},
+		pageSize: 2,
+	}
+}
+
+func (h *memoryHook) Identify(ctx context.Context, req OAIPMHRequest) ResultWrapper[OAIPMHIdentify] {
+	return NewResultWrapper(OAIPMHIdentify{
+		RepositoryName:    "Fixture repository",
+		AdminEmail:        []string{"admin@example.com"},
+		EarliestDatestamp: h.records[0].Header.Datestamp,
+		DeletedRecord:     "persistent",
+		Granularity:       GranularitySeconds,
+	})
+}
+
+func (h *memoryHook) GetRecord(ctx context.Context, req OAIPMHRequest) ResultWrapper[OAIPMHRecord] {
+	ans := NewResultWrapper(OAIPMHRecord{})
+	for _, record := range h.records {
+		if record.Header.Identifier == req.Identifier {
+			ans.Data = record
+			return ans
+		}
+	}
+	ans.Errors.Add(ErrorCodeIDDoesNotExist, fmt.Sprintf("Result for ID = %s not found", req.Identifier))
+	return ans
+}
+
+// list returns records matching the request and a resumption token.
+// Tokens have the form `<set>:<offset>`.
+func (h *memoryHook) list(req OAIPMHRequest) ([]OAIPMHRecord, *OAIPMHResumptionToken, OAIPMHErrors) {
+	var errors OAIPMHErrors
+	set, offset := req.Set, 0
+	if req.ResumptionToken != "" {
+		tokenSet, tokenOffset, found := strings.Cut(req.ResumptionToken, ":")
+		parsed, err := strconv.Atoi(tokenOffset)
+		if !found || err != nil {
+			errors.Add(ErrorCodeBadResumptionToken, "Invalid resumption token")
+			return nil, nil, errors
+		}
+		set, offset = tokenSet, parsed
+	}
+	matching := []OAIPMHRecord{}
+	for _, record := range h.records {
+		date := record.Header.Datestamp
+		if (req.From == nil || !date.Before(*req.From)) &&
+			(req.Until == nil || !date.After(*req.Until)) &&
+			(set == "" || slices.Contains(record.Header.SetSpec, set)) {
+			matching = append(matching, record)
+		}
+	}
+	if len(matching) == 0 {
+		errors.Add(ErrorCodeNoRecordsMatch, "No records match the request")
+		return nil, nil, errors
+	}
+	if offset >= len(matching) {
+		errors.Add(ErrorCodeBadResumptionToken, "Invalid resumption token")
+		return nil, nil, errors
+	}
+	end := min(offset+h.pageSize, len(matching))
+	var token *OAIPMHResumptionToken
+	if len(matching) > h.pageSize {
+		token = NewResumptionToken(fmt.Sprintf("%s:%d", set, end), offset, end-offset, len(matching))
+	}
+	return matching[offset:end], token, errors
+}
+
+func (h *memoryHook) ListIdentifiers(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHRecordHeader] {
+	ans := NewResultWrapper([]OAIPMHRecordHeader{})
+	records, token, errors := h.list(req)
+	for _, record := range records {
+		ans.Data = append(ans.Data, *record.Header)
+	}
+	ans.ResumptionToken, ans.Errors = token, errors
+	return ans
+}
+
+func (h *memoryHook) ListMetadataFormats(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHMetadataFormat] {
+	ans := NewResultWrapper([]OAIPMHMetadataFormat{
+		{
+			MetadataPrefix:    "oai_dc",
+			Schema:            "http://www.openarchives.org/OAI/2.0/oai_dc.xsd",
+			MetadataNamespace: "http://www.openarchives.org/OAI/2.0/oai_dc/",
+		},
+	})
+	if req.Identifier != "" {
+		if record := h.GetRecord(ctx, req); !record.NoError() {
+			ans.Errors, ans.HTTPCode = record.Errors, record.HTTPCode
+		}
+	}
+	return ans
+}
+
+func (h *memoryHook) ListRecords(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHRecord] {
+	ans := NewResultWrapper([]OAIPMHRecord{})
+	ans.Data, ans.ResumptionToken, ans.Errors = h.list(req)
+	return ans
+}
+
+func (h *memoryHook) ListSets(ctx context.Context, req OAIPMHRequest) ResultWrapper[[]OAIPMHSet] {
+	ans := NewResultWrapper(h.sets)
+	if req.ResumptionToken != "" {
+		ans.Errors.Add(ErrorCodeBadResumptionToken, "Invalid resumption token")
+	}
+	return ans
+}
+
+func (h *memoryHook) SupportsSets() bool {
+	return true
+}
+
+func (h *memoryHook) SupportedMetadataPrefixes() []string {
+	return []string{"oai_dc"}
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsNamespace = "oaipmh"
+
+	// metricsInvalidVerb labels requests with a missing or unknown
+	// verb so arbitrary values do not create new time series
+	metricsInvalidVerb = "invalid"
+
+	// metricsServerError labels failures with no OAI-PMH error code
+	// (e.g. failed DB queries)
+	metricsServerError = "serverError"
+)
+
+// Metrics collects Prometheus metrics of harvest traffic.
+// A nil *Metrics is valid and collects nothing.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+	records  *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "requests_total",
+				Help:      "Number of OAI-PMH requests by verb",
+			},
+			[]string{"verb"},
+		),
+		errors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "errors_total",
+				Help:      "Number of failed OAI-PMH requests by verb and error code",
+			},
+			[]string{"verb", "code"},
+		),
+		latency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricsNamespace,
+				Name:      "request_duration_seconds",
+				Help:      "Latency of OAI-PMH requests by verb",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"verb"},
+		),
+		inFlight: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricsNamespace,
+				Name:      "requests_in_flight",
+				Help:      "Number of OAI-PMH requests being processed",
+			},
+		),
+		records: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "records_served_total",
+				Help:      "Number of records (or headers) served by verb",
+			},
+			[]string{"verb"},
+		),
+	}
+	m.registry.MustRegister(
+		m.requests, m.errors, m.latency, m.inFlight, m.records,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler returns a HTTP handler exposing the collected metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// requestStarted marks a request as being processed. The returned
+// function is expected to be called once the request is finished.
+func (m *Metrics) requestStarted() func() {
+	if m == nil {
+		return func() {}
+	}
+	m.inFlight.Inc()
+	return m.inFlight.Dec
+}
+
+// observe records a finished request
+func (m *Metrics) observe(verb Verb, resp *OAIPMHResponse, httpCode int, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	verbLabel := string(verb)
+	if verb.Validate() != nil {
+		verbLabel = metricsInvalidVerb
+	}
+	m.requests.WithLabelValues(verbLabel).Inc()
+	m.latency.WithLabelValues(verbLabel).Observe(elapsed.Seconds())
+	for _, e := range resp.Errors {
+		m.errors.WithLabelValues(verbLabel, string(e.Code)).Inc()
+	}
+	if len(resp.Errors) == 0 && httpCode >= http.StatusBadRequest {
+		code := metricsServerError
+		if httpCode < http.StatusInternalServerError {
+			code = strconv.Itoa(httpCode)
+		}
+		m.errors.WithLabelValues(verbLabel, code).Inc()
+	}
+	if numRecords := resp.numRecords(); numRecords > 0 {
+		m.records.WithLabelValues(verbLabel).Add(float64(numRecords))
+	}
+}
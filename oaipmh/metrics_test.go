@@ -0,0 +1,86 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMetricsEngine(hook VLOHook, metrics *Metrics) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler := NewVLOHandler("http://localhost", HandlerSetup{}, hook)
+	handler.SetMetrics(metrics)
+	engine.GET("/oai", handler.HandleOAIGet)
+	engine.GET("/metrics", gin.WrapH(metrics.Handler()))
+	return engine
+}
+
+func doMetricsRequest(engine *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestMetricsScrape(t *testing.T) {
+	metrics := NewMetrics()
+	engine := newMetricsEngine(newMemoryHook(), metrics)
+	assert.NotContains(t, doMetricsRequest(engine, "/metrics").Body.String(), "oaipmh_requests_total")
+
+	for _, query := range []string{
+		"verb=Identify",
+		"verb=ListRecords&metadataPrefix=oai_dc",
+		"verb=GetRecord&identifier=1&metadataPrefix=oai_dc",
+		"verb=GetRecord&identifier=1&metadataPrefix=marc21",
+		"verb=Foo",
+	} {
+		assert.Equal(t, http.StatusOK, doMetricsRequest(engine, "/oai?"+query).Code)
+	}
+	// a failing hook sharing the same metrics
+	failing := newMetricsEngine(&errorHook{}, metrics)
+	w := doMetricsRequest(failing, "/oai?verb=ListIdentifiers&metadataPrefix=oai_dc")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = doMetricsRequest(engine, "/metrics")
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `oaipmh_requests_total{verb="Identify"} 1`)
+	assert.Contains(t, body, `oaipmh_requests_total{verb="ListRecords"} 1`)
+	assert.Contains(t, body, `oaipmh_requests_total{verb="GetRecord"} 2`)
+	assert.Contains(t, body, `oaipmh_requests_total{verb="invalid"} 1`)
+	assert.Contains(t, body, `oaipmh_records_served_total{verb="ListRecords"} 2`)
+	assert.Contains(t, body, `oaipmh_records_served_total{verb="GetRecord"} 1`)
+	assert.Contains(t, body, `oaipmh_errors_total{code="cannotDisseminateFormat",verb="GetRecord"} 1`)
+	assert.Contains(t, body, `oaipmh_errors_total{code="badVerb",verb="invalid"} 1`)
+	assert.Contains(t, body, `oaipmh_errors_total{code="serverError",verb="ListIdentifiers"} 1`)
+	assert.Contains(t, body, `oaipmh_request_duration_seconds_count{verb="Identify"} 1`)
+	assert.Contains(t, body, "oaipmh_requests_in_flight 0")
+}
+
+func TestNilMetrics(t *testing.T) {
+	var metrics *Metrics
+	metrics.requestStarted()()
+	metrics.observe(VerbIdentify, &OAIPMHResponse{}, http.StatusOK, 0)
+	w := doGetRequest(newMemoryHook(), "verb=Identify")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
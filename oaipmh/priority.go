@@ -0,0 +1,94 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	dfltPrioritySlots = 1
+
+	// rejectedRetryAfterSecs is a hint for clients
+	// rejected due to the exhausted shared pool
+	rejectedRetryAfterSecs = "10"
+)
+
+type priorityPools struct {
+	userAgents []string
+	priority   chan struct{}
+	shared     chan struct{}
+}
+
+func (p *priorityPools) isHarvester(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, ua := range p.userAgents {
+		if strings.Contains(userAgent, ua) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *priorityPools) handle(ctx *gin.Context) {
+	if p.isHarvester(ctx.GetHeader("User-Agent")) {
+		select {
+		case p.priority <- struct{}{}:
+			defer func() { <-p.priority }()
+			ctx.Next()
+		case <-ctx.Request.Context().Done():
+			ctx.AbortWithStatus(http.StatusServiceUnavailable)
+		}
+		return
+	}
+	select {
+	case p.shared <- struct{}{}:
+		defer func() { <-p.shared }()
+		ctx.Next()
+	default:
+		ctx.Header("Retry-After", rejectedRetryAfterSecs)
+		ctx.AbortWithStatus(http.StatusServiceUnavailable)
+	}
+}
+
+// PriorityMiddleware limits concurrency of requests with a dedicated
+// pool reserved for configured harvesters so their harvesting stays
+// responsive during traffic spikes.
+func PriorityMiddleware(setup PrioritySetup) gin.HandlerFunc {
+	if setup.SharedSlots <= 0 {
+		return func(ctx *gin.Context) {
+			ctx.Next()
+		}
+	}
+	prioritySlots := setup.PrioritySlots
+	if prioritySlots <= 0 {
+		prioritySlots = dfltPrioritySlots
+	}
+	pools := &priorityPools{
+		priority: make(chan struct{}, prioritySlots),
+		shared:   make(chan struct{}, setup.SharedSlots),
+	}
+	for _, ua := range setup.UserAgents {
+		if ua = strings.TrimSpace(ua); ua != "" {
+			pools.userAgents = append(pools.userAgents, strings.ToLower(ua))
+		}
+	}
+	return pools.handle
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// newBusyEngine creates an engine with an endpoint `/busy` blocking
+// until `release` is closed so pools can be exhausted
+func newBusyEngine(setup PrioritySetup, release chan struct{}, started *sync.WaitGroup) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(PriorityMiddleware(setup))
+	engine.GET("/busy", func(ctx *gin.Context) {
+		started.Done()
+		<-release
+		ctx.Status(http.StatusOK)
+	})
+	engine.GET("/oai", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func doUARequest(engine *gin.Engine, path, userAgent string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("User-Agent", userAgent)
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestPriorityHarvesterServedWhenSharedPoolExhausted(t *testing.T) {
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	engine := newBusyEngine(
+		PrioritySetup{UserAgents: []string{"CLARIN VLO"}, SharedSlots: 1},
+		release,
+		&started,
+	)
+	started.Add(1)
+	go doUARequest(engine, "/busy", "Mozilla/5.0")
+	started.Wait()
+	defer close(release)
+
+	w := doUARequest(engine, "/oai", "Mozilla/5.0")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+
+	w = doUARequest(engine, "/oai", "clarin vlo harvester/1.0")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestPriorityUnmatchedAgentServed(t *testing.T) {
+	engine := newBusyEngine(
+		PrioritySetup{UserAgents: []string{"CLARIN VLO"}, SharedSlots: 1},
+		make(chan struct{}),
+		&sync.WaitGroup{},
+	)
+	w := doUARequest(engine, "/oai", "Mozilla/5.0")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestPriorityDisabled(t *testing.T) {
+	engine := newBusyEngine(PrioritySetup{}, make(chan struct{}), &sync.WaitGroup{})
+	w := doUARequest(engine, "/oai", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -35,6 +35,10 @@ type OAIPMHRequest struct {
 	Until           *time.Time `xml:"until,attr,omitempty"`
 	Set             string     `xml:"set,attr,omitempty"`
 	ResumptionToken string     `xml:"resumptionToken,attr,omitempty"`
+
+	// AcceptLanguage contains value of the Accept-Language header
+	// so hooks can localize some of the values
+	AcceptLanguage string `xml:"-"`
 }
 
 type OAIPMHResponse struct {
@@ -43,7 +47,7 @@ type OAIPMHResponse struct {
 	XMLNSXSI          string   `xml:"xmlns:xsi,attr"`
 	XSISchemaLocation string   `xml:"xsi:schemaLocation,attr"`
 
-	ResponseDate time.Time      `xml:"responseDate"`
+	ResponseDate UTCTime        `xml:"responseDate"`
 	Request      *OAIPMHRequest `xml:"request"`
 	Errors       OAIPMHErrors   `xml:"error,omitempty"`
 
@@ -51,12 +55,37 @@ type OAIPMHResponse struct {
 	GetRecord           *OAIPMHRecord           `xml:"GetRecord>record,omitempty"`
 	ListMetadataFormats *[]OAIPMHMetadataFormat `xml:"ListMetadataFormats>metadataFormat,omitempty"`
 	ListIdentifiers     *[]OAIPMHRecordHeader   `xml:"ListIdentifiers>header,omitempty"`
+	ListIdentifiersRT   *OAIPMHResumptionToken  `xml:"ListIdentifiers>resumptionToken,omitempty"`
 	ListRecords         *[]OAIPMHRecord         `xml:"ListRecords>record,omitempty"`
+	ListRecordsRT       *OAIPMHResumptionToken  `xml:"ListRecords>resumptionToken,omitempty"`
 	ListSets            *[]OAIPMHSet            `xml:"ListSets>set,omitempty"`
+	ListSetsRT          *OAIPMHResumptionToken  `xml:"ListSets>resumptionToken,omitempty"`
 
 	ProtocolVersion string `xml:"-"`
 }
 
+// numRecords returns the number of records (or record headers)
+// contained in the response
+func (r *OAIPMHResponse) numRecords() int {
+	switch {
+	case r.GetRecord != nil:
+		return 1
+	case r.ListRecords != nil:
+		return len(*r.ListRecords)
+	case r.ListIdentifiers != nil:
+		return len(*r.ListIdentifiers)
+	}
+	return 0
+}
+
+// UTCTime is a time serialized in UTC with a second
+// granularity (YYYY-MM-DDThh:mm:ssZ) as required by OAI-PMH
+type UTCTime time.Time
+
+func (t UTCTime) MarshalText() ([]byte, error) {
+	return []byte(time.Time(t).UTC().Truncate(time.Second).Format(time.RFC3339)), nil
+}
+
 type OAIPMHErrors []OAIPMHError
 
 func (r *OAIPMHErrors) Add(code OAIPMHErrorCode, message string) {
@@ -76,7 +105,7 @@ func NewOAIPMHResponse(request *OAIPMHRequest) *OAIPMHResponse {
 		XMLNS:             "http://www.openarchives.org/OAI/2.0/",
 		XMLNSXSI:          "http://www.w3.org/2001/XMLSchema-instance",
 		XSISchemaLocation: "http://www.openarchives.org/OAI/2.0/ http://www.openarchives.org/OAI/2.0/OAI-PMH.xsd",
-		ResponseDate:      time.Now().Round(time.Second).In(time.UTC),
+		ResponseDate:      UTCTime(time.Now()),
 		Request:           request,
 		ProtocolVersion:   "2.0",
 	}
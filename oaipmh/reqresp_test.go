@@ -0,0 +1,99 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"encoding/xml"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseDateFormat(t *testing.T) {
+	resp := NewOAIPMHResponse(&OAIPMHRequest{})
+	resp.ResponseDate = UTCTime(time.Date(2024, 3, 10, 14, 5, 6, 789000000, time.FixedZone("CET", 3600)))
+	data, err := xml.Marshal(resp)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "<responseDate>2024-03-10T13:05:06Z</responseDate>")
+}
+
+func TestResponseDateNowFormat(t *testing.T) {
+	data, err := xml.Marshal(NewOAIPMHResponse(&OAIPMHRequest{}))
+	assert.NoError(t, err)
+	assert.Regexp(
+		t,
+		regexp.MustCompile(`<responseDate>\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z</responseDate>`),
+		string(data),
+	)
+}
+
+func marshalListIdentifiers(t *testing.T, token *OAIPMHResumptionToken) string {
+	resp := NewOAIPMHResponse(&OAIPMHRequest{URL: "http://localhost/oai", Verb: VerbListIdentifiers})
+	resp.ResponseDate = UTCTime(time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC))
+	resp.ListIdentifiers = &[]OAIPMHRecordHeader{
+		{Identifier: "1", Datestamp: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	resp.ListIdentifiersRT = token
+	data, err := xml.Marshal(resp)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestResumptionTokenMidList(t *testing.T) {
+	data := marshalListIdentifiers(t, NewResumptionToken("abc", 0, 1, 2))
+	assert.Contains(
+		t,
+		data,
+		"<ListIdentifiers>"+
+			"<header><identifier>1</identifier><datestamp>2024-03-01T00:00:00Z</datestamp></header>"+
+			`<resumptionToken completeListSize="2" cursor="0">abc</resumptionToken>`+
+			"</ListIdentifiers>",
+	)
+}
+
+func TestResumptionTokenFinalPage(t *testing.T) {
+	data := marshalListIdentifiers(t, NewResumptionToken("abc", 1, 1, 2))
+	assert.Contains(
+		t,
+		data,
+		"<ListIdentifiers>"+
+			"<header><identifier>1</identifier><datestamp>2024-03-01T00:00:00Z</datestamp></header>"+
+			`<resumptionToken completeListSize="2" cursor="1"></resumptionToken>`+
+			"</ListIdentifiers>",
+	)
+}
+
+func TestResumptionTokenOmittedForCompleteList(t *testing.T) {
+	data := marshalListIdentifiers(t, nil)
+	assert.NotContains(t, data, "resumptionToken")
+	assert.Contains(t, data, "</header></ListIdentifiers>")
+}
+
+func TestResumptionTokenListRecords(t *testing.T) {
+	resp := NewOAIPMHResponse(&OAIPMHRequest{})
+	resp.ListRecords = &[]OAIPMHRecord{{Header: &OAIPMHRecordHeader{Identifier: "1"}}}
+	resp.ListRecordsRT = NewResumptionToken("abc", 0, 1, 3)
+	data, err := xml.Marshal(resp)
+	assert.NoError(t, err)
+	assert.Regexp(
+		t,
+		regexp.MustCompile(`<ListRecords><record>.*</record><resumptionToken completeListSize="3" cursor="0">abc</resumptionToken></ListRecords>`),
+		string(data),
+	)
+}
@@ -16,15 +16,60 @@
 
 package oaipmh
 
-import "time"
+import (
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// debugMode makes serialization of invalid output fail
+// instead of silently fixing it
+var debugMode atomic.Bool
+
+// SetDebugMode enables failing on invalid output (e.g. an empty
+// element wrapper) so the problems are noticed during development
+func SetDebugMode(debug bool) {
+	debugMode.Store(debug)
+}
 
 // wrapper to be able to embed custom element with name defined by XMLName
 type ElementWrapper struct {
 	Value any
 }
 
+// MarshalXML omits the whole element in case there is no value
+// as an empty element (e.g. `<metadata/>`) would be invalid.
+// In the debug mode, an error is returned instead.
+func (w ElementWrapper) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if w.Value == nil || isNilPointer(w.Value) {
+		if debugMode.Load() {
+			return errors.New("failed to marshal " + start.Name.Local + ": element wrapper without value")
+		}
+		log.Warn().Str("element", start.Name.Local).Msg("omitting element wrapper without value")
+		return nil
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.Encode(w.Value); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+func isNilPointer(v any) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
 // note - omitempties are optional
 
+const RecordStatusDeleted = "deleted"
+
 type OAIPMHRecordHeader struct {
 	Status     string    `xml:"status,attr,omitempty"` // only `deleted` status
 	Identifier string    `xml:"identifier"`            // URL
@@ -42,10 +87,54 @@ type OAIPMHIdentify struct {
 	EarliestDatestamp time.Time        `xml:"earliestDatestamp"`
 	DeletedRecord     string           `xml:"deletedRecord"` // are we tracking deleted records no/transient/persistent?
 	Granularity       string           `xml:"granularity"`   // all repositories must support YYYY-MM-DD, extra YYYY-MM-DDThh:mm:ssZ
-	Compression       string           `xml:"compression,omitempty"`
+	Compression       []string         `xml:"compression,omitempty"`
 	Description       []ElementWrapper `xml:"description,omitempty"`
 }
 
+// OAIPMHFriends is an Identify description container listing
+// base URLs of related repositories
+type OAIPMHFriends struct {
+	XMLName           xml.Name `xml:"friends"`
+	XMLNS             string   `xml:"xmlns,attr"`
+	XMLNSXSI          string   `xml:"xmlns:xsi,attr"`
+	XSISchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	BaseURL           []string `xml:"baseURL"`
+}
+
+// OAIPMHIdentifierDescription is an Identify description container
+// describing the `oai-identifier` scheme of record identifiers
+type OAIPMHIdentifierDescription struct {
+	XMLName              xml.Name `xml:"oai-identifier"`
+	XMLNS                string   `xml:"xmlns,attr"`
+	XMLNSXSI             string   `xml:"xmlns:xsi,attr"`
+	XSISchemaLocation    string   `xml:"xsi:schemaLocation,attr"`
+	Scheme               string   `xml:"scheme"`
+	RepositoryIdentifier string   `xml:"repositoryIdentifier"`
+	Delimiter            string   `xml:"delimiter"`
+	SampleIdentifier     string   `xml:"sampleIdentifier"`
+}
+
+func NewOAIPMHIdentifierDescription(repositoryIdentifier, sampleIdentifier string) OAIPMHIdentifierDescription {
+	return OAIPMHIdentifierDescription{
+		XMLNS:                "http://www.openarchives.org/OAI/2.0/oai-identifier",
+		XMLNSXSI:             "http://www.w3.org/2001/XMLSchema-instance",
+		XSISchemaLocation:    "http://www.openarchives.org/OAI/2.0/oai-identifier http://www.openarchives.org/OAI/2.0/oai-identifier.xsd",
+		Scheme:               "oai",
+		RepositoryIdentifier: repositoryIdentifier,
+		Delimiter:            ":",
+		SampleIdentifier:     sampleIdentifier,
+	}
+}
+
+func NewOAIPMHFriends(baseURLs []string) OAIPMHFriends {
+	return OAIPMHFriends{
+		XMLNS:             "http://www.openarchives.org/OAI/2.0/friends/",
+		XMLNSXSI:          "http://www.w3.org/2001/XMLSchema-instance",
+		XSISchemaLocation: "http://www.openarchives.org/OAI/2.0/friends/ http://www.openarchives.org/OAI/2.0/friends.xsd",
+		BaseURL:           baseURLs,
+	}
+}
+
 // --------------------- ListMetadataFormats ------------------
 
 type OAIPMHMetadataFormat struct {
@@ -59,6 +148,35 @@ type OAIPMHMetadataFormat struct {
 type OAIPMHRecord struct {
 	Header   *OAIPMHRecordHeader `xml:"header"`
 	Metadata *ElementWrapper     `xml:"metadata,omitempty"`
+	About    []ElementWrapper    `xml:"about,omitempty"`
+}
+
+// OAIPMHProvenance is a container describing origin of a record
+// harvested or otherwise ingested from another repository
+type OAIPMHProvenance struct {
+	XMLName           xml.Name                `xml:"provenance"`
+	XMLNS             string                  `xml:"xmlns,attr"`
+	XMLNSXSI          string                  `xml:"xmlns:xsi,attr"`
+	XSISchemaLocation string                  `xml:"xsi:schemaLocation,attr"`
+	OriginDescription OAIPMHOriginDescription `xml:"originDescription"`
+}
+
+type OAIPMHOriginDescription struct {
+	HarvestDate       UTCTime `xml:"harvestDate,attr"`
+	Altered           bool    `xml:"altered,attr"`
+	BaseURL           string  `xml:"baseURL"`
+	Identifier        string  `xml:"identifier"`
+	Datestamp         UTCTime `xml:"datestamp"`
+	MetadataNamespace string  `xml:"metadataNamespace"`
+}
+
+func NewOAIPMHProvenance(origin OAIPMHOriginDescription) OAIPMHProvenance {
+	return OAIPMHProvenance{
+		XMLNS:             "http://www.openarchives.org/OAI/2.0/provenance",
+		XMLNSXSI:          "http://www.w3.org/2001/XMLSchema-instance",
+		XSISchemaLocation: "http://www.openarchives.org/OAI/2.0/provenance http://www.openarchives.org/OAI/2.0/provenance.xsd",
+		OriginDescription: origin,
+	}
 }
 
 func NewOAIPMHRecord(metadata any) OAIPMHRecord {
@@ -75,3 +193,29 @@ type OAIPMHSet struct {
 	SetName        string          `xml:"setName"`
 	SetDescription *ElementWrapper `xml:"setDescription,omitempty"`
 }
+
+// ----------------------- resumptionToken --------------
+
+// OAIPMHResumptionToken represents a flow control token for incomplete
+// list responses. The `Cursor` is the zero-based position of the first
+// record of the current batch within the complete list.
+type OAIPMHResumptionToken struct {
+	Token            string   `xml:",chardata"`
+	ExpirationDate   *UTCTime `xml:"expirationDate,attr,omitempty"`
+	CompleteListSize int      `xml:"completeListSize,attr"`
+	Cursor           int      `xml:"cursor,attr"`
+}
+
+// NewResumptionToken creates a token for a batch of `batchSize` records
+// starting at `cursor`. For the last batch, the token value is left
+// empty as required by the specification.
+func NewResumptionToken(token string, cursor, batchSize, completeListSize int) *OAIPMHResumptionToken {
+	if cursor+batchSize >= completeListSize {
+		token = ""
+	}
+	return &OAIPMHResumptionToken{
+		Token:            token,
+		CompleteListSize: completeListSize,
+		Cursor:           cursor,
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 Martin Zimandl <martin.zimandl@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResumptionTokenAcrossPages(t *testing.T) {
+	expected := []string{
+		`<resumptionToken completeListSize="25" cursor="0">page2</resumptionToken>`,
+		`<resumptionToken completeListSize="25" cursor="10">page3</resumptionToken>`,
+		`<resumptionToken completeListSize="25" cursor="20"></resumptionToken>`,
+	}
+	tokens := []string{"page2", "page3", "page4"}
+	for i, cursor := range []int{0, 10, 20} {
+		batchSize := min(10, 25-cursor)
+		token := NewResumptionToken(tokens[i], cursor, batchSize, 25)
+		out, err := xml.Marshal(struct {
+			XMLName xml.Name `xml:"resumptionToken"`
+			*OAIPMHResumptionToken
+		}{OAIPMHResumptionToken: token})
+		assert.NoError(t, err)
+		assert.Equal(t, expected[i], string(out))
+	}
+}
+
+func TestResumptionTokenSinglePage(t *testing.T) {
+	token := NewResumptionToken("next", 0, 5, 5)
+	assert.Empty(t, token.Token)
+	assert.Equal(t, 0, token.Cursor)
+	assert.Equal(t, 5, token.CompleteListSize)
+}
+
+func TestResumptionTokenExpirationDate(t *testing.T) {
+	token := NewResumptionToken("next", 0, 10, 25)
+	expires := UTCTime(time.Date(2024, 3, 10, 13, 0, 0, 0, time.FixedZone("CET", 3600)))
+	token.ExpirationDate = &expires
+	out, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"resumptionToken"`
+		*OAIPMHResumptionToken
+	}{OAIPMHResumptionToken: token})
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		`<resumptionToken expirationDate="2024-03-10T12:00:00Z" completeListSize="25" cursor="0">next</resumptionToken>`,
+		string(out),
+	)
+}
+
+type wrappedFixture struct {
+	XMLName xml.Name `xml:"fixture"`
+	Title   string   `xml:"title"`
+}
+
+func TestElementWrapperValue(t *testing.T) {
+	record := NewOAIPMHRecord(wrappedFixture{Title: "SYN2020"})
+	out, err := xml.Marshal(record)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "<metadata><fixture><title>SYN2020</title></fixture></metadata>")
+}
+
+func TestElementWrapperNilValue(t *testing.T) {
+	for _, metadata := range []any{nil, (*wrappedFixture)(nil)} {
+		record := NewOAIPMHRecord(metadata)
+		record.Header.Identifier = "1"
+		out, err := xml.Marshal(record)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(out), "metadata")
+		assert.Contains(t, string(out), "<identifier>1</identifier>")
+	}
+}
+
+func TestElementWrapperNilValueDebugMode(t *testing.T) {
+	SetDebugMode(true)
+	defer SetDebugMode(false)
+	_, err := xml.Marshal(NewOAIPMHRecord(nil))
+	assert.ErrorContains(t, err, "metadata")
+}
+
+func TestIdentifierDescriptionNamespace(t *testing.T) {
+	identify := OAIPMHIdentify{
+		Description: []ElementWrapper{
+			{Value: NewOAIPMHIdentifierDescription("korpus.cz", "oai:korpus.cz:1")},
+		},
+	}
+	out, err := xml.Marshal(identify)
+	assert.NoError(t, err)
+	var parsed struct {
+		Description struct {
+			Identifier struct {
+				Scheme               string `xml:"http://www.openarchives.org/OAI/2.0/oai-identifier scheme"`
+				RepositoryIdentifier string `xml:"http://www.openarchives.org/OAI/2.0/oai-identifier repositoryIdentifier"`
+				Delimiter            string `xml:"http://www.openarchives.org/OAI/2.0/oai-identifier delimiter"`
+				SampleIdentifier     string `xml:"http://www.openarchives.org/OAI/2.0/oai-identifier sampleIdentifier"`
+			} `xml:"http://www.openarchives.org/OAI/2.0/oai-identifier oai-identifier"`
+		} `xml:"description"`
+	}
+	assert.NoError(t, xml.Unmarshal(out, &parsed))
+	assert.Equal(t, "oai", parsed.Description.Identifier.Scheme)
+	assert.Equal(t, "korpus.cz", parsed.Description.Identifier.RepositoryIdentifier)
+	assert.Equal(t, ":", parsed.Description.Identifier.Delimiter)
+	assert.Equal(t, "oai:korpus.cz:1", parsed.Description.Identifier.SampleIdentifier)
+}
@@ -0,0 +1,75 @@
+--
+-- Schema changes for existing installations created before
+-- the respective features were introduced (new installations
+-- should use cncdb/scripts/schema.sql instead).
+-- Apply only the sections which are not applied yet.
+--
+
+-- deletion tracking (required by `deletedRecord` other than `no`,
+-- the column is maintained by track_metadata_deletion_trig,
+-- see triggers.sql or triggers_cnc.sql)
+ALTER TABLE vlo_metadata_common ADD COLUMN deleted_date DATETIME AFTER deleted;
+
+-- corpus availability date (dcterms:available)
+ALTER TABLE vlo_metadata_common ADD COLUMN date_available VARCHAR(255) AFTER date_issued;
+
+-- source records of derived resources (dc:source)
+ALTER TABLE vlo_metadata_common ADD COLUMN source_metadata_id INT AFTER service_metadata_id,
+  ADD CONSTRAINT vlo_metadata_common_source_metadata_id_fk FOREIGN KEY (source_metadata_id)
+    REFERENCES vlo_metadata_common(id) ON DELETE SET NULL ON UPDATE RESTRICT;
+
+-- persistent identifiers (Handles) of records
+ALTER TABLE vlo_metadata_common ADD COLUMN pid VARCHAR(255) AFTER source_metadata_id;
+
+-- origin of records ingested from an upstream repository
+-- (required by `conversion.includeProvenance`)
+ALTER TABLE vlo_metadata_common ADD COLUMN origin_base_url VARCHAR(255) AFTER pid,
+  ADD COLUMN origin_identifier VARCHAR(255) AFTER origin_base_url,
+  ADD COLUMN origin_datestamp DATETIME AFTER origin_identifier,
+  ADD COLUMN origin_synced DATETIME AFTER origin_datestamp;
+
+-- temporal and spatial coverage of corpora
+ALTER TABLE vlo_metadata_corpus ADD COLUMN time_periods VARCHAR(255) AFTER corpus_name,
+  ADD COLUMN places VARCHAR(255) AFTER time_periods;
+
+-- downloadable distributions of records
+-- (required by `conversion.includeDistributions`)
+CREATE TABLE vlo_metadata_distribution (
+  id int(11) PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  metadata_id INT NOT NULL,
+  url VARCHAR(255) NOT NULL,
+  mime_type VARCHAR(127) NOT NULL,
+  size BIGINT,
+  CONSTRAINT vlo_metadata_distribution_metadata_id_fk FOREIGN KEY (metadata_id) REFERENCES vlo_metadata_common(id) ON DELETE CASCADE ON UPDATE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+-- typed relations to other resources
+-- (required by `conversion.includeRelations`)
+CREATE TABLE vlo_metadata_relation (
+  id int(11) PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  metadata_id INT NOT NULL,
+  relation_type VARCHAR(63) NOT NULL,
+  target VARCHAR(255) NOT NULL,
+  CONSTRAINT vlo_metadata_relation_metadata_id_fk FOREIGN KEY (metadata_id) REFERENCES vlo_metadata_common(id) ON DELETE CASCADE ON UPDATE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+-- funding organizations and grants
+-- (required by `conversion.includeFunding`)
+CREATE TABLE vlo_metadata_funding (
+  id int(11) PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  metadata_id INT NOT NULL,
+  organization VARCHAR(255) NOT NULL,
+  code VARCHAR(127),
+  project_name VARCHAR(255),
+  funds_type VARCHAR(127),
+  CONSTRAINT vlo_metadata_funding_metadata_id_fk FOREIGN KEY (metadata_id) REFERENCES vlo_metadata_common(id) ON DELETE CASCADE ON UPDATE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
+
+-- annotation tagsets of corpora (e.g. `PDT`)
+-- (required by `conversion.includeRegistryAttrs`)
+CREATE TABLE vlo_corpus_tagset (
+  corpus_name varchar(63) NOT NULL,
+  tagset_name VARCHAR(63) NOT NULL,
+  PRIMARY KEY (corpus_name, tagset_name),
+  CONSTRAINT vlo_corpus_tagset_corpus_name_fk FOREIGN KEY (corpus_name) REFERENCES kontext_corpus(name) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
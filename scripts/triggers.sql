@@ -11,6 +11,7 @@ DELIMITER //
 DROP TRIGGER IF EXISTS sync_descriptions_from_corpora_trig //
 DROP TRIGGER IF EXISTS sync_descriptions_from_metadata_trig //
 DROP TRIGGER IF EXISTS insert_metadata_on_corpora_insert_trig //
+DROP TRIGGER IF EXISTS track_metadata_deletion_trig //
 
 CREATE TRIGGER sync_descriptions_from_corpora_trig
 AFTER UPDATE ON kontext_corpus
@@ -56,4 +57,16 @@ BEGIN
     INSERT INTO vlo_metadata_common (type, desc_cs, desc_en, corpus_metadata_id, contact_user_id, deleted, license_info, authors, date_issued)
         VALUES ('corpus', NEW.description_cs, NEW.description_en, LAST_INSERT_ID(), @contact_user_id, 1, '', '', '');
 END;
+//
+
+CREATE TRIGGER track_metadata_deletion_trig
+BEFORE UPDATE ON vlo_metadata_common
+FOR EACH ROW
+BEGIN
+    IF NEW.deleted AND NOT OLD.deleted THEN
+        SET NEW.deleted_date = NOW();
+    ELSEIF NOT NEW.deleted THEN
+        SET NEW.deleted_date = NULL;
+    END IF;
+END;
 //
\ No newline at end of file
@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"encoding/xml"
 	"flag"
 	"fmt"
 	"net/http"
@@ -33,10 +34,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 
+	"github.com/czcorpus/cnc-vlo/admin"
 	"github.com/czcorpus/cnc-vlo/cncdb"
 	"github.com/czcorpus/cnc-vlo/cnchook"
 	"github.com/czcorpus/cnc-vlo/cnf"
 	"github.com/czcorpus/cnc-vlo/general"
+	"github.com/czcorpus/cnc-vlo/health"
 	"github.com/czcorpus/cnc-vlo/oaipmh"
 )
 
@@ -51,29 +54,84 @@ func runApiServer(
 	syscallChan chan os.Signal,
 	exitEvent chan os.Signal,
 	db *cncdb.CNCMySQLHandler,
+	version general.VersionInfo,
 ) {
 	if !conf.Logging.Level.IsDebugMode() {
 		gin.SetMode(gin.ReleaseMode)
 	}
+	oaipmh.SetDebugMode(conf.Logging.Level.IsDebugMode())
 
 	engine := gin.New()
 	engine.Use(gin.Recovery())
 	engine.Use(logging.GinMiddleware())
 	engine.NoMethod(uniresp.NoMethodHandler)
-	engine.NoRoute(uniresp.NotFoundHandler)
 
 	hook := cnchook.NewCNCHook(conf, db)
-	handler := oaipmh.NewVLOHandler(conf.RepositoryInfo.BaseURL, hook)
-	engine.GET("/oai", handler.HandleOAIGet)
-	engine.POST("/oai", handler.HandleOAIPost)
+	if conf.StartupSelfTest != cnf.SelfTestOff {
+		if err := hook.RunSelfTest(); err != nil {
+			if conf.StartupSelfTest == cnf.SelfTestFatal {
+				log.Fatal().Err(err).Msg("Startup self-test failed")
+			}
+			log.Error().Err(err).Msg("Startup self-test failed")
+
+		} else {
+			log.Info().Msg("Startup self-test passed")
+		}
+	}
+	handler := oaipmh.NewVLOHandler(conf.RepositoryInfo.BaseURL, conf.OAIPMH, hook)
+	engine.NoRoute(handler.HandleNoRoute)
+	if conf.ExposeMetrics {
+		metrics := oaipmh.NewMetrics()
+		handler.SetMetrics(metrics)
+		engine.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+	priority := oaipmh.PriorityMiddleware(conf.OAIPMH.Priority)
+	compression := oaipmh.CompressionMiddleware(conf.OAIPMH)
+	engine.GET("/oai", priority, compression, handler.HandleOAIGet)
+	engine.POST("/oai", priority, compression, handler.HandleOAIPost)
+	if conf.OAIPMH.LenientRequests {
+		engine.GET("/oai/", priority, compression, handler.HandleOAIGet)
+		engine.POST("/oai/", priority, compression, handler.HandleOAIPost)
+	}
+	engine.GET("/oai/recent", priority, compression, handler.HandleRecent)
 	engine.GET("/record/:recordId", handler.HandleSelfLink)
+	engine.GET("/sitemap.xml", priority, compression, hook.HandleSitemap)
+	engine.GET("/robots.txt", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, conf.RobotsTxt)
+	})
+	engine.GET("/version", func(ctx *gin.Context) {
+		uniresp.WriteJSONResponse(ctx.Writer, version)
+	})
+	healthHandler := health.NewHandler(db, hook, version)
+	engine.GET("/healthz", healthHandler.HandleLiveness)
+	engine.GET("/readyz", healthHandler.HandleReadiness)
+	engine.GET("/health/freshness", healthHandler.HandleFreshness)
+	if conf.AdminToken != "" {
+		adminHandler := admin.NewHandler(hook, conf.AdminToken)
+		adminRoutes := engine.Group("/admin", adminHandler.Authorize)
+		adminRoutes.POST("/cache/clear", adminHandler.HandleCacheClear)
+		adminRoutes.GET("/harvest/last-served", adminHandler.HandleLastServed)
+
+	} else {
+		log.Info().Msg("adminToken not set, admin endpoints are disabled")
+	}
+
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	refreshDone := make(chan struct{})
+	go func() {
+		hook.RunBackgroundRefresh(refreshCtx)
+		close(refreshDone)
+	}()
 
 	log.Info().Msgf("starting to listen at %s:%d", conf.ListenAddress, conf.ListenPort)
 	srv := &http.Server{
-		Handler:      engine,
-		Addr:         fmt.Sprintf("%s:%d", conf.ListenAddress, conf.ListenPort),
-		WriteTimeout: time.Duration(conf.ServerWriteTimeoutSecs) * time.Second,
-		ReadTimeout:  time.Duration(conf.ServerReadTimeoutSecs) * time.Second,
+		Handler:           engine,
+		Addr:              fmt.Sprintf("%s:%d", conf.ListenAddress, conf.ListenPort),
+		WriteTimeout:      time.Duration(conf.ServerWriteTimeoutSecs) * time.Second,
+		ReadTimeout:       time.Duration(conf.ServerReadTimeoutSecs) * time.Second,
+		ReadHeaderTimeout: time.Duration(conf.ServerReadHeaderTimeoutSecs) * time.Second,
+		IdleTimeout:       time.Duration(conf.ServerIdleTimeoutSecs) * time.Second,
+		MaxHeaderBytes:    conf.ServerMaxHeaderBytes,
 	}
 	go func() {
 		err := srv.ListenAndServe()
@@ -91,6 +149,80 @@ func runApiServer(
 		if err != nil {
 			log.Info().Err(err).Msg("Shutdown request error")
 		}
+		stopRefresh()
+		<-refreshDone
+	}
+}
+
+func runCrosswalk(conf *cnf.Conf, db *cncdb.CNCMySQLHandler, identifier string) {
+	if identifier == "" {
+		log.Fatal().Msg("Missing record identifier")
+	}
+	hook := cnchook.NewCNCHook(conf, db)
+	crosswalk, err := hook.GetCrosswalk(context.Background(), identifier)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create crosswalk")
+	}
+	if crosswalk == nil {
+		log.Fatal().Str("identifier", identifier).Msg("Record not found")
+	}
+	data, err := xml.MarshalIndent(crosswalk, "", "  ")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to encode crosswalk")
+	}
+	fmt.Println(xml.Header + string(data))
+}
+
+func runExport(conf *cnf.Conf, db *cncdb.CNCMySQLHandler, metadataPrefix, outFile string) {
+	if metadataPrefix == "" || outFile == "" {
+		log.Fatal().Msg("Missing metadata format or output file")
+	}
+	f, err := os.Create(outFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create output file")
+	}
+	defer f.Close()
+	hook := cnchook.NewCNCHook(conf, db)
+	numRecords, err := hook.ExportRecords(context.Background(), f, metadataPrefix)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to export records")
+	}
+	log.Info().Int("numRecords", numRecords).Str("file", outFile).Msg("Records exported")
+}
+
+func setupDBOverrides(conf *cnf.Conf) {
+	if conf.CNCDB.Overrides.CorporaTableName != "" {
+		log.Warn().Msgf(
+			"Overriding default corpora table name to '%s'", conf.CNCDB.Overrides.CorporaTableName)
+
+	} else {
+		conf.CNCDB.Overrides.CorporaTableName = "kontext_corpus"
+	}
+	if conf.CNCDB.Overrides.UserTableName != "" {
+		log.Warn().Msgf(
+			"Overriding default user table name to '%s'", conf.CNCDB.Overrides.UserTableName)
+
+	} else {
+		conf.CNCDB.Overrides.UserTableName = "kontext_user"
+	}
+	if conf.CNCDB.Overrides.UserTableFirstNameCol != "" {
+		log.Warn().Msgf(
+			"Overriding default user table column for the `first name` to '%s'",
+			conf.CNCDB.Overrides.UserTableFirstNameCol,
+		)
+
+	} else {
+		conf.CNCDB.Overrides.UserTableFirstNameCol = "firstname"
+	}
+
+	if conf.CNCDB.Overrides.UserTableLastNameCol != "" {
+		log.Warn().Msgf(
+			"Overriding default user table column for the `first name` to '%s'",
+			conf.CNCDB.Overrides.UserTableLastNameCol,
+		)
+
+	} else {
+		conf.CNCDB.Overrides.UserTableLastNameCol = "lastname"
 	}
 }
 
@@ -108,6 +240,8 @@ func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "VLO repository\n\n")
 		fmt.Fprintf(os.Stderr, "Usage:\n\t%s [options] start [config.json]\n\t", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "%s [options] crosswalk [config.json] [identifier]\n\t", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "%s [options] export [config.json] [format] [outfile]\n\t", filepath.Base(os.Args[0]))
 		fmt.Fprintf(os.Stderr, "%s [options] version\n", filepath.Base(os.Args[0]))
 		flag.PrintDefaults()
 	}
@@ -131,46 +265,20 @@ func main() {
 		close(exitEvent)
 	}()
 
+	setupDBOverrides(conf)
+	db, err := cncdb.NewCNCMySQLHandler(
+		conf.CNCDB, conf.Conversion.IncludeProvenance, conf.Logging.Level.IsDebugMode())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create DB connection")
+	}
+
 	switch action {
 	case "start":
-		if conf.CNCDB.Overrides.CorporaTableName != "" {
-			log.Warn().Msgf(
-				"Overriding default corpora table name to '%s'", conf.CNCDB.Overrides.CorporaTableName)
-
-		} else {
-			conf.CNCDB.Overrides.CorporaTableName = "kontext_corpus"
-		}
-		if conf.CNCDB.Overrides.UserTableName != "" {
-			log.Warn().Msgf(
-				"Overriding default user table name to '%s'", conf.CNCDB.Overrides.UserTableName)
-
-		} else {
-			conf.CNCDB.Overrides.UserTableName = "kontext_user"
-		}
-		if conf.CNCDB.Overrides.UserTableFirstNameCol != "" {
-			log.Warn().Msgf(
-				"Overriding default user table column for the `first name` to '%s'",
-				conf.CNCDB.Overrides.UserTableFirstNameCol,
-			)
-
-		} else {
-			conf.CNCDB.Overrides.UserTableFirstNameCol = "firstname"
-		}
-
-		if conf.CNCDB.Overrides.UserTableLastNameCol != "" {
-			log.Warn().Msgf(
-				"Overriding default user table column for the `first name` to '%s'",
-				conf.CNCDB.Overrides.UserTableLastNameCol,
-			)
-
-		} else {
-			conf.CNCDB.Overrides.UserTableLastNameCol = "lastname"
-		}
-		db, err := cncdb.NewCNCMySQLHandler(conf.CNCDB)
-		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to create DB connection")
-		}
-		runApiServer(conf, syscallChan, exitEvent, db)
+		runApiServer(conf, syscallChan, exitEvent, db, version)
+	case "crosswalk":
+		runCrosswalk(conf, db, flag.Arg(2))
+	case "export":
+		runExport(conf, db, flag.Arg(2), flag.Arg(3))
 	default:
 		log.Fatal().Msgf("Unknown action %s", action)
 	}